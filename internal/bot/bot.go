@@ -6,7 +6,6 @@ import (
 	"adtime-bot/pkg/redis"
 	"context"
 	"fmt"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -62,12 +61,23 @@ func (b *Bot) RegisterHandlers() {
         StepPrivacyAgreement: b.HandlePrivacyAgreement,
         StepServiceSelection: b.HandleServiceSelection,
 		StepServiceType:      b.HandleServiceType,
+		StepShape:            b.HandleShape,
 		StepDimensions:       b.HandleDimensionsSize,
+		StepQuantity:         b.HandleQuantity,
 		StepDateSelection:    b.HandleDateSelection,
 		StepManualDateInput:  b.HandleManualDateInput,
 		StepDateConfirmation: b.HandleDateConfirmation,
 		StepContactMethod:    b.HandleContactMethod,
 		StepPhoneNumber:      b.HandlePhoneNumber,
+		StepOrderConfirmation: b.HandleOrderConfirmation,
+		StepCancelReason:     b.HandleCancelReason,
+		StepEditOrderField:   b.HandleEditOrderField,
+		StepEditOrderValue:   b.HandleEditOrderValue,
+		StepQuotePrice:       b.HandleQuotePriceInput,
+		StepOrderMessage:     b.HandleOrderMessageInput,
+		StepAttachments:      b.HandleAttachmentsStep,
+		StepFulfilment:       b.HandleFulfilment,
+		StepDeliveryAddress:  b.HandleDeliveryAddress,
 	}
 }
 
@@ -78,6 +88,16 @@ func (b *Bot) Start(ctx context.Context) error {
 	u.Timeout = 60
 	updates := b.bot.GetUpdatesChan(u)
 
+	// Background jobs stop with ctx; Start returns once they have finished
+	var jobs sync.WaitGroup
+	defer jobs.Wait()
+
+	jobs.Add(1)
+	go func() {
+		defer jobs.Done()
+		b.watchOverdueOrders(ctx)
+	}()
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -109,21 +129,31 @@ func (b *Bot) ProcessMessage(ctx context.Context, message *tgbotapi.Message) {
             b.SendError(chatID, "Пожалуйста, предоставьте действительный номер телефона")
             return
         }
-        
-        // Skip phone number input step and proceed to create order
-        _, err := b.CreateOrder(ctx, chatID, normalized)
-        if err != nil {
-            b.logger.Error("Failed to create order from contact",
+
+        if err := b.state.SetPhoneNumber(ctx, chatID, normalized); err != nil {
+            b.logger.Error("Failed to save shared contact",
                 zap.Int64("chat_id", chatID),
                 zap.Error(err))
-            b.SendError(chatID, "Ошибка при оформлении заказа")
+            b.SendError(chatID, "Ошибка при сохранении контакта")
             return
         }
 
-        // Just clear state without sending another message
-        b.state.ClearState(ctx, chatID)
+        // Let the customer check everything before the order is saved
+        b.ShowOrderReview(ctx, chatID)
         return
 	}
+
+    // A shared location is a delivery address
+    if message.Location != nil {
+        b.HandleLocationMessage(ctx, chatID, message.Location)
+        return
+    }
+
+    // Photos and files are reference material for an order
+    if len(message.Photo) > 0 || message.Document != nil {
+        b.HandleAttachmentMessage(ctx, chatID, message)
+        return
+    }
     
     if message.IsCommand() {
         // Split command and arguments
@@ -144,6 +174,42 @@ func (b *Bot) ProcessMessage(ctx context.Context, message *tgbotapi.Message) {
             b.HandleHelp(ctx, chatID)
         case "new_order":
             b.HandleNewOrder(ctx, chatID)
+        case "order_history":
+            b.HandleOrderHistory(ctx, chatID)
+        case "my_queue":
+            b.HandleMyQueue(ctx, chatID)
+        case "cancel_order":
+            if len(args) == 0 {
+                b.SendError(chatID, "Использование: /cancel_order <номер_заказа>")
+                return
+            }
+            b.HandleCustomerCancelOrder(ctx, chatID, args[0])
+        case "edit_order":
+            if len(args) == 0 {
+                b.SendError(chatID, "Использование: /edit_order <номер_заказа>")
+                return
+            }
+            b.HandleEditOrder(ctx, chatID, args[0])
+        case "order":
+            if len(args) == 0 {
+                b.SendError(chatID, "Использование: /order <номер_заказа>")
+                return
+            }
+            b.HandleOrderView(ctx, chatID, args[0])
+        case "ask":
+            if len(args) == 0 {
+                b.SendError(chatID, "Использование: /ask <номер_заказа>")
+                return
+            }
+            b.HandleOrderMessage(ctx, chatID, args[0])
+        case "attach":
+            if len(args) == 0 {
+                b.SendError(chatID, "Использование: /attach <номер_заказа>")
+                return
+            }
+            b.HandleAttachOrder(ctx, chatID, args[0])
+        case "promo":
+            b.HandlePromoCommand(ctx, chatID, args)
         default:
             b.HandleUnknownCommand(ctx, chatID)
         }
@@ -157,6 +223,11 @@ func (b *Bot) ProcessMessage(ctx context.Context, message *tgbotapi.Message) {
         return
     }
 
+    if message.Text == "📋 Мои заказы" {
+        b.HandleOrderHistory(ctx, chatID)
+        return
+    }
+
     // Handle regular messages
     step, err := b.state.GetStep(ctx, chatID)
     if err != nil {
@@ -191,9 +262,48 @@ func (b *Bot) ProcessCallback(ctx context.Context, callback *tgbotapi.CallbackQu
         b.HandleTextureSelection(ctx, callback)
     case callback.Data == "cancel":
         b.HandleCancel(ctx, chatID)
+    case strings.HasPrefix(callback.Data, "cancel_order:"):
+        b.HandleCustomerCancelOrder(ctx, chatID, strings.TrimPrefix(callback.Data, "cancel_order:"))
+    case strings.HasPrefix(callback.Data, "edit_order:"):
+        b.HandleEditOrder(ctx, chatID, strings.TrimPrefix(callback.Data, "edit_order:"))
+    case strings.HasPrefix(callback.Data, "order_message:"):
+        b.HandleOrderMessage(ctx, chatID, strings.TrimPrefix(callback.Data, "order_message:"))
+    case strings.HasPrefix(callback.Data, "history:"):
+        b.HandleOrderHistoryPage(ctx, chatID, callback.Message.MessageID, strings.TrimPrefix(callback.Data, "history:"))
+    case strings.HasPrefix(callback.Data, "find:"):
+        b.HandleFindPage(ctx, chatID, callback.Message.MessageID, strings.TrimPrefix(callback.Data, "find:"))
+    case strings.HasPrefix(callback.Data, "status_menu:"):
+        b.HandleStatusMenu(ctx, chatID, strings.TrimPrefix(callback.Data, "status_menu:"))
+    case strings.HasPrefix(callback.Data, "export_order:"):
+        b.HandleExportOrderCallback(ctx, chatID, strings.TrimPrefix(callback.Data, "export_order:"))
+    case strings.HasPrefix(callback.Data, "order_card:"):
+        b.HandleOrderView(ctx, chatID, strings.TrimPrefix(callback.Data, "order_card:"))
+    case strings.HasPrefix(callback.Data, "repeat_order:"):
+        b.HandleRepeatOrder(ctx, chatID, strings.TrimPrefix(callback.Data, "repeat_order:"))
+    case strings.HasPrefix(callback.Data, "attach_order:"):
+        b.HandleAttachOrder(ctx, chatID, strings.TrimPrefix(callback.Data, "attach_order:"))
+    case strings.HasPrefix(callback.Data, "quote_price:"):
+        b.HandleQuotePriceButton(ctx, chatID, strings.TrimPrefix(callback.Data, "quote_price:"))
+    case strings.HasPrefix(callback.Data, "quote_accept:"):
+        b.HandleQuoteAccept(ctx, chatID, strings.TrimPrefix(callback.Data, "quote_accept:"))
+    case strings.HasPrefix(callback.Data, "quote_decline:"):
+        b.HandleQuoteDecline(ctx, chatID, strings.TrimPrefix(callback.Data, "quote_decline:"))
+    case strings.HasPrefix(callback.Data, "assign_menu:"):
+        b.HandleAssignMenu(ctx, chatID, strings.TrimPrefix(callback.Data, "assign_menu:"))
+    case strings.HasPrefix(callback.Data, "assign:"):
+        parts := strings.Split(callback.Data, ":")
+        if len(parts) != 3 {
+            b.SendError(chatID, "Неверный формат команды")
+            return
+        }
+        b.HandleAssignOrder(ctx, chatID, parts[1], parts[2])
     case strings.HasPrefix(callback.Data, "status:"):
         parts := strings.Split(callback.Data, ":")
-        b.HandleStatusUpdate(ctx, callback.Message.Chat.ID, parts[1], parts[2])    
+        if len(parts) != 3 {
+            b.SendError(chatID, "Неверный формат команды")
+            return
+        }
+        b.HandleStatusUpdate(ctx, chatID, parts[1], parts[2])
     default:
         b.logger.Warn("Unknown callback received",
             zap.String("callback_data", callback.Data),
@@ -205,58 +315,8 @@ func (b *Bot) ProcessCallback(ctx context.Context, callback *tgbotapi.CallbackQu
 }
 
 func (b *Bot) HandleAdminStatusUpdate(ctx context.Context, chatID int64, orderIDStr, action string) {
-    if !b.IsAdmin(chatID) {
-        b.SendError(chatID, "❌ У вас нет прав для этого действия")
-        return
-    }
-
-    orderID, err := strconv.ParseInt(orderIDStr, 10, 64)
-    if err != nil {
-        b.SendError(chatID, "❌ Неверный ID заказа")
-        return
-    }
-
-    var newStatus string
-    switch action {
-    case "processing":
-        newStatus = "processing"
-    case "cancelled":
-        newStatus = "cancelled"
-    default:
-        b.SendError(chatID, "❌ Неизвестное действие")
-        return
-    }
-
-    err = b.storage.UpdateOrderStatus(ctx, orderID, newStatus)
-    if err != nil {
-        b.logger.Error("Failed to update order status", zap.Error(err))
-        b.SendError(chatID, "❌ Ошибка при обновлении статуса")
-        return
-    }
-
-    // Отправляем подтверждение админу
-    b.SendMessage(tgbotapi.NewMessage(chatID, fmt.Sprintf(
-        "✅ Статус заказа #%d изменён на: %s",
-        orderID,
-        map[string]string{
-            "processing": "В обработке",
-            "cancelled": "Отменён",
-        }[newStatus],
-    )))
-
-    // Уведомляем пользователя
-    order, err := b.storage.GetOrderByID(ctx, orderID)
-    if err == nil {
-        userMsg := tgbotapi.NewMessage(order.UserID, fmt.Sprintf(
-            "ℹ️ Статус вашего заказа #%d изменён на: %s",
-            orderID,
-            map[string]string{
-                "processing": "В обработке",
-                "cancelled": "Отменён",
-            }[newStatus],
-        ))
-        b.SendMessage(userMsg)
-    }
+    // Kept for older callbacks; the lifecycle rules live in HandleStatusUpdate
+    b.HandleStatusUpdate(ctx, chatID, orderIDStr, action)
 }
 
 func (b *Bot) SendError(chatID int64, text string) {
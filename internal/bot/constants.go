@@ -4,12 +4,34 @@ const (
     StepPrivacyAgreement = "privacy_agreement"
     StepServiceSelection = "service_selection"
     StepServiceType      = "service_type"
+    StepShape            = "shape"
     StepDimensions       = "dimensions"
+    StepQuantity         = "quantity"
     StepDateSelection    = "date_selection"
     StepManualDateInput  = "manual_date_input"
     StepDateConfirmation = "date_confirmation"
     StepContactMethod    = "contact_method"
     StepPhoneNumber      = "phone_number"
+    StepOrderConfirmation = "order_confirmation"
     StepTextureSelection = "texture_selection"
     CustomTextureInput   = "custom_texture_input"
+    StepCancelReason     = "cancel_reason"
+    StepEditOrderField   = "edit_order_field"
+    StepEditOrderValue   = "edit_order_value"
+    StepQuotePrice       = "quote_price"
+    StepOrderMessage     = "order_message"
+    StepAttachments      = "attachments"
+    StepFulfilment       = "fulfilment"
+    StepDeliveryAddress  = "delivery_address"
+)
+
+// CustomTextureService is the service for textures outside the catalogue;
+// such orders are saved as quote requests and priced by an admin
+const CustomTextureService = "Другая текстура"
+
+// Fields of a saved order that can be edited
+const (
+    EditFieldSize    = "size"
+    EditFieldTexture = "texture"
+    EditFieldDate    = "due_date"
 )
@@ -1,9 +1,11 @@
 package bot
 
 import (
+	"adtime-bot/internal/storage"
 	"context"
+	"errors"
 	"fmt"
-	"strconv"
+	"strings"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -20,48 +22,94 @@ func (b *Bot) HandleAdminCommand(ctx context.Context, chatID int64, cmd string,
         if len(args) == 0 {
             b.exportAllOrders(ctx, chatID)
         } else {
-            orderID, err := strconv.ParseInt(args[0], 10, 64)
-            if err != nil {
-                b.SendError(chatID, "Неверный формат ID заказа")
-                return
-            }
-            b.exportSingleOrder(ctx, chatID, orderID)
+            b.exportSingleOrder(ctx, chatID, args[0])
         }
     case "stats":
         b.HandleOrderStats(ctx, chatID)
+    case "edit":
+        if len(args) == 0 {
+            b.SendError(chatID, "Использование: /edit <номер_заказа>")
+            return
+        }
+        b.HandleEditOrder(ctx, chatID, args[0])
     case "status":
         if len(args) < 2 {
-            b.SendError(chatID, "Использование: /status <ID_заказа> <новый_статус>")
+            b.SendError(chatID, "Использование: /status <номер_заказа> <новый_статус>")
             return
         }
         b.HandleStatusUpdate(ctx, chatID, args[0], args[1])
+    case "order":
+        if len(args) == 0 {
+            b.SendError(chatID, "Использование: /order <номер_заказа>")
+            return
+        }
+        b.HandleOrderView(ctx, chatID, args[0])
+    case "quote":
+        if len(args) < 2 {
+            b.SendError(chatID, "Использование: /quote <номер_запроса> <цена_за_дм2>")
+            return
+        }
+        b.HandleQuotePrice(ctx, chatID, args[0], args[1])
+    case "quotes":
+        b.HandleOpenQuotes(ctx, chatID)
+    case "find":
+        b.HandleFindOrders(ctx, chatID, args)
+    case "capacity":
+        b.HandleCapacityCommand(ctx, chatID, args)
+    case "staff":
+        b.HandleStaffCommand(ctx, chatID, args)
+    case "assign":
+        if len(args) < 2 {
+            b.SendError(chatID, "Использование: /assign <номер_заказа> <telegram_id_мастера>")
+            return
+        }
+        b.HandleAssignOrder(ctx, chatID, args[0], args[1])
+    case "my_queue":
+        b.HandleMyQueue(ctx, chatID)
+    case "formula":
+        b.HandleFormulaCommand(ctx, chatID, args)
+    case "promos":
+        b.HandlePromosCommand(ctx, chatID, args)
+    case "promo":
+        b.HandlePromoCommand(ctx, chatID, args)
     default:
         b.SendError(chatID, "Неизвестная команда администратора")
     }
 }
 
-func (b *Bot) HandleStatusUpdate(ctx context.Context, chatID int64, orderIDStr string, newStatus string) {
-    orderID, err := strconv.ParseInt(orderIDStr, 10, 64)
-    if err != nil {
-        b.SendError(chatID, "Неверный формат ID заказа")
+// HandleStatusUpdate moves an order, given by its public code or ID, to newStatus
+func (b *Bot) HandleStatusUpdate(ctx context.Context, chatID int64, orderRef string, newStatus string) {
+    if !b.IsAdmin(chatID) {
+        b.SendError(chatID, "У вас нет прав для этого действия")
         return
     }
 
-    // Validate status
-    validStatuses := map[string]bool{
-        "new":        true,
-        "processing": true,
-        "completed":  true,
-        "cancelled":  true,
+    if !storage.IsValidStatus(newStatus) {
+        b.SendError(chatID, "Недопустимый статус. Допустимые значения: "+strings.Join(storage.OrderStatuses, ", "))
+        return
     }
-    if !validStatuses[newStatus] {
-        b.SendError(chatID, "Недопустимый статус. Допустимые значения: new, processing, completed, cancelled")
+
+    order, err := b.storage.GetOrderByRef(ctx, orderRef)
+    if err != nil {
+        if errors.Is(err, storage.ErrOrderNotFound) {
+            b.SendError(chatID, "Заказ не найден")
+            return
+        }
+        b.logger.Error("Failed to get order",
+            zap.String("order", orderRef),
+            zap.Error(err))
+        b.SendError(chatID, "Ошибка при получении заказа")
         return
     }
+    orderID := order.ID
 
     // Update status in database
-    err = b.storage.UpdateOrderStatus(ctx, orderID, newStatus)
+    err = b.storage.UpdateOrderStatus(ctx, orderID, newStatus, chatID)
     if err != nil {
+        if errors.Is(err, storage.ErrInvalidStatusTransition) {
+            b.SendError(chatID, FormatStatusTransitionError(order.Number(), order.Status, newStatus))
+            return
+        }
         b.logger.Error("Failed to update order status",
             zap.Int64("order_id", orderID),
             zap.String("status", newStatus),
@@ -70,36 +118,28 @@ func (b *Bot) HandleStatusUpdate(ctx context.Context, chatID int64, orderIDStr s
         return
     }
 
-    // Notify admin
-    b.SendMessage(tgbotapi.NewMessage(chatID, fmt.Sprintf(
-        "✅ Статус заказа #%d изменён на: %s",
-        orderID,
-        map[string]string{
-            "new":        "Новый",
-            "processing": "В обработке",
-            "completed":  "Завершён",
-            "cancelled":  "Отменён",
-        }[newStatus],
-    )))
+    // Notify admin and offer the next steps of the lifecycle
+    adminMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
+        "✅ Статус заказа %s изменён: %s → %s",
+        order.Number(),
+        StatusLabel(order.Status),
+        StatusLabel(newStatus),
+    ))
+    if len(storage.AllowedStatusTransitions(newStatus)) > 0 {
+        adminMsg.ReplyMarkup = b.CreateStatusKeyboard(order.Number(), newStatus)
+    }
+    b.SendMessage(adminMsg)
 
     // Notify user if possible
-    order, err := b.storage.GetOrderByID(ctx, orderID)
-    if err == nil {
-        userMsg := tgbotapi.NewMessage(order.UserID, fmt.Sprintf(
-            "ℹ️ Статус вашего заказа #%d изменён на: %s",
-            orderID,
-            map[string]string{
-                "new":        "Новый",
-                "processing": "В обработке",
-                "completed":  "Завершён",
-                "cancelled":  "Отменён",
-            }[newStatus],
-        ))
-        if _, err := b.bot.Send(userMsg); err != nil {
-            b.logger.Warn("Failed to notify user about status change",
-                zap.Int64("user_id", order.UserID),
-                zap.Error(err))
-        }
+    userMsg := tgbotapi.NewMessage(order.UserID, fmt.Sprintf(
+        "ℹ️ Статус вашего заказа %s изменён на: %s",
+        order.Number(),
+        StatusLabel(newStatus),
+    ))
+    if _, err := b.bot.Send(userMsg); err != nil {
+        b.logger.Warn("Failed to notify user about status change",
+            zap.Int64("user_id", order.UserID),
+            zap.Error(err))
     }
 }
 
@@ -117,26 +157,42 @@ func (b *Bot) HandleOrderStats(ctx context.Context, chatID int64) {
     msgText := fmt.Sprintf(
         "📊 *Статистика заказов*\n\n"+
             "📌 Всего заказов: %d\n"+
+            "📦 Изделий: %d\n"+
             "💰 Общая сумма: %.2f ₽\n"+
             "📅 За сегодня: %d (%.2f ₽)\n"+
             "📅 За неделю: %d (%.2f ₽)\n"+
-            "📅 За месяц: %d (%.2f ₽)\n\n"+
+            "📅 За месяц: %d (%.2f ₽)\n"+
+            "⏰ Просрочено: %d\n\n"+
             "📌 По статусам:\n"+
             "🆕 Новые: %d\n"+
-            "🔄 В обработке: %d\n"+
-            "✅ Завершённые: %d\n"+
+            "👍 Подтверждённые: %d\n"+
+            "🔄 В производстве: %d\n"+
+            "📦 Готовые: %d\n"+
+            "✅ Выданные: %d\n"+
             "❌ Отменённые: %d",
         stats.TotalOrders,
+        stats.TotalItems,
         stats.TotalRevenue,
         stats.TodayOrders, stats.TodayRevenue,
         stats.WeekOrders, stats.WeekRevenue,
         stats.MonthOrders, stats.MonthRevenue,
-        stats.StatusCounts["new"],
-        stats.StatusCounts["processing"],
-        stats.StatusCounts["completed"],
-        stats.StatusCounts["cancelled"],
+        stats.OverdueOrders,
+        stats.StatusCounts[storage.StatusNew],
+        stats.StatusCounts[storage.StatusConfirmed],
+        stats.StatusCounts[storage.StatusInProduction],
+        stats.StatusCounts[storage.StatusReady],
+        stats.StatusCounts[storage.StatusDelivered],
+        stats.StatusCounts[storage.StatusCancelled],
     )
 
+    // Assignments change all the time, so the workload is not part of the cached statistics
+    workload, err := b.storage.GetStaffWorkload(ctx, time.Now())
+    if err != nil {
+        b.logger.Error("Failed to get staff workload", zap.Error(err))
+    } else {
+        msgText += "\n\n" + FormatStaffWorkload(workload)
+    }
+
     msg := tgbotapi.NewMessage(chatID, msgText)
     msg.ParseMode = "Markdown"
     b.SendMessage(msg)
@@ -180,7 +236,7 @@ func (b *Bot) HandleExportSingleOrder(ctx context.Context, chatID int64, orderID
 	}
 
 	msg := tgbotapi.NewDocument(chatID, tgbotapi.FilePath(filepath))
-	msg.Caption = fmt.Sprintf("📊 Order #%d export", orderID)
+	msg.Caption = fmt.Sprintf("📊 Order %s export", order.Number())
 
 	if _, err := b.bot.Send(msg); err != nil {
 		b.logger.Error("Failed to send Excel file", zap.Error(err))
@@ -207,8 +263,8 @@ func (b *Bot) exportAllOrders(ctx context.Context, chatID int64) {
     }
 }
 
-func (b *Bot) exportSingleOrder(ctx context.Context, chatID, orderID int64) {
-    order, err := b.storage.GetOrderByID(ctx, orderID)
+func (b *Bot) exportSingleOrder(ctx context.Context, chatID int64, orderRef string) {
+    order, err := b.storage.GetOrderByRef(ctx, orderRef)
     if err != nil {
         b.logger.Error("Failed to get order", zap.Error(err))
         b.SendError(chatID, "Заказ не найден")
@@ -223,7 +279,7 @@ func (b *Bot) exportSingleOrder(ctx context.Context, chatID, orderID int64) {
     }
 
     doc := tgbotapi.NewDocument(chatID, tgbotapi.FilePath(filepath))
-    doc.Caption = fmt.Sprintf("📊 Заказ #%d", orderID)
+    doc.Caption = fmt.Sprintf("📊 Заказ %s", order.Number())
 
     if _, err := b.bot.Send(doc); err != nil {
         b.logger.Error("Failed to send Excel file", zap.Error(err))
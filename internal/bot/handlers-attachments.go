@@ -0,0 +1,162 @@
+package bot
+
+import (
+	"adtime-bot/internal/storage"
+	"context"
+	"fmt"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// MaxAttachments limits the files kept for an order that is not saved yet
+const MaxAttachments = 10
+
+// StartOrderAttachments lets the customer send files for the order under review
+func (b *Bot) StartOrderAttachments(ctx context.Context, chatID int64) {
+    msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
+        "Отправьте фото или файл с примером или логотипом (до %d штук). Когда закончите, нажмите «✅ Готово».",
+        MaxAttachments))
+    msg.ReplyMarkup = b.CreateAttachmentsKeyboard()
+    b.SendMessage(msg)
+
+    if err := b.state.SetStep(ctx, chatID, StepAttachments); err != nil {
+        b.logger.Error("Failed to set attachments state",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+    }
+}
+
+// HandleAttachOrder lets the customer add files to an order that is already saved
+func (b *Bot) HandleAttachOrder(ctx context.Context, chatID int64, orderRef string) {
+    order, ok := b.getVisibleOrder(ctx, chatID, orderRef)
+    if !ok {
+        return
+    }
+
+    if err := b.state.ResetOrderState(ctx, chatID); err != nil {
+        b.logger.Error("Failed to reset state before attaching files",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+    }
+    if err := b.state.SetAttachOrderID(ctx, chatID, order.ID); err != nil {
+        b.logger.Error("Failed to save order to attach files to",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+        b.SendError(chatID, "Произошла ошибка, попробуйте позже")
+        return
+    }
+
+    msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
+        "Отправьте фото или файлы для заказа %s. Когда закончите, нажмите «✅ Готово».", order.Number()))
+    msg.ReplyMarkup = b.CreateAttachmentsKeyboard()
+    b.SendMessage(msg)
+
+    if err := b.state.SetStep(ctx, chatID, StepAttachments); err != nil {
+        b.logger.Error("Failed to set attachments state",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+    }
+}
+
+// HandleAttachmentsStep handles text while the customer is sending files
+func (b *Bot) HandleAttachmentsStep(ctx context.Context, chatID int64, text string) {
+    if text != "✅ Готово" && text != "Назад" {
+        b.SendError(chatID, "Отправьте фото или файл либо нажмите «✅ Готово»")
+        return
+    }
+
+    state, err := b.state.GetFullState(ctx, chatID)
+    if err == nil && state.AttachOrderID != 0 {
+        b.finishOrderMessage(ctx, chatID, fmt.Sprintf("Готово! Файлы сохранены в заказе %s.",
+            b.orderNumber(ctx, state.AttachOrderID)))
+        return
+    }
+    b.ShowOrderReview(ctx, chatID)
+}
+
+// HandleAttachmentMessage stores a photo or document sent by the user
+func (b *Bot) HandleAttachmentMessage(ctx context.Context, chatID int64, message *tgbotapi.Message) {
+    state, err := b.state.GetFullState(ctx, chatID)
+    if err != nil || state.Step != StepAttachments {
+        b.SendMessage(tgbotapi.NewMessage(chatID,
+            "Чтобы приложить файл, нажмите «📎 Прикрепить файл» при оформлении заказа "+
+                "или используйте /attach <номер_заказа>"))
+        return
+    }
+
+    attachment := attachmentFromMessage(message)
+
+    if state.AttachOrderID == 0 {
+        if len(state.Attachments) >= MaxAttachments {
+            b.SendError(chatID, fmt.Sprintf("К заказу можно приложить не более %d файлов", MaxAttachments))
+            return
+        }
+        count, err := b.state.AddAttachment(ctx, chatID, attachment)
+        if err != nil {
+            b.logger.Error("Failed to keep attachment",
+                zap.Int64("chat_id", chatID),
+                zap.Error(err))
+            b.SendError(chatID, "Ошибка при сохранении файла")
+            return
+        }
+        b.SendMessage(tgbotapi.NewMessage(chatID, fmt.Sprintf("📎 Файл добавлен (%d/%d)", count, MaxAttachments)))
+        return
+    }
+
+    orderAttachment := orderAttachmentFromPending(attachment, chatID)
+    orderAttachment.OrderID = state.AttachOrderID
+    if _, err := b.storage.SaveOrderAttachment(ctx, orderAttachment); err != nil {
+        b.logger.Error("Failed to save order attachment",
+            zap.Int64("order_id", state.AttachOrderID),
+            zap.Error(err))
+        b.SendError(chatID, "Ошибка при сохранении файла")
+        return
+    }
+    number := b.orderNumber(ctx, state.AttachOrderID)
+    b.SendMessage(tgbotapi.NewMessage(chatID, fmt.Sprintf("📎 Файл добавлен к заказу %s", number)))
+
+    if b.IsAdmin(chatID) {
+        return
+    }
+    b.NotifyAdminText(ctx, fmt.Sprintf("📎 Клиент добавил файл к заказу %s", number),
+        b.CreateOrderMessageKeyboard(number))
+    for _, adminID := range b.adminChatIDs() {
+        b.sendAttachments(adminID, []storage.OrderAttachment{orderAttachment})
+    }
+}
+
+// attachmentFromMessage takes the largest photo size or the document of a message
+func attachmentFromMessage(message *tgbotapi.Message) PendingAttachment {
+    if len(message.Photo) > 0 {
+        return PendingAttachment{
+            Kind:    storage.AttachmentPhoto,
+            FileID:  message.Photo[len(message.Photo)-1].FileID,
+            Caption: message.Caption,
+        }
+    }
+    return PendingAttachment{
+        Kind:     storage.AttachmentDocument,
+        FileID:   message.Document.FileID,
+        FileName: message.Document.FileName,
+        Caption:  message.Caption,
+    }
+}
+
+func orderAttachmentFromPending(attachment PendingAttachment, uploadedBy int64) storage.OrderAttachment {
+    return storage.OrderAttachment{
+        Kind:       attachment.Kind,
+        FileID:     attachment.FileID,
+        FileName:   attachment.FileName,
+        Caption:    attachment.Caption,
+        UploadedBy: uploadedBy,
+    }
+}
+
+func orderAttachmentsFromPending(attachments []PendingAttachment, uploadedBy int64) []storage.OrderAttachment {
+    result := make([]storage.OrderAttachment, 0, len(attachments))
+    for _, attachment := range attachments {
+        result = append(result, orderAttachmentFromPending(attachment, uploadedBy))
+    }
+    return result
+}
@@ -0,0 +1,121 @@
+package bot
+
+import (
+	"adtime-bot/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// HandleCustomerCancelOrder starts cancellation of the customer's own order
+func (b *Bot) HandleCustomerCancelOrder(ctx context.Context, chatID int64, orderRef string) {
+    order, err := b.storage.GetOrderByRef(ctx, orderRef)
+    if err != nil || order.UserID != chatID {
+        if err != nil && !errors.Is(err, storage.ErrOrderNotFound) {
+            b.logger.Error("Failed to get order for cancellation",
+                zap.String("order", orderRef),
+                zap.Error(err))
+        }
+        b.SendError(chatID, "Заказ не найден")
+        return
+    }
+
+    if !storage.CanCustomerCancel(order.Status) {
+        b.SendError(chatID, fmt.Sprintf(
+            "Заказ %s уже в статусе «%s» и не может быть отменён. Пожалуйста, свяжитесь с нами.",
+            order.Number(), StatusLabel(order.Status)))
+        return
+    }
+
+    if err := b.state.StartCancellation(ctx, chatID, order.ID); err != nil {
+        b.logger.Error("Failed to start order cancellation",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+        b.SendError(chatID, "Произошла ошибка, попробуйте позже")
+        return
+    }
+
+    msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
+        "Вы отменяете заказ %s.\nПожалуйста, укажите причину отмены:", order.Number()))
+    msg.ReplyMarkup = b.CreateCancelReasonKeyboard()
+    b.SendMessage(msg)
+}
+
+func (b *Bot) HandleCancelReason(ctx context.Context, chatID int64, text string) {
+    state, err := b.state.GetFullState(ctx, chatID)
+    if err != nil || state.CancelOrderID == 0 {
+        b.finishCancellation(ctx, chatID, "❌ Не удалось определить заказ для отмены")
+        return
+    }
+    orderID := state.CancelOrderID
+
+    if text == "Назад" {
+        b.finishCancellation(ctx, chatID, fmt.Sprintf("Заказ %s не отменён.", b.orderNumber(ctx, orderID)))
+        return
+    }
+
+    reason := text
+    if text == "Пропустить" {
+        reason = ""
+    }
+
+    err = b.storage.CancelOrderByCustomer(ctx, orderID, chatID, reason)
+    switch {
+    case errors.Is(err, storage.ErrOrderNotCancellable), errors.Is(err, storage.ErrInvalidStatusTransition):
+        b.finishCancellation(ctx, chatID, fmt.Sprintf(
+            "❌ Заказ %s уже передан в производство и не может быть отменён. Пожалуйста, свяжитесь с нами.",
+            b.orderNumber(ctx, orderID)))
+        return
+    case err != nil:
+        b.logger.Error("Failed to cancel order",
+            zap.Int64("order_id", orderID),
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+        // Leave the reason step, or the next message would be taken as a reason
+        b.finishCancellation(ctx, chatID, "❌ Ошибка при отмене заказа. Пожалуйста, попробуйте позже.")
+        return
+    }
+
+    order, err := b.storage.GetOrderByID(ctx, orderID)
+    if err != nil {
+        b.logger.Error("Failed to get cancelled order",
+            zap.Int64("order_id", orderID),
+            zap.Error(err))
+        b.finishCancellation(ctx, chatID, "✅ Заказ отменён.")
+        return
+    }
+
+    b.finishCancellation(ctx, chatID, fmt.Sprintf("✅ Заказ %s отменён.", order.Number()))
+    b.NotifyAdminText(ctx, FormatCustomerCancellation(*order, reason), nil)
+}
+
+// finishCancellation sends text and returns the customer to where they were
+// before the cancellation, keeping the order they may be putting together
+func (b *Bot) finishCancellation(ctx context.Context, chatID int64, text string) {
+    state, err := b.state.FinishCancellation(ctx, chatID)
+    if err != nil {
+        b.logger.Error("Failed to finish order cancellation",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+    }
+
+    msg := tgbotapi.NewMessage(chatID, text)
+    if state.Step == StepOrderConfirmation {
+        b.SendMessage(msg)
+        b.ShowOrderReview(ctx, chatID)
+        return
+    }
+
+    // An idle customer also sits at the service type step
+    msg.ReplyMarkup = b.CreateMainMenuKeyboard()
+    if state.Step != StepServiceType || len(state.Items) > 0 {
+        if keyboard, ok := b.stepKeyboard(ctx, chatID, state.Step); ok {
+            msg.Text += "\n\nМожете продолжить оформление заказа."
+            msg.ReplyMarkup = keyboard
+        }
+    }
+    b.SendMessage(msg)
+}
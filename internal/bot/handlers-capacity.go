@@ -0,0 +1,268 @@
+package bot
+
+import (
+    "adtime-bot/internal/storage"
+    "context"
+    "errors"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+
+    tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+    "go.uber.org/zap"
+)
+
+// CapacityOverviewDays is how many days /capacity shows the load for
+const CapacityOverviewDays = 7
+
+const capacityUsage = `Использование:
+/capacity — загрузка на ближайшие дни
+/capacity <заказов> <дм²> — лимит на день по умолчанию
+/capacity <ДД.ММ.ГГГГ> <заказов> <дм²> — лимит на конкретную дату
+/capacity <ДД.ММ.ГГГГ> closed — закрыть дату: заказы на неё не принимаются
+/capacity <ДД.ММ.ГГГГ> reset — вернуть дате лимит по умолчанию
+0 означает «без ограничения».`
+
+// CartAreaDM2 is the total leather area of the cart in dm²
+func CartAreaDM2(items []CartItem) float64 {
+    var area float64
+    for _, item := range items {
+        params := item.PriceParams()
+        if params.WidthCM <= 0 || params.HeightCM <= 0 {
+            continue
+        }
+        area += ShapeArea(params) / 100 * float64(params.Quantity)
+    }
+    return area
+}
+
+// OrderAreaDM2 is the total leather area of a saved order in dm²
+func OrderAreaDM2(order storage.Order) float64 {
+    var area float64
+    for _, item := range order.Items {
+        params := PriceParams{
+            WidthCM:        item.WidthCM,
+            HeightCM:       item.HeightCM,
+            Shape:          item.Shape,
+            CornerRadiusCM: item.CornerRadiusCM,
+        }
+        area += ShapeArea(params) / 100 * float64(item.Quantity)
+    }
+    return area
+}
+
+// cartArea returns the area of the customer's cart, or 0 if the state is unavailable
+func (b *Bot) cartArea(ctx context.Context, chatID int64) float64 {
+    state, err := b.state.GetFullState(ctx, chatID)
+    if err != nil {
+        return 0
+    }
+    return CartAreaDM2(state.CartItems())
+}
+
+// dateSelectionKeyboard builds the date keyboard for the customer's cart,
+// marking today and tomorrow when they are full
+func (b *Bot) dateSelectionKeyboard(ctx context.Context, chatID int64) tgbotapi.ReplyKeyboardMarkup {
+    return b.CreateDateSelectionKeyboard(b.dateAvailability(ctx, chatID, b.cartArea(ctx, chatID)))
+}
+
+// dateRefusal explains why an order of areaDM2 cannot be made on date and
+// suggests the nearest free date. It returns no text when the order fits.
+func (b *Bot) dateRefusal(ctx context.Context, chatID int64, date time.Time, areaDM2 float64) (string, *time.Time) {
+    days, err := b.storage.GetDayLoads(ctx, date, date)
+    if err != nil {
+        b.logger.Error("Failed to check day capacity",
+            zap.Int64("chat_id", chatID),
+            zap.Time("date", date),
+            zap.Error(err))
+        return "", nil
+    }
+    if days[0].Fits(areaDM2) {
+        return "", nil
+    }
+
+    text := fmt.Sprintf("К сожалению, на %s производство уже загружено.", date.Format("02.01.2006"))
+    if days[0].Capacity.Closed {
+        text = fmt.Sprintf("К сожалению, на %s заказы не принимаются.", date.Format("02.01.2006"))
+    }
+
+    day, found, err := b.storage.NearestFreeDay(ctx, date.AddDate(0, 0, 1), areaDM2)
+    if err != nil {
+        b.logger.Error("Failed to find free day",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+    }
+    if !found {
+        return text + "\nПожалуйста, выберите другую дату.", nil
+    }
+    return text + fmt.Sprintf("\nБлижайшая свободная дата: %s", day.Format("02.01.2006")), &day
+}
+
+// dateAvailability checks today and tomorrow for an order of areaDM2 and,
+// if either is full, finds the nearest free date
+func (b *Bot) dateAvailability(ctx context.Context, chatID int64, areaDM2 float64) DateAvailability {
+    var availability DateAvailability
+
+    today := time.Now()
+    days, err := b.storage.GetDayLoads(ctx, today, today.AddDate(0, 0, 1))
+    if err != nil {
+        b.logger.Error("Failed to get day loads",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+        return availability
+    }
+    availability.TodayFull = !days[0].Fits(areaDM2)
+    availability.TomorrowFull = !days[1].Fits(areaDM2)
+
+    if availability.TodayFull || availability.TomorrowFull {
+        if day, found, err := b.storage.NearestFreeDay(ctx, today, areaDM2); err == nil && found {
+            availability.Suggested = &day
+        }
+    }
+    return availability
+}
+
+// checkDateCapacity reports whether the workshop can take the customer's cart
+// on date. If not, it tells the customer and offers the nearest free date
+// after it. The order is let through when the load cannot be checked.
+func (b *Bot) checkDateCapacity(ctx context.Context, chatID int64, date time.Time) bool {
+    area := b.cartArea(ctx, chatID)
+    text, suggested := b.dateRefusal(ctx, chatID, date, area)
+    if text == "" {
+        return true
+    }
+
+    availability := b.dateAvailability(ctx, chatID, area)
+    availability.Suggested = suggested
+
+    msg := tgbotapi.NewMessage(chatID, text)
+    msg.ReplyMarkup = b.CreateDateSelectionKeyboard(availability)
+    b.SendMessage(msg)
+
+    if err := b.state.SetStep(ctx, chatID, StepDateSelection); err != nil {
+        b.logger.Error("Failed to set date selection state",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+    }
+    return false
+}
+
+// HandleCapacityCommand shows or changes the production capacity
+func (b *Bot) HandleCapacityCommand(ctx context.Context, chatID int64, args []string) {
+    switch len(args) {
+    case 0:
+        b.showCapacity(ctx, chatID)
+        return
+    case 2:
+        if args[1] == "closed" {
+            day, err := parseCapacityDate(args[0])
+            if err != nil {
+                b.SendError(chatID, err.Error())
+                return
+            }
+            if err := b.storage.SetDayCapacity(ctx, day, storage.Capacity{Closed: true}, chatID); err != nil {
+                b.logger.Error("Failed to close day",
+                    zap.Time("day", day),
+                    zap.Error(err))
+                b.SendError(chatID, "Ошибка при сохранении лимита")
+                return
+            }
+            b.SendMessage(tgbotapi.NewMessage(chatID, fmt.Sprintf(
+                "✅ %s закрыто: новые заказы на эту дату не принимаются. Уже оформленные заказы остаются в работе.",
+                day.Format("02.01.2006"))))
+            return
+        }
+        if args[1] == "reset" {
+            day, err := parseCapacityDate(args[0])
+            if err != nil {
+                b.SendError(chatID, err.Error())
+                return
+            }
+            if err := b.storage.ResetDayCapacity(ctx, day); err != nil {
+                b.logger.Error("Failed to reset day capacity",
+                    zap.Time("day", day),
+                    zap.Error(err))
+                b.SendError(chatID, "Ошибка при сбросе лимита")
+                return
+            }
+            b.SendMessage(tgbotapi.NewMessage(chatID, fmt.Sprintf(
+                "✅ Для %s снова действует лимит по умолчанию", day.Format("02.01.2006"))))
+            return
+        }
+
+        capacity, err := ParseCapacity(args[0], args[1])
+        if err != nil {
+            b.SendError(chatID, err.Error())
+            return
+        }
+        if err := b.storage.SetDefaultCapacity(ctx, capacity, chatID); err != nil {
+            b.logger.Error("Failed to set production capacity", zap.Error(err))
+            b.SendError(chatID, "Ошибка при сохранении лимита")
+            return
+        }
+        b.SendMessage(tgbotapi.NewMessage(chatID, "✅ Лимит на день по умолчанию: "+FormatCapacity(capacity)))
+    case 3:
+        day, err := parseCapacityDate(args[0])
+        if err != nil {
+            b.SendError(chatID, err.Error())
+            return
+        }
+        capacity, err := ParseCapacity(args[1], args[2])
+        if err != nil {
+            b.SendError(chatID, err.Error())
+            return
+        }
+        if err := b.storage.SetDayCapacity(ctx, day, capacity, chatID); err != nil {
+            b.logger.Error("Failed to set day capacity",
+                zap.Time("day", day),
+                zap.Error(err))
+            b.SendError(chatID, "Ошибка при сохранении лимита")
+            return
+        }
+        b.SendMessage(tgbotapi.NewMessage(chatID, fmt.Sprintf(
+            "✅ Лимит на %s: %s", day.Format("02.01.2006"), FormatCapacity(capacity))))
+    default:
+        b.SendError(chatID, capacityUsage)
+    }
+}
+
+func (b *Bot) showCapacity(ctx context.Context, chatID int64) {
+    capacity, err := b.storage.GetDefaultCapacity(ctx)
+    if err != nil {
+        b.logger.Error("Failed to get production capacity", zap.Error(err))
+        b.SendError(chatID, "Ошибка при получении лимитов")
+        return
+    }
+
+    today := time.Now()
+    days, err := b.storage.GetDayLoads(ctx, today, today.AddDate(0, 0, CapacityOverviewDays-1))
+    if err != nil {
+        b.logger.Error("Failed to get day loads", zap.Error(err))
+        b.SendError(chatID, "Ошибка при получении загрузки")
+        return
+    }
+
+    b.SendMessage(tgbotapi.NewMessage(chatID, FormatCapacityOverview(capacity, days)+"\n\n"+capacityUsage))
+}
+
+// ParseCapacity reads the order and area limits of /capacity
+func ParseCapacity(ordersText, areaText string) (storage.Capacity, error) {
+    orders, err := strconv.Atoi(ordersText)
+    if err != nil || orders < 0 {
+        return storage.Capacity{}, errors.New("Количество заказов должно быть целым числом не меньше 0")
+    }
+    area, err := strconv.ParseFloat(strings.Replace(areaText, ",", ".", 1), 64)
+    if err != nil || area < 0 {
+        return storage.Capacity{}, errors.New("Площадь должна быть числом не меньше 0")
+    }
+    return storage.Capacity{MaxOrders: orders, MaxAreaDM2: area}, nil
+}
+
+func parseCapacityDate(text string) (time.Time, error) {
+    day, err := time.Parse("02.01.2006", text)
+    if err != nil {
+        return time.Time{}, errors.New("Неверный формат даты. Используйте ДД.ММ.ГГГГ")
+    }
+    return day, nil
+}
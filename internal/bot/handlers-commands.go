@@ -69,11 +69,24 @@ func (b *Bot) ShowMainMenu(ctx context.Context, chatID int64, phone string) {
         formattedPhone = FormatPhoneNumber(phone)
     }
 
+    // Returning customers see their loyalty discount
+    loyalty := ""
+    if stats, err := b.storage.GetCustomerStats(ctx, chatID); err == nil {
+        if status := FormatLoyaltyStatus(NewLoyaltyConfig(b.cfg), stats); status != "" {
+            loyalty = "\n" + status + "\n\n"
+        }
+    } else {
+        b.logger.Error("Failed to get customer stats",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+    }
+
     msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
         "🏠 *Главное меню*\n\n"+
             "Ваш контактный номер: %s\n"+
+            "%s"+
             "Выберите действие:",
-        formattedPhone))
+        formattedPhone, loyalty))
     
     msg.ReplyMarkup = b.CreateMainMenuKeyboard()
     msg.ParseMode = "Markdown"
@@ -120,22 +133,8 @@ func (b *Bot) HandleError(ctx context.Context, chatID int64, errorMsg string) {
 		step = ""
 	}
 
-	var keyboard tgbotapi.ReplyKeyboardMarkup
-
-	switch step {
-	case StepDimensions:
-		keyboard = b.CreateDimensionsKeyboard()
-	case StepDateSelection:
-		keyboard = b.CreateDateSelectionKeyboard()
-	case StepServiceType:
-		keyboard = b.CreateServiceTypeKeyboard()
-	case StepManualDateInput:
-		keyboard = tgbotapi.NewReplyKeyboard(
-			tgbotapi.NewKeyboardButtonRow(
-				tgbotapi.NewKeyboardButton("Назад"),
-			),
-		)
-	default:
+	keyboard, ok := b.stepKeyboard(ctx, chatID, step)
+	if !ok {
 		keyboard = tgbotapi.NewReplyKeyboard(
 			tgbotapi.NewKeyboardButtonRow(
 				tgbotapi.NewKeyboardButton("/start"),
@@ -148,6 +147,31 @@ func (b *Bot) HandleError(ctx context.Context, chatID int64, errorMsg string) {
 	b.SendMessage(msg)
 }
 
+// stepKeyboard returns the keyboard of an order step, if the step has one
+func (b *Bot) stepKeyboard(ctx context.Context, chatID int64, step string) (tgbotapi.ReplyKeyboardMarkup, bool) {
+	switch step {
+	case StepShape:
+		return b.CreateShapeKeyboard(), true
+	case StepDimensions:
+		shape := ""
+		if state, err := b.state.GetFullState(ctx, chatID); err == nil {
+			shape = state.Shape
+		}
+		return b.CreateDimensionsKeyboard(shape), true
+	case StepDateSelection:
+		return b.dateSelectionKeyboard(ctx, chatID), true
+	case StepServiceType:
+		return b.CreateServiceTypeKeyboard(), true
+	case StepManualDateInput:
+		return tgbotapi.NewReplyKeyboard(
+			tgbotapi.NewKeyboardButtonRow(
+				tgbotapi.NewKeyboardButton("Назад"),
+			),
+		), true
+	}
+	return tgbotapi.ReplyKeyboardMarkup{}, false
+}
+
 func (b *Bot) HandleCancel(ctx context.Context, chatID int64) {
 	// Get current step to determine where to return
 	currentStep, err := b.state.GetStep(ctx, chatID)
@@ -158,19 +182,64 @@ func (b *Bot) HandleCancel(ctx context.Context, chatID int64) {
 		currentStep = "" // Default to start if cannot get step
 	}
 
+	// Abandoning an edit started from the order review returns to the review
+	if currentStep != StepOrderConfirmation {
+		if state, err := b.state.GetFullState(ctx, chatID); err == nil && state.Editing && state.Date != "" {
+			b.ShowOrderReview(ctx, chatID)
+			return
+		}
+	}
+
+	// Abandoning an extra cart position drops it and returns to the review
+	switch currentStep {
+	case StepServiceType, CustomTextureInput, StepShape, StepDimensions, StepQuantity:
+		if state, err := b.state.GetFullState(ctx, chatID); err == nil && len(state.Items) > 0 {
+			if err := b.state.RemoveCurrentItem(ctx, chatID); err == nil {
+				b.ShowOrderReview(ctx, chatID)
+				return
+			}
+		}
+	}
+
 	var msg tgbotapi.MessageConfig
 	var keyboard any
 
 	switch currentStep {
+	case StepDeliveryAddress:
+		msg = tgbotapi.NewMessage(chatID, "❌ Ввод адреса отменен. Как вы хотите получить заказ?")
+		keyboard = b.CreateFulfilmentKeyboard()
+		b.state.SetStep(ctx, chatID, StepFulfilment)
+
+	case StepFulfilment:
+		msg = tgbotapi.NewMessage(chatID, "❌ Выбор способа получения отменен. Как вам удобно предоставить контактные данные?")
+		keyboard = b.CreatePhoneInputKeyboard()
+		b.state.SetStep(ctx, chatID, StepContactMethod)
+
 	case StepDateSelection, StepManualDateInput, StepDateConfirmation:
+		// Return to quantity input
+		msg = tgbotapi.NewMessage(chatID, "❌ Выбор даты отменен. Введите количество снова:")
+		keyboard = b.CreateQuantityKeyboard()
+		b.state.SetStep(ctx, chatID, StepQuantity)
+
+	case StepQuantity:
 		// Return to dimensions input
-		msg = tgbotapi.NewMessage(chatID, "❌ Выбор даты отменен. Введите размеры снова:")
-		keyboard = b.CreateDimensionsKeyboard()
+		shape := ""
+		if state, err := b.state.GetFullState(ctx, chatID); err == nil {
+			shape = state.Shape
+		}
+		msg = tgbotapi.NewMessage(chatID, "❌ Ввод количества отменен. "+DimensionsPrompt(shape))
+		keyboard = b.CreateDimensionsKeyboard(shape)
 		b.state.SetStep(ctx, chatID, StepDimensions)
 
 	case StepDimensions:
+		// Return to shape selection
+		msg = tgbotapi.NewMessage(chatID, "❌ Ввод размеров отменен. Выберите форму изделия:")
+		keyboard = b.CreateShapeKeyboard()
+		b.state.SetStep(ctx, chatID, StepShape)
+
+	case StepShape:
 		// Return to service type selection
-		msg = tgbotapi.NewMessage(chatID, "❌ Ввод размеров отменен. Выберите тип услуги:")
+		msg = tgbotapi.NewMessage(chatID, "❌ Выбор формы отменен. Выберите тип услуги:")
 		keyboard = b.CreateServiceTypeKeyboard()
 		b.state.SetStep(ctx, chatID, StepServiceType)
 
@@ -214,6 +283,15 @@ func (b *Bot) HandleUnknownCommand(ctx context.Context, chatID int64) {
 func (b *Bot) HandleHelp(ctx context.Context, chatID int64) {
 	helpText := `Доступные команды:
 	/start - Начать работу с ботом
+	/new_order - Оформить новый заказ
+	/order_history - Мои заказы
+	/edit_order <номер> - Изменить новый заказ
+	/cancel_order <номер> - Отменить заказ до начала производства
+	/order <номер> - Заказ и переписка по нему
+	/ask <номер> - Задать вопрос по заказу
+	/attach <номер> - Приложить фото или файл к заказу
+	/promo <код> - Применить промокод к заказу
+	/my_queue - Очередь заказов (для мастеров)
 	/help - Показать эту справку
 
 	Если у вас возникли проблемы, свяжитесь с поддержкой.`
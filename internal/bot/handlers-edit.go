@@ -0,0 +1,398 @@
+package bot
+
+import (
+	"adtime-bot/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// HandleEditOrder starts editing a saved order by its customer or by an admin
+func (b *Bot) HandleEditOrder(ctx context.Context, chatID int64, orderRef string) {
+    order, ok := b.getVisibleOrder(ctx, chatID, orderRef)
+    if !ok || !b.checkEditable(chatID, order) {
+        return
+    }
+
+    if err := b.state.SetEditOrder(ctx, chatID, order.ID, "", 0); err != nil {
+        b.logger.Error("Failed to save order to edit",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+        b.SendError(chatID, "Произошла ошибка, попробуйте позже")
+        return
+    }
+
+    b.askEditField(ctx, chatID, order)
+}
+
+func (b *Bot) HandleEditOrderField(ctx context.Context, chatID int64, text string) {
+    state, err := b.state.GetFullState(ctx, chatID)
+    if err != nil || state.EditOrderID == 0 {
+        b.finishOrderEdit(ctx, chatID, "Не удалось определить заказ для изменения")
+        return
+    }
+
+    var (
+        field    string
+        prompt   string
+        keyboard tgbotapi.ReplyKeyboardMarkup
+    )
+
+    choice, item := parseEditChoice(text)
+
+    switch choice {
+    case "📏 Размер":
+        // The size is entered in the format of the item's shape
+        shape := ""
+        if order, err := b.storage.GetOrderByID(ctx, state.EditOrderID); err == nil && item < len(order.Items) {
+            shape = order.Items[item].Shape
+        }
+        field = EditFieldSize
+        prompt = "Новый размер. " + DimensionsPrompt(shape)
+        keyboard = b.CreateDimensionsKeyboard(shape)
+    case "🧵 Текстура":
+        textures, err := b.storage.GetAvailableTextures(ctx)
+        if err != nil || len(textures) == 0 {
+            b.logger.Error("Failed to get textures for order edit",
+                zap.Int64("chat_id", chatID),
+                zap.Error(err))
+            b.SendError(chatID, "Не удалось получить список текстур")
+            return
+        }
+        field = EditFieldTexture
+        prompt = "Выберите новую текстуру:"
+        keyboard = b.CreateTextureNamesKeyboard(textures)
+    case "🗓 Дата":
+        field = EditFieldDate
+        prompt = "Введите новую дату выполнения в формате ДД.ММ.ГГГГ"
+        keyboard = tgbotapi.NewReplyKeyboard(
+            tgbotapi.NewKeyboardButtonRow(
+                tgbotapi.NewKeyboardButton("Назад"),
+            ),
+        )
+    case "❌ Отмена":
+        b.finishOrderEdit(ctx, chatID, fmt.Sprintf("Изменение заказа %s отменено", b.orderNumber(ctx, state.EditOrderID)))
+        return
+    default:
+        b.SendError(chatID, "Пожалуйста, выберите, что изменить, с помощью кнопок")
+        return
+    }
+
+    if err := b.state.SetEditOrder(ctx, chatID, state.EditOrderID, field, item); err != nil {
+        b.logger.Error("Failed to save edit field",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+        b.SendError(chatID, "Произошла ошибка, попробуйте позже")
+        return
+    }
+
+    msg := tgbotapi.NewMessage(chatID, prompt)
+    msg.ReplyMarkup = keyboard
+    b.SendMessage(msg)
+
+    if err := b.state.SetStep(ctx, chatID, StepEditOrderValue); err != nil {
+        b.logger.Error("Failed to set edit value state",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+    }
+}
+
+func (b *Bot) HandleEditOrderValue(ctx context.Context, chatID int64, text string) {
+    state, err := b.state.GetFullState(ctx, chatID)
+    if err != nil || state.EditOrderID == 0 {
+        b.finishOrderEdit(ctx, chatID, "Не удалось определить заказ для изменения")
+        return
+    }
+
+    // Re-read the order: its status may have changed while the customer was typing
+    order, ok := b.getEditableOrder(ctx, chatID, state.EditOrderID)
+    if !ok {
+        b.finishOrderEdit(ctx, chatID, "")
+        return
+    }
+
+    if text == "Назад" {
+        b.askEditField(ctx, chatID, order)
+        return
+    }
+
+    if state.EditItem < 0 || state.EditItem >= len(order.Items) {
+        b.askEditField(ctx, chatID, order)
+        return
+    }
+    item := &order.Items[state.EditItem]
+
+    change := storage.OrderChange{
+        OrderID:   order.ID,
+        ChangedBy: chatID,
+        Field:     state.EditField,
+        OldPrice:  order.Price,
+    }
+
+    switch state.EditField {
+    case EditFieldSize:
+        width, height, radius, err := ParseShapeDimensions(item.Shape, text)
+        if err != nil {
+            b.SendError(chatID, err.Error())
+            return
+        }
+        change.OldValue = FormatItemSize(item.Shape, item.WidthCM, item.HeightCM, item.CornerRadiusCM)
+        change.NewValue = FormatItemSize(item.Shape, width, height, radius)
+        item.WidthCM, item.HeightCM, item.CornerRadiusCM = width, height, radius
+
+    case EditFieldTexture:
+        texture, err := b.storage.GetTextureByName(ctx, text)
+        if err != nil {
+            b.SendError(chatID, "Пожалуйста, выберите текстуру из списка")
+            return
+        }
+        change.OldValue = item.TextureName
+        change.NewValue = texture.Name
+        item.TextureID = texture.ID
+        item.TextureName = texture.Name
+
+    case EditFieldDate:
+        dueDate, err := ParseDueDate(text)
+        if err != nil {
+            b.SendError(chatID, err.Error())
+            return
+        }
+        change.OldValue = FormatDueDate(order.DueDate)
+        change.NewValue = FormatDueDate(&dueDate)
+        if change.OldValue != change.NewValue {
+            if refusal, _ := b.dateRefusal(ctx, chatID, dueDate, OrderAreaDM2(*order)); refusal != "" {
+                b.SendError(chatID, refusal)
+                return
+            }
+        }
+        order.DueDate = &dueDate
+        // A date inside the rush window takes the surcharge, a later one drops it
+        order.Rush = b.IsRushDate(change.NewValue)
+
+    default:
+        b.askEditField(ctx, chatID, order)
+        return
+    }
+
+    if change.OldValue == change.NewValue {
+        b.SendError(chatID, "Новое значение совпадает с текущим")
+        return
+    }
+
+    // A new date may add or drop the rush surcharge of every item; other
+    // fields only change the edited item
+    if state.EditField == EditFieldDate {
+        for i := range order.Items {
+            if !b.repriceOrderItem(ctx, chatID, order, i) {
+                return
+            }
+        }
+    } else {
+        if !b.repriceOrderItem(ctx, chatID, order, state.EditItem) {
+            return
+        }
+
+        if len(order.Items) > 1 {
+            prefix := fmt.Sprintf("позиция %d: ", state.EditItem+1)
+            change.OldValue = prefix + change.OldValue
+            change.NewValue = prefix + change.NewValue
+        }
+    }
+    b.priceOrder(order)
+    change.NewPrice = order.Price
+
+    err = b.storage.UpdateOrderDetails(ctx, *order, b.editableStatuses(chatID), change)
+    if errors.Is(err, storage.ErrOrderNotEditable) {
+        b.finishOrderEdit(ctx, chatID, fmt.Sprintf("Заказ %s больше нельзя изменить", order.Number()))
+        return
+    }
+    // Other orders took the date since it was checked
+    if errors.Is(err, storage.ErrDayFull) {
+        b.SendError(chatID, fmt.Sprintf("К сожалению, на %s производство уже загружено. Пожалуйста, выберите другую дату.",
+            FormatDueDate(order.DueDate)))
+        return
+    }
+    if err != nil {
+        b.logger.Error("Failed to update order",
+            zap.Int64("order_id", order.ID),
+            zap.Any("change", change),
+            zap.Error(err))
+        b.SendError(chatID, "Ошибка при сохранении изменений")
+        return
+    }
+
+    b.finishOrderEdit(ctx, chatID, "")
+    b.notifyOrderChanged(ctx, chatID, *order, change)
+}
+
+// repriceOrderItem prices an item of a saved order anew with current
+// texture prices. The customer is told when it fails.
+func (b *Bot) repriceOrderItem(ctx context.Context, chatID int64, order *storage.Order, index int) bool {
+    item := &order.Items[index]
+    texture, err := b.storage.GetTextureByID(ctx, item.TextureID)
+    if err != nil {
+        b.logger.Error("Failed to get item texture",
+            zap.Int64("order_id", order.ID),
+            zap.Error(err))
+        b.SendError(chatID, "Не удалось получить текстуру заказа")
+        return false
+    }
+
+    params := PriceParams{
+        WidthCM:        item.WidthCM,
+        HeightCM:       item.HeightCM,
+        Quantity:       item.Quantity,
+        Shape:          item.Shape,
+        CornerRadiusCM: item.CornerRadiusCM,
+        Rush:           order.Rush,
+        // The order keeps the loyalty tier it was placed with
+        LoyaltyRate: order.LoyaltyRate,
+    }
+    params = b.orderPromoAllowance(ctx, *order, index).Params(params, texture.Name)
+    priceDetails, err := b.CalculateOrderPrice(ctx, params, texture)
+    if err != nil {
+        b.logger.Error("Failed to recalculate order price",
+            zap.Int64("order_id", order.ID),
+            zap.Error(err))
+        b.SendError(chatID, "Ошибка при расчете цены")
+        return false
+    }
+    ApplyPriceDetails(item, priceDetails)
+    return true
+}
+
+// getEditableOrder loads an order and checks that chatID may still edit it.
+// The customer is told why when editing is not possible.
+func (b *Bot) getEditableOrder(ctx context.Context, chatID, orderID int64) (*storage.Order, bool) {
+    order, err := b.storage.GetOrderByID(ctx, orderID)
+    if err != nil || (order.UserID != chatID && !b.IsAdmin(chatID)) {
+        if err != nil && !errors.Is(err, storage.ErrOrderNotFound) {
+            b.logger.Error("Failed to get order for edit",
+                zap.Int64("order_id", orderID),
+                zap.Error(err))
+        }
+        b.SendError(chatID, "Заказ не найден")
+        return nil, false
+    }
+
+    if !b.checkEditable(chatID, order) {
+        return nil, false
+    }
+    return order, true
+}
+
+// checkEditable tells the user when the order's status no longer allows edits
+func (b *Bot) checkEditable(chatID int64, order *storage.Order) bool {
+    if !slices.Contains(b.editableStatuses(chatID), order.Status) {
+        b.SendError(chatID, fmt.Sprintf(
+            "Заказ %s в статусе «%s» больше нельзя изменить. Пожалуйста, свяжитесь с нами.",
+            order.Number(), StatusLabel(order.Status)))
+        return false
+    }
+    return true
+}
+
+func (b *Bot) editableStatuses(chatID int64) []string {
+    if b.IsAdmin(chatID) {
+        return storage.AdminEditableStatuses
+    }
+    return storage.CustomerEditableStatuses
+}
+
+func (b *Bot) askEditField(ctx context.Context, chatID int64, order *storage.Order) {
+    msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
+        "✏️ Изменение заказа %s\n\n"+
+            "%s\n"+
+            "🗓 Срок выполнения: %s\n"+
+            "💰 Цена: %.2f ₽\n\n"+
+            "Что вы хотите изменить?",
+        order.Number(),
+        FormatOrderItems(*order),
+        FormatDueDate(order.DueDate),
+        order.Price,
+    ))
+    msg.ReplyMarkup = b.CreateEditOrderKeyboard(len(order.Items))
+    b.SendMessage(msg)
+
+    if err := b.state.SetStep(ctx, chatID, StepEditOrderField); err != nil {
+        b.logger.Error("Failed to set edit field state",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+    }
+}
+
+// finishOrderEdit leaves the edit flow, optionally explaining why
+func (b *Bot) finishOrderEdit(ctx context.Context, chatID int64, text string) {
+    if err := b.state.ResetOrderState(ctx, chatID); err != nil {
+        b.logger.Error("Failed to reset state after order edit",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+    }
+
+    if text == "" {
+        return
+    }
+
+    msg := tgbotapi.NewMessage(chatID, text)
+    if b.IsAdmin(chatID) {
+        msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+    } else {
+        msg.ReplyMarkup = b.CreateMainMenuKeyboard()
+    }
+    b.SendMessage(msg)
+}
+
+// notifyOrderChanged tells the customer and the admins what changed and how the price moved
+func (b *Bot) notifyOrderChanged(ctx context.Context, editorID int64, order storage.Order, change storage.OrderChange) {
+    text := fmt.Sprintf(
+        "✏️ Заказ %s изменён\n\n"+
+            "%s: %s → %s\n"+
+            "💰 Цена: %s",
+        order.Number(),
+        editFieldLabels[change.Field],
+        change.OldValue, change.NewValue,
+        FormatPriceChange(change.OldPrice, change.NewPrice),
+    )
+
+    customerMsg := tgbotapi.NewMessage(order.UserID, text)
+    customerMsg.ReplyMarkup = b.CreateMainMenuKeyboard()
+    if _, err := b.bot.Send(customerMsg); err != nil {
+        b.logger.Warn("Failed to notify customer about order change",
+            zap.Int64("user_id", order.UserID),
+            zap.Error(err))
+    }
+
+    editor := "клиентом"
+    if editorID != order.UserID {
+        editor = fmt.Sprintf("администратором (id%d)", editorID)
+    }
+    b.NotifyAdminText(ctx, fmt.Sprintf("%s\n\nИзменено %s в %s",
+        text, editor, time.Now().Format("02.01.2006 15:04")), nil)
+}
+
+// parseEditChoice splits an edit button label like "📏 Размер #2" into
+// the action and the zero-based item index
+func parseEditChoice(text string) (string, int) {
+    choice, number, found := strings.Cut(text, " #")
+    if !found {
+        return text, 0
+    }
+    n, err := strconv.Atoi(number)
+    if err != nil || n < 1 {
+        return text, 0
+    }
+    return choice, n - 1
+}
+
+var editFieldLabels = map[string]string{
+    EditFieldSize:    "📏 Размер",
+    EditFieldTexture: "🧵 Текстура",
+    EditFieldDate:    "🗓 Срок выполнения",
+}
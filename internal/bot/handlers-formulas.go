@@ -0,0 +1,236 @@
+package bot
+
+import (
+    "adtime-bot/internal/storage"
+    "context"
+    "errors"
+    "fmt"
+    "strconv"
+    "strings"
+
+    tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+    "go.uber.org/zap"
+)
+
+const formulaUsage = `Использование:
+/formula — список формул
+/formula add <текстура> = <формула>; <параметр>=<значение>; ... — новая формула (выключена)
+/formula test <id> <ширина> <высота> [кол-во] — сравнить со стандартной ценой
+/formula on <id> — включить формулу для её текстуры
+/formula off <id> — выключить формулу
+Пример: /formula add Кожа Люкс = max(area * price * k, 500); k=2.4`
+
+// HandleFormulaCommand lists, adds, tests and switches price formulas
+func (b *Bot) HandleFormulaCommand(ctx context.Context, chatID int64, args []string) {
+    if len(args) == 0 {
+        b.showPriceFormulas(ctx, chatID)
+        return
+    }
+
+    switch {
+    case args[0] == "add" && len(args) >= 2:
+        b.addPriceFormula(ctx, chatID, strings.Join(args[1:], " "))
+
+    case args[0] == "test" && (len(args) == 4 || len(args) == 5):
+        params := PriceParams{Quantity: 1}
+        var err error
+        if params.WidthCM, err = strconv.Atoi(args[2]); err != nil {
+            b.SendError(chatID, "Неверная ширина")
+            return
+        }
+        if params.HeightCM, err = strconv.Atoi(args[3]); err != nil {
+            b.SendError(chatID, "Неверная высота")
+            return
+        }
+        if len(args) == 5 {
+            if params.Quantity, err = strconv.Atoi(args[4]); err != nil {
+                b.SendError(chatID, "Неверное количество")
+                return
+            }
+        }
+        b.testPriceFormula(ctx, chatID, args[1], params)
+
+    case (args[0] == "on" || args[0] == "off") && len(args) == 2:
+        b.switchPriceFormula(ctx, chatID, args[1], args[0] == "on")
+
+    default:
+        b.SendError(chatID, formulaUsage)
+    }
+}
+
+func (b *Bot) showPriceFormulas(ctx context.Context, chatID int64) {
+    formulas, err := b.storage.GetPriceFormulas(ctx)
+    if err != nil {
+        b.logger.Error("Failed to get price formulas", zap.Error(err))
+        b.SendError(chatID, "Ошибка при получении формул")
+        return
+    }
+
+    var sb strings.Builder
+    sb.WriteString("🧮 Формулы цены:\n")
+    if len(formulas) == 0 {
+        sb.WriteString("пока нет ни одной\n")
+    }
+    for _, f := range formulas {
+        sb.WriteString(FormatPriceFormula(f) + "\n")
+    }
+    sb.WriteString("\n" + FormatFormulaVariables() + "\n\n" + formulaUsage)
+    b.SendMessage(tgbotapi.NewMessage(chatID, sb.String()))
+}
+
+func (b *Bot) addPriceFormula(ctx context.Context, chatID int64, definition string) {
+    f, err := ParseFormulaDefinition(definition)
+    if err != nil {
+        b.SendError(chatID, "Не удалось разобрать формулу: "+err.Error()+"\n\n"+formulaUsage)
+        return
+    }
+    if _, err := ParsePriceFormula(f.Formula, f.Parameters); err != nil {
+        b.SendError(chatID, "Ошибка в формуле: "+err.Error())
+        return
+    }
+    if _, err := b.storage.GetTextureByName(ctx, f.ServiceType); err != nil {
+        b.SendError(chatID, fmt.Sprintf("Текстура «%s» не найдена", f.ServiceType))
+        return
+    }
+
+    f.CreatedBy = &chatID
+    if err := b.storage.SavePriceFormula(ctx, f); err != nil {
+        b.logger.Error("Failed to save price formula",
+            zap.String("service", f.ServiceType),
+            zap.Error(err))
+        b.SendError(chatID, "Ошибка при сохранении формулы")
+        return
+    }
+    b.SendMessage(tgbotapi.NewMessage(chatID, fmt.Sprintf(
+        "✅ Формула #%d сохранена. Проверьте её: /formula test %d <ширина> <высота>, затем включите: /formula on %d",
+        f.ID, f.ID, f.ID)))
+}
+
+// ParseFormulaDefinition splits "<service> = <formula>; k=2.4; m=1" into a
+// price formula; decimal commas are accepted in the parameter values
+func ParseFormulaDefinition(definition string) (*storage.PriceFormula, error) {
+    parts := strings.Split(definition, ";")
+    service, source, ok := strings.Cut(parts[0], "=")
+    service, source = strings.TrimSpace(service), strings.TrimSpace(source)
+    if !ok || service == "" || source == "" {
+        return nil, errors.New("ожидается <текстура> = <формула>")
+    }
+
+    parameters := storage.FormulaParameters{}
+    for _, part := range parts[1:] {
+        if strings.TrimSpace(part) == "" {
+            continue
+        }
+        name, value, ok := strings.Cut(part, "=")
+        name = strings.TrimSpace(name)
+        if !ok || name == "" {
+            return nil, fmt.Errorf("параметр %q: ожидается имя=значение", strings.TrimSpace(part))
+        }
+        number, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(value), ",", "."), 64)
+        if err != nil {
+            return nil, fmt.Errorf("параметр %s: неверное значение", name)
+        }
+        parameters[name] = number
+    }
+
+    return &storage.PriceFormula{
+        ServiceType: service,
+        Formula:     source,
+        Parameters:  parameters,
+    }, nil
+}
+
+// loadPriceFormula finds the formula an admin referred to by ID, reporting
+// any problem to them
+func (b *Bot) loadPriceFormula(ctx context.Context, chatID int64, ref string) (*storage.PriceFormula, bool) {
+    id, err := strconv.ParseInt(strings.TrimPrefix(ref, "#"), 10, 64)
+    if err != nil {
+        b.SendError(chatID, "Неверный номер формулы")
+        return nil, false
+    }
+    f, err := b.storage.GetPriceFormula(ctx, id)
+    if err != nil {
+        if errors.Is(err, storage.ErrPriceFormulaNotFound) {
+            b.SendError(chatID, "Формула не найдена")
+            return nil, false
+        }
+        b.logger.Error("Failed to get price formula",
+            zap.Int64("formula_id", id),
+            zap.Error(err))
+        b.SendError(chatID, "Ошибка при получении формулы")
+        return nil, false
+    }
+    return f, true
+}
+
+func (b *Bot) testPriceFormula(ctx context.Context, chatID int64, ref string, params PriceParams) {
+    f, ok := b.loadPriceFormula(ctx, chatID, ref)
+    if !ok {
+        return
+    }
+    expr, err := ParsePriceFormula(f.Formula, f.Parameters)
+    if err != nil {
+        b.SendError(chatID, "Ошибка в формуле: "+err.Error())
+        return
+    }
+    texture, err := b.storage.GetTextureByName(ctx, f.ServiceType)
+    if err != nil {
+        b.SendError(chatID, fmt.Sprintf("Текстура «%s» не найдена", f.ServiceType))
+        return
+    }
+
+    cfg := NewPricingConfig(texture.PricePerDM2, b.cfg)
+    standard, err := CalculateItemPrice(params, cfg)
+    if err != nil {
+        b.SendError(chatID, "Не удалось рассчитать цену: "+err.Error())
+        return
+    }
+    cfg.Formula, cfg.FormulaParameters = expr, f.Parameters
+    withFormula, err := CalculateItemPrice(params, cfg)
+    if err != nil {
+        b.SendError(chatID, "Формула не сработала: "+err.Error())
+        return
+    }
+
+    b.SendMessage(tgbotapi.NewMessage(chatID, fmt.Sprintf(
+        "🧮 Формула #%d, %s, %d×%d см × %d шт.\n"+
+            "Себестоимость: %s ₽\n"+
+            "Стандартная цена: %s ₽ (к оплате %s ₽, прибыль %s ₽)\n"+
+            "По формуле: %s ₽ (к оплате %s ₽, прибыль %s ₽)",
+        f.ID, f.ServiceType, params.WidthCM, params.HeightCM, params.Quantity,
+        standard.TotalCost,
+        standard.GrossPrice, standard.FinalPrice, standard.Profit,
+        withFormula.GrossPrice, withFormula.FinalPrice, withFormula.Profit)))
+}
+
+func (b *Bot) switchPriceFormula(ctx context.Context, chatID int64, ref string, active bool) {
+    f, ok := b.loadPriceFormula(ctx, chatID, ref)
+    if !ok {
+        return
+    }
+
+    if !active {
+        if err := b.storage.DeactivatePriceFormula(ctx, f.ID); err != nil {
+            b.logger.Error("Failed to deactivate price formula",
+                zap.Int64("formula_id", f.ID),
+                zap.Error(err))
+            b.SendError(chatID, "Ошибка при выключении формулы")
+            return
+        }
+        b.SendMessage(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Формула #%d выключена, для «%s» действует стандартная цена", f.ID, f.ServiceType)))
+        return
+    }
+
+    if _, err := ParsePriceFormula(f.Formula, f.Parameters); err != nil {
+        b.SendError(chatID, "Ошибка в формуле: "+err.Error())
+        return
+    }
+    if err := b.storage.ActivatePriceFormula(ctx, f.ID); err != nil {
+        b.logger.Error("Failed to activate price formula",
+            zap.Int64("formula_id", f.ID),
+            zap.Error(err))
+        b.SendError(chatID, "Ошибка при включении формулы")
+        return
+    }
+    b.SendMessage(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Формула #%d включена для «%s»", f.ID, f.ServiceType)))
+}
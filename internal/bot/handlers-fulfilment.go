@@ -0,0 +1,137 @@
+package bot
+
+import (
+	"adtime-bot/internal/storage"
+	"adtime-bot/pkg/money"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// Buttons of the fulfilment step; pickup buttons end with the point's name
+const (
+	PickupButtonPrefix = "🏬 Самовывоз: "
+	CourierButton      = "🚚 Доставка курьером"
+)
+
+// MinAddressLength rejects addresses too short to be delivered to
+const MinAddressLength = 10
+
+// StartFulfilment asks how the order should reach the customer
+func (b *Bot) StartFulfilment(ctx context.Context, chatID int64) {
+    msg := tgbotapi.NewMessage(chatID, "Как вы хотите получить заказ?\n\n"+b.courierFeeNote())
+    msg.ReplyMarkup = b.CreateFulfilmentKeyboard()
+    b.SendMessage(msg)
+
+    if err := b.state.SetStep(ctx, chatID, StepFulfilment); err != nil {
+        b.logger.Error("Failed to set fulfilment state",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+    }
+}
+
+func (b *Bot) HandleFulfilment(ctx context.Context, chatID int64, text string) {
+    switch {
+    case text == "Назад":
+        b.HandleCancel(ctx, chatID)
+
+    case text == CourierButton:
+        msg := tgbotapi.NewMessage(chatID,
+            "Отправьте геопозицию или напишите адрес доставки: город, улица, дом, квартира.")
+        msg.ReplyMarkup = b.CreateDeliveryAddressKeyboard()
+        b.SendMessage(msg)
+
+        if err := b.state.SetStep(ctx, chatID, StepDeliveryAddress); err != nil {
+            b.logger.Error("Failed to set delivery address state",
+                zap.Int64("chat_id", chatID),
+                zap.Error(err))
+        }
+
+    case strings.HasPrefix(text, PickupButtonPrefix):
+        point := strings.TrimPrefix(text, PickupButtonPrefix)
+        if !b.isPickupPoint(point) {
+            b.SendError(chatID, "Пожалуйста, выберите пункт самовывоза из списка")
+            return
+        }
+        b.saveFulfilment(ctx, chatID, storage.Fulfilment{
+            Method:  storage.FulfilmentPickup,
+            Address: point,
+        })
+
+    default:
+        b.SendError(chatID, "Пожалуйста, выберите один из вариантов")
+    }
+}
+
+func (b *Bot) HandleDeliveryAddress(ctx context.Context, chatID int64, text string) {
+    if text == "Назад" {
+        b.HandleCancel(ctx, chatID)
+        return
+    }
+
+    address := strings.TrimSpace(text)
+    if utf8.RuneCountInString(address) < MinAddressLength {
+        b.SendError(chatID, "Пожалуйста, укажите полный адрес: город, улица, дом, квартира")
+        return
+    }
+
+    b.saveFulfilment(ctx, chatID, storage.Fulfilment{
+        Method:  storage.FulfilmentCourier,
+        Address: address,
+    })
+}
+
+// HandleLocationMessage takes a shared location as the delivery address
+func (b *Bot) HandleLocationMessage(ctx context.Context, chatID int64, location *tgbotapi.Location) {
+    step, err := b.state.GetStep(ctx, chatID)
+    if err != nil || step != StepDeliveryAddress {
+        b.HandleDefault(ctx, chatID)
+        return
+    }
+
+    latitude, longitude := location.Latitude, location.Longitude
+    b.saveFulfilment(ctx, chatID, storage.Fulfilment{
+        Method:    storage.FulfilmentCourier,
+        Address:   "по геопозиции",
+        Latitude:  &latitude,
+        Longitude: &longitude,
+    })
+}
+
+func (b *Bot) saveFulfilment(ctx context.Context, chatID int64, fulfilment storage.Fulfilment) {
+    if err := b.state.SetFulfilment(ctx, chatID, fulfilment); err != nil {
+        b.logger.Error("Failed to save fulfilment",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+        b.SendError(chatID, "Ошибка при сохранении способа получения")
+        return
+    }
+    b.ShowOrderReview(ctx, chatID)
+}
+
+func (b *Bot) isPickupPoint(point string) bool {
+    return slices.Contains(b.cfg.Delivery.PickupPoints, point)
+}
+
+// DeliveryFee returns the fee for the fulfilment method of an order whose items cost itemsTotal
+func (b *Bot) DeliveryFee(method string, itemsTotal money.Kopecks) money.Kopecks {
+    return NewDeliveryConfig(b.cfg).Fee(method, itemsTotal)
+}
+
+// courierFeeNote describes the courier fees for the fulfilment prompt
+func (b *Bot) courierFeeNote() string {
+    cfg := NewDeliveryConfig(b.cfg)
+    if cfg.CourierFee == 0 {
+        return "Самовывоз и доставка курьером — бесплатно."
+    }
+    note := fmt.Sprintf("Самовывоз — бесплатно, доставка курьером — %.0f ₽", cfg.CourierFee.Rubles())
+    if cfg.FreeCourierFrom > 0 {
+        note += fmt.Sprintf(" (бесплатно для заказов от %.0f ₽)", cfg.FreeCourierFrom.Rubles())
+    }
+    return note + "."
+}
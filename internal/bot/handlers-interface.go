@@ -23,11 +23,14 @@ type BotHandler interface {
 	HandleServiceSelection(ctx context.Context, chatID int64, text string)
 	HandleServiceType(ctx context.Context, chatID int64, text string)
 	HandleDimensionsSize(ctx context.Context, chatID int64, text string)
+	HandleQuantity(ctx context.Context, chatID int64, text string)
 	HandleDateSelection(ctx context.Context, chatID int64, text string)
 	HandleManualDateInput(ctx context.Context, chatID int64, text string)
 	HandleDateConfirmation(ctx context.Context, chatID int64, text string)
 	HandleContactMethod(ctx context.Context, chatID int64, text string)
 	HandlePhoneNumber(ctx context.Context, chatID int64, text string)
+	HandleOrderConfirmation(ctx context.Context, chatID int64, text string)
+	HandleCancelReason(ctx context.Context, chatID int64, text string)
 	
 	// Texture handlers
 	HandleTextureSelection(ctx context.Context, callback *tgbotapi.CallbackQuery)
@@ -42,8 +45,8 @@ type BotHandler interface {
 	// Utility methods
 	CreateOrder(ctx context.Context, chatID int64, phone string) (int64, error)
 	GetOrderTexture(ctx context.Context, chatID int64, state UserState) (*storage.Texture, error)
-	CalculateOrderPrice(width, height int, texture *storage.Texture) map[string]float64
-	SendUserConfirmation(ctx context.Context, chatID, orderID int64, phone string, width, height int, priceDetails map[string]float64)
+	CalculateOrderPrice(ctx context.Context, params PriceParams, texture *storage.Texture) (PriceBreakdown, error)
+	SendUserConfirmation(ctx context.Context, order storage.Order)
 	IsAdmin(chatID int64) bool
 }
 
@@ -0,0 +1,210 @@
+package bot
+
+import (
+	"adtime-bot/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// HandleOrderMessage starts a message about an order: a question from its
+// customer or a reply from an admin
+func (b *Bot) HandleOrderMessage(ctx context.Context, chatID int64, orderRef string) {
+    order, ok := b.getVisibleOrder(ctx, chatID, orderRef)
+    if !ok {
+        return
+    }
+
+    if err := b.state.SetMessageOrderID(ctx, chatID, order.ID); err != nil {
+        b.logger.Error("Failed to save order to message about",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+        b.SendError(chatID, "Произошла ошибка, попробуйте позже")
+        return
+    }
+
+    prompt := fmt.Sprintf("Напишите ваш вопрос по заказу %s:", order.Number())
+    if b.IsAdmin(chatID) {
+        prompt = fmt.Sprintf("Напишите ответ клиенту по заказу %s:", order.Number())
+    }
+    msg := tgbotapi.NewMessage(chatID, prompt)
+    msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
+        tgbotapi.NewKeyboardButtonRow(
+            tgbotapi.NewKeyboardButton("Назад"),
+        ),
+    )
+    b.SendMessage(msg)
+
+    if err := b.state.SetStep(ctx, chatID, StepOrderMessage); err != nil {
+        b.logger.Error("Failed to set order message state",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+    }
+}
+
+func (b *Bot) HandleOrderMessageInput(ctx context.Context, chatID int64, text string) {
+    state, err := b.state.GetFullState(ctx, chatID)
+    if err != nil || state.MessageOrderID == 0 {
+        b.finishOrderMessage(ctx, chatID, "❌ Не удалось определить заказ")
+        return
+    }
+    orderID := state.MessageOrderID
+
+    if text == "Назад" {
+        b.finishOrderMessage(ctx, chatID, fmt.Sprintf("Сообщение по заказу %s не отправлено.", b.orderNumber(ctx, orderID)))
+        return
+    }
+
+    text = strings.TrimSpace(text)
+    if text == "" {
+        b.SendError(chatID, "Пожалуйста, напишите текст сообщения")
+        return
+    }
+
+    order, ok := b.getVisibleOrder(ctx, chatID, strconv.FormatInt(orderID, 10))
+    if !ok {
+        b.finishOrderMessage(ctx, chatID, "")
+        return
+    }
+
+    fromAdmin := b.IsAdmin(chatID)
+    if _, err := b.storage.SaveOrderMessage(ctx, storage.OrderMessage{
+        OrderID:   order.ID,
+        SenderID:  chatID,
+        FromAdmin: fromAdmin,
+        Text:      text,
+    }); err != nil {
+        b.logger.Error("Failed to save order message",
+            zap.Int64("order_id", order.ID),
+            zap.Error(err))
+        b.SendError(chatID, "Ошибка при отправке сообщения")
+        return
+    }
+
+    if fromAdmin {
+        reply := tgbotapi.NewMessage(order.UserID, fmt.Sprintf(
+            "💬 Ответ менеджера по заказу %s:\n\n%s", order.Number(), text))
+        reply.ReplyMarkup = b.CreateOrderMessageKeyboard(order.Number())
+        if _, err := b.bot.Send(reply); err != nil {
+            b.logger.Warn("Failed to forward reply to customer",
+                zap.Int64("user_id", order.UserID),
+                zap.Error(err))
+            b.finishOrderMessage(ctx, chatID, "⚠️ Ответ сохранён, но доставить его клиенту не удалось")
+            return
+        }
+        b.finishOrderMessage(ctx, chatID, fmt.Sprintf("✅ Ответ по заказу %s отправлен клиенту", order.Number()))
+        return
+    }
+
+    b.NotifyAdminText(ctx, fmt.Sprintf(
+        "💬 Вопрос по заказу %s\nКлиент: %s\n\n%s",
+        order.Number(), FormatPhoneNumber(order.Contact), text),
+        b.CreateOrderMessageKeyboard(order.Number()))
+    b.finishOrderMessage(ctx, chatID, fmt.Sprintf(
+        "✅ Ваше сообщение по заказу %s отправлено. Ответ придёт в этот чат.", order.Number()))
+}
+
+// HandleOrderView shows an order card with its status timeline and conversation
+func (b *Bot) HandleOrderView(ctx context.Context, chatID int64, orderRef string) {
+    order, ok := b.getVisibleOrder(ctx, chatID, orderRef)
+    if !ok {
+        return
+    }
+
+    messages, err := b.storage.GetOrderMessages(ctx, order.ID)
+    if err != nil {
+        b.logger.Error("Failed to get order messages",
+            zap.Int64("order_id", order.ID),
+            zap.Error(err))
+        b.SendError(chatID, "Ошибка при получении переписки")
+        return
+    }
+
+    var (
+        summary  string
+        keyboard tgbotapi.InlineKeyboardMarkup
+    )
+    if b.IsAdmin(chatID) {
+        summary = FormatOrderNotification(*order)
+        keyboard = b.CreateAdminOrderKeyboard(*order)
+    } else {
+        summary = fmt.Sprintf(
+            "🆔 Заказ %s\n📏 Позиции:\n%s\n🗓 Срок: %s\n📦 %s\n💵 %.2f ₽\n🔄 %s",
+            order.Number(),
+            FormatOrderItems(*order),
+            FormatDueDate(order.DueDate),
+            order.Fulfilment,
+            order.Price,
+            StatusLabel(order.Status),
+        )
+        keyboard = b.CreateOrderCardKeyboard(*order)
+    }
+
+    history, err := b.storage.GetOrderStatusHistory(ctx, order.ID)
+    if err != nil {
+        b.logger.Error("Failed to get order status history",
+            zap.Int64("order_id", order.ID),
+            zap.Error(err))
+    }
+    if len(history) > 0 {
+        summary += "\n\n" + FormatStatusTimeline(history)
+    }
+
+    attachments, err := b.storage.GetOrderAttachments(ctx, order.ID)
+    if err != nil {
+        b.logger.Error("Failed to get order attachments",
+            zap.Int64("order_id", order.ID),
+            zap.Error(err))
+    }
+    if len(attachments) > 0 {
+        summary += fmt.Sprintf("\n📎 Файлов: %d", len(attachments))
+    }
+
+    msg := tgbotapi.NewMessage(chatID, summary+"\n\n"+FormatOrderThread(messages))
+    msg.ReplyMarkup = keyboard
+    b.SendMessage(msg)
+
+    b.sendAttachments(chatID, attachments)
+}
+
+// getVisibleOrder loads an order its customer or an admin may look at. The
+// order is referred to by its public code or its ID.
+func (b *Bot) getVisibleOrder(ctx context.Context, chatID int64, orderRef string) (*storage.Order, bool) {
+    order, err := b.storage.GetOrderByRef(ctx, orderRef)
+    if err != nil || (order.UserID != chatID && !b.IsAdmin(chatID)) {
+        if err != nil && !errors.Is(err, storage.ErrOrderNotFound) {
+            b.logger.Error("Failed to get order",
+                zap.String("order", orderRef),
+                zap.Error(err))
+        }
+        b.SendError(chatID, "Заказ не найден")
+        return nil, false
+    }
+    return order, true
+}
+
+// finishOrderMessage leaves the message input, optionally with a note
+func (b *Bot) finishOrderMessage(ctx context.Context, chatID int64, text string) {
+    if err := b.state.ResetOrderState(ctx, chatID); err != nil {
+        b.logger.Error("Failed to reset state after order message",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+    }
+
+    if text == "" {
+        return
+    }
+
+    msg := tgbotapi.NewMessage(chatID, text)
+    if b.IsAdmin(chatID) {
+        msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+    } else {
+        msg.ReplyMarkup = b.CreateMainMenuKeyboard()
+    }
+    b.SendMessage(msg)
+}
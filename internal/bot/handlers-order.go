@@ -2,8 +2,12 @@ package bot
 
 import (
 	"adtime-bot/internal/storage"
+	"adtime-bot/pkg/formula"
+	"adtime-bot/pkg/money"
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,62 +22,50 @@ func (b *Bot) CreateOrder(ctx context.Context, chatID int64, phone string) (int6
         return 0, fmt.Errorf("failed to get order state: %w", err)
     }
 
-	width, height, err := b.state.GetDimensions(ctx, chatID)
+    // The execution date is mandatory - the workshop plans around it
+    dueDate, err := time.Parse("02.01.2006", state.Date)
     if err != nil {
-        return 0, fmt.Errorf("failed to get dimensions: %w", err)
+        return 0, fmt.Errorf("invalid due date %q: %w", state.Date, err)
     }
 
-	// Validate dimensions
-    if width <= 0 || height <= 0 {
-        return 0, fmt.Errorf("invalid dimensions: width=%d height=%d", width, height)
+    // The promo code is checked once more when the order is saved
+    var promo *storage.PromoCode
+    if state.PromoCode != "" {
+        promo, err = b.storage.CheckPromoCode(ctx, state.PromoCode, chatID, time.Now())
+        if err != nil {
+            return 0, fmt.Errorf("promo code %s: %w", state.PromoCode, err)
+        }
     }
 
-    // Use the helper function to get texture
-    texture, err := b.GetOrderTexture(ctx, chatID, state)
+    loyaltyRate := b.loyaltyRate(ctx, chatID)
+    items, err := b.PriceCart(ctx, state, promo, loyaltyRate)
     if err != nil {
-        b.logger.Error("Failed to get texture for order",
+        b.logger.Error("Failed to price cart",
             zap.Int64("chat_id", chatID),
+            zap.Any("items", state.CartItems()),
             zap.Error(err))
-        return 0, fmt.Errorf("texture selection required: %w", err)
-    }
-
-    // Add debug logging to verify prices
-    b.logger.Debug("Using texture for pricing",
-        zap.String("texture_id", texture.ID),
-        zap.String("texture_name", texture.Name),
-        zap.Float64("price_per_dm2", texture.PricePerDM2))
-
-	priceDetails, err := b.CalculateOrderPrice(width, height, texture)
-    if err != nil {
-        b.logger.Error("Failed to calculate price",
-            zap.Int("width", width),
-            zap.Int("height", height),
-            zap.Any("texture", texture),
-            zap.Error(err))
-        return 0, fmt.Errorf("price calculation failed: %w", err)
+        return 0, err
     }
 
     order := storage.Order{
         UserID:      chatID,
-        WidthCM:     width,
-        HeightCM:    height,
-        TextureID:   texture.ID,
-        TextureName: texture.Name,
-        Price:       priceDetails["final_price"],
-        LeatherCost: priceDetails["leather_cost"],
-        ProcessCost: priceDetails["processing_cost"],
-        TotalCost:   priceDetails["total_cost"],
-        Commission:  priceDetails["commission"],
-        Tax:         priceDetails["tax"],
-        NetRevenue:  priceDetails["net_revenue"],
-        Profit:      priceDetails["profit"],
         Contact:     phone,
-        Status:      "new",
+        Status:      storage.StatusNew,
+        DueDate:     &dueDate,
+        Rush:        state.Rush,
+        LoyaltyRate: loyaltyRate,
         CreatedAt:   time.Now(),
+        Items:       items,
+        Attachments: orderAttachmentsFromPending(state.Attachments, chatID),
+        Fulfilment:  state.Fulfilment,
+    }
+    b.priceOrder(&order)
+    // A code that gave nothing, e.g. for other textures, is not used up
+    if promo != nil && order.PromoDiscount > 0 {
+        order.PromoCode = &promo.Code
     }
 
-	orderID, err := b.storage.SaveOrder(ctx, order)
-    if err != nil {
+    if err := b.storage.SaveOrder(ctx, &order); err != nil {
         b.logger.Error("Failed to save order",
             zap.Int64("chat_id", chatID),
             zap.Any("order", order),
@@ -81,6 +73,15 @@ func (b *Bot) CreateOrder(ctx context.Context, chatID int64, phone string) (int6
         return 0, fmt.Errorf("failed to save order: %w", err)
     }
 
+    b.announceOrder(ctx, order)
+
+    return order.ID, nil
+}
+
+// announceOrder confirms a saved order to the customer and notifies admins and the channel
+func (b *Bot) announceOrder(ctx context.Context, order storage.Order) {
+    chatID := order.UserID
+
     // After order creation
     b.logger.Info("Testing admin notification",
         zap.Int64("admin_chat", b.cfg.Admin.ChatID),
@@ -101,19 +102,14 @@ func (b *Bot) CreateOrder(ctx context.Context, chatID int64, phone string) (int6
     } else {
         username = fmt.Sprintf("id%d", chatID)
     }
-    
-	// Update the order with the actual ID before notifications
-    order.ID = orderID
 
     // Send notifications with the updated order
-    b.SendUserConfirmation(ctx, chatID, orderID, phone, width, height, priceDetails)
+    b.SendUserConfirmation(ctx, order)
     
     go func() {
         b.NotifyAdmin(ctx, order)
         b.NotifyNewOrderToChannel(ctx, order, username)
     }()
-
-    return orderID, nil
 }
 
 func (b *Bot) HandleMainMenu(ctx context.Context, chatID int64) {
@@ -128,98 +124,377 @@ func (b *Bot) HandleMainMenu(ctx context.Context, chatID int64) {
 }
 
 
+// OrderHistoryPageSize is the number of orders on one page of the history
+const OrderHistoryPageSize = 5
+
 func (b *Bot) HandleOrderHistory(ctx context.Context, chatID int64) {
-    orders, err := b.storage.GetUserOrders(ctx, chatID)
+    b.showOrderHistoryPage(ctx, chatID, 0, 0, 0)
+}
+
+// HandleOrderHistoryPage moves between history pages in place. The callback
+// data is "first", "older:<id>" or "newer:<id>".
+func (b *Bot) HandleOrderHistoryPage(ctx context.Context, chatID int64, messageID int, data string) {
+    var beforeID, afterID int64
+    if direction, idStr, ok := strings.Cut(data, ":"); ok {
+        id, err := strconv.ParseInt(idStr, 10, 64)
+        if err != nil {
+            b.SendError(chatID, "Неверный формат команды")
+            return
+        }
+        if direction == "newer" {
+            afterID = id
+        } else {
+            beforeID = id
+        }
+    }
+    b.showOrderHistoryPage(ctx, chatID, messageID, beforeID, afterID)
+}
+
+// showOrderHistoryPage sends a page of the customer's orders, or replaces
+// the message with the given ID when paging
+func (b *Bot) showOrderHistoryPage(ctx context.Context, chatID int64, messageID int, beforeID, afterID int64) {
+    page, err := b.storage.GetUserOrdersPage(ctx, chatID, beforeID, afterID, OrderHistoryPageSize)
     if err != nil {
         b.logger.Error("Failed to get user orders", zap.Error(err))
         b.SendError(chatID, "Ошибка при получении истории заказов")
         return
     }
 
-    if len(orders) == 0 {
+    if len(page.Orders) == 0 && beforeID == 0 && afterID == 0 {
         b.SendMessage(tgbotapi.NewMessage(chatID, "У вас пока нет заказов"))
         return
     }
 
     var sb strings.Builder
     sb.WriteString("📋 Ваши заказы:\n\n")
-    for _, order := range orders {
+    for _, order := range page.Orders {
         sb.WriteString(fmt.Sprintf(
-            "🆔 #%d\n📅 %s\n📏 %dx%d см\n💵 %.2f ₽\n🔄 %s\n\n",
-            order.ID,
+            "🆔 %s от %s — %s\n📏 %s\n💵 %.2f ₽\n\n",
+            order.Number(),
             order.CreatedAt.Format("02.01.2006"),
-            order.WidthCM,
-            order.HeightCM,
+            StatusLabel(order.Status),
+            FormatOrderHeadline(order),
             order.Price,
-            order.Status,
         ))
     }
+    sb.WriteString("Нажмите на заказ, чтобы открыть подробности.")
+    keyboard := b.CreateOrderHistoryKeyboard(page)
+
+    if messageID != 0 {
+        edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, sb.String(), keyboard)
+        if _, err := b.bot.Send(edit); err == nil {
+            return
+        }
+        // The message may be too old to edit; send the page anew
+        b.logger.Warn("Failed to update order history message",
+            zap.Int64("chat_id", chatID),
+            zap.Int("message_id", messageID))
+    }
 
     msg := tgbotapi.NewMessage(chatID, sb.String())
+    msg.ReplyMarkup = keyboard
     b.SendMessage(msg)
 }
 
+// HandleRepeatOrder starts a new order with the items of an old one at current prices
+func (b *Bot) HandleRepeatOrder(ctx context.Context, chatID int64, orderRef string) {
+    order, err := b.storage.GetOrderByRef(ctx, orderRef)
+    if err != nil || order.UserID != chatID {
+        if err != nil && !errors.Is(err, storage.ErrOrderNotFound) {
+            b.logger.Error("Failed to get order to repeat",
+                zap.String("order", orderRef),
+                zap.Error(err))
+        }
+        b.SendError(chatID, "Заказ не найден")
+        return
+    }
+
+    var (
+        cart        []CartItem
+        newPrice    money.Kopecks
+        loyaltyRate = b.loyaltyRate(ctx, chatID)
+    )
+    for _, item := range order.Items {
+        texture, err := b.storage.GetTextureByID(ctx, item.TextureID)
+        if err != nil {
+            b.logger.Warn("Texture of repeated order is unavailable",
+                zap.Int64("order_id", order.ID),
+                zap.String("texture_id", item.TextureID),
+                zap.Error(err))
+            b.SendError(chatID, fmt.Sprintf(
+                "Текстура «%s» больше недоступна. Пожалуйста, оформите новый заказ.", item.TextureName))
+            return
+        }
+
+        cartItem := CartItem{
+            Service:        texture.Name,
+            TextureID:      texture.ID,
+            WidthCM:        item.WidthCM,
+            HeightCM:       item.HeightCM,
+            Shape:          item.Shape,
+            CornerRadiusCM: item.CornerRadiusCM,
+            Quantity:       item.Quantity,
+        }
+        params := cartItem.PriceParams()
+        params.LoyaltyRate = loyaltyRate
+        priceDetails, err := b.CalculateOrderPrice(ctx, params, texture)
+        if err != nil {
+            b.logger.Error("Failed to reprice repeated order",
+                zap.Int64("order_id", order.ID),
+                zap.Error(err))
+            b.SendError(chatID, "Ошибка при расчете цены")
+            return
+        }
+        newPrice += priceDetails.FinalPrice
+        cart = append(cart, cartItem)
+    }
+
+    if len(cart) == 0 {
+        b.SendError(chatID, "В заказе нет позиций для повтора")
+        return
+    }
+
+    if err := b.state.PrefillOrder(ctx, chatID, cart, order.Contact); err != nil {
+        b.logger.Error("Failed to prefill repeated order",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+        b.SendError(chatID, "Произошла ошибка, попробуйте позже")
+        return
+    }
+
+    // Delivery, rush and the promo code are chosen anew for the repeated
+    // order, so only the items are compared
+    newPrice += b.orderAdjustment(newPrice)
+    oldPrice := money.FromRubles(order.RegularItemsTotal())
+    oldPrice += b.orderAdjustment(oldPrice)
+    priceLine := fmt.Sprintf("💰 Цена: %s ₽", newPrice)
+    if newPrice != oldPrice {
+        priceLine = "💰 Цена изменилась: " + FormatPriceChange(oldPrice.Rubles(), newPrice.Rubles())
+    }
+
+    msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
+        "🔁 Повторяем заказ %s\n\n%s\n%s\n\nКогда вам удобно выполнить заказ?",
+        order.Number(), FormatOrderItems(*order), priceLine))
+    msg.ReplyMarkup = b.dateSelectionKeyboard(ctx, chatID)
+    b.SendMessage(msg)
+
+    if err := b.state.SetStep(ctx, chatID, StepDateSelection); err != nil {
+        b.logger.Error("Failed to set date selection state",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+    }
+}
+
 func (b *Bot) GetOrderTexture(ctx context.Context, chatID int64, state UserState) (*storage.Texture, error) {
+    return b.getItemTexture(ctx, state.CurrentItem())
+}
+
+func (b *Bot) getItemTexture(ctx context.Context, item CartItem) (*storage.Texture, error) {
     // First try by texture ID
-    if state.TextureID != "" {
-        texture, err := b.storage.GetTextureByID(ctx, state.TextureID)
+    if item.TextureID != "" {
+        texture, err := b.storage.GetTextureByID(ctx, item.TextureID)
         if err == nil {
             return texture, nil
         }
         b.logger.Warn("Failed to get texture by ID, falling back to service name",
-            zap.String("texture_id", state.TextureID),
+            zap.String("texture_id", item.TextureID),
             zap.Error(err))
     }
 
     // Fall back to service name if texture ID not set
-    if state.Service != "" {
-        texture, err := b.storage.GetTextureByName(ctx, state.Service)
+    if item.Service != "" {
+        texture, err := b.storage.GetTextureByName(ctx, item.Service)
         if err == nil {
             return texture, nil
         }
         b.logger.Warn("Failed to get texture by service name",
-            zap.String("service", state.Service),
+            zap.String("service", item.Service),
             zap.Error(err))
     }
 
     return nil, fmt.Errorf("no texture selected")
 }
 
-func (b *Bot) CalculateOrderPrice(width, height int, texture *storage.Texture) (map[string]float64, error) {
-    pricingConfig := PricingConfig{
-        LeatherPricePerDM2:    texture.PricePerDM2,
-        ProcessingCostPerDM2:  b.cfg.Pricing.ProcessingCostPerDM2,
-        PaymentCommissionRate: b.cfg.Pricing.PaymentCommissionRate,
-        SalesTaxRate:          b.cfg.Pricing.SalesTaxRate,
-        MarkupMultiplier:      b.cfg.Pricing.MarkupMultiplier,
+// orderNumber returns the public code of a saved order for messages that
+// only know its ID
+func (b *Bot) orderNumber(ctx context.Context, orderID int64) string {
+    order, err := b.storage.GetOrderByID(ctx, orderID)
+    if err != nil {
+        b.logger.Warn("Failed to get order code",
+            zap.Int64("order_id", orderID),
+            zap.Error(err))
+        return fmt.Sprintf("#%d", orderID)
+    }
+    return order.Number()
+}
+
+// PriceCart prices every cart position with the current texture prices, the
+// customer's loyalty discount and the promo code, if any
+func (b *Bot) PriceCart(ctx context.Context, state UserState, promo *storage.PromoCode, loyaltyRate float64) ([]storage.OrderItem, error) {
+    cart := state.CartItems()
+    items := make([]storage.OrderItem, 0, len(cart))
+    allowance := NewPromoAllowance(promo)
+
+    for i, cartItem := range cart {
+        if cartItem.WidthCM <= 0 || cartItem.HeightCM <= 0 {
+            return nil, fmt.Errorf("item %d: invalid dimensions: width=%d height=%d",
+                i+1, cartItem.WidthCM, cartItem.HeightCM)
+        }
+
+        texture, err := b.getItemTexture(ctx, cartItem)
+        if err != nil {
+            return nil, fmt.Errorf("item %d: texture selection required: %w", i+1, err)
+        }
+
+        params := allowance.Params(state.ItemPriceParams(cartItem), texture.Name)
+        params.LoyaltyRate = loyaltyRate
+        priceDetails, err := b.CalculateOrderPrice(ctx, params, texture)
+        if err != nil {
+            return nil, fmt.Errorf("item %d: price calculation failed: %w", i+1, err)
+        }
+        allowance.Use(priceDetails.PromoDiscount)
+
+        item := storage.OrderItem{
+            Position:       i + 1,
+            TextureID:      texture.ID,
+            TextureName:    texture.Name,
+            WidthCM:        cartItem.WidthCM,
+            HeightCM:       cartItem.HeightCM,
+            Shape:          params.Shape,
+            CornerRadiusCM: params.CornerRadiusCM,
+            Quantity:       params.Quantity,
+        }
+        ApplyPriceDetails(&item, priceDetails)
+        items = append(items, item)
+    }
+
+    return items, nil
+}
+
+// priceOrder sets the totals of an order from its priced items, with the
+// minimum order price, rounding and delivery
+func (b *Bot) priceOrder(order *storage.Order) {
+    ApplyOrderPricing(order, NewPricingConfig(b.cfg.Pricing.LeatherPricePerDM2, b.cfg), NewDeliveryConfig(b.cfg))
+}
+
+// orderAdjustment is what the minimum order price and rounding add to the
+// items total of an order being put together
+func (b *Bot) orderAdjustment(itemsTotal money.Kopecks) money.Kopecks {
+    return NewPricingConfig(b.cfg.Pricing.LeatherPricePerDM2, b.cfg).OrderAdjustment(itemsTotal)
+}
+
+// loyaltyRate is the loyalty discount the customer's next order gets; none
+// if their order history cannot be read
+func (b *Bot) loyaltyRate(ctx context.Context, chatID int64) float64 {
+    stats, err := b.storage.GetCustomerStats(ctx, chatID)
+    if err != nil {
+        b.logger.Error("Failed to get customer stats",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+        return 0
+    }
+    tier, _ := NewLoyaltyConfig(b.cfg).Tier(stats)
+    return tier.Rate
+}
+
+// CalculateOrderPrice prices an item with the active formula of its service
+// (the texture name). Without one, or if the formula fails, the standard
+// pricing is used.
+func (b *Bot) CalculateOrderPrice(ctx context.Context, params PriceParams, texture *storage.Texture) (PriceBreakdown, error) {
+    cfg := NewPricingConfig(texture.PricePerDM2, b.cfg)
+
+    if priceFormula, expr := b.activePriceFormula(ctx, texture.Name); expr != nil {
+        withFormula := cfg
+        withFormula.Formula = expr
+        withFormula.FormulaParameters = priceFormula.Parameters
+
+        priceDetails, err := CalculateItemPrice(params, withFormula)
+        if err == nil {
+            return priceDetails, nil
+        }
+        b.logger.Warn("Price formula failed, using standard pricing",
+            zap.Int64("formula_id", priceFormula.ID),
+            zap.String("service", texture.Name),
+            zap.Error(err))
     }
 
-    return CalculatePrice(width, height, pricingConfig)
+    return CalculateItemPrice(params, cfg)
 }
 
-func (b *Bot) SendUserConfirmation(ctx context.Context, chatID, orderID int64, phone string, width, height int, priceDetails map[string]float64) {
+// activePriceFormula returns the formula in use for a service, or nils when
+// the service has none or it cannot be used
+func (b *Bot) activePriceFormula(ctx context.Context, serviceType string) (*storage.PriceFormula, *formula.Expr) {
+    priceFormula, err := b.storage.GetActivePriceFormula(ctx, serviceType)
+    if err != nil {
+        if !errors.Is(err, storage.ErrPriceFormulaNotFound) {
+            b.logger.Error("Failed to get price formula",
+                zap.String("service", serviceType),
+                zap.Error(err))
+        }
+        return nil, nil
+    }
+
+    expr, err := ParsePriceFormula(priceFormula.Formula, priceFormula.Parameters)
+    if err != nil {
+        b.logger.Error("Invalid active price formula",
+            zap.Int64("formula_id", priceFormula.ID),
+            zap.Error(err))
+        return nil, nil
+    }
+    return priceFormula, expr
+}
+
+// ApplyPriceDetails copies a CalculatePrice result into the item's price
+// columns, which are DECIMAL roubles
+func ApplyPriceDetails(item *storage.OrderItem, priceDetails PriceBreakdown) {
+    item.Price = priceDetails.FinalPrice.Rubles()
+    item.Discount = priceDetails.Discount.Rubles()
+    item.LoyaltyDiscount = priceDetails.LoyaltyDiscount.Rubles()
+    item.PromoDiscount = priceDetails.PromoDiscount.Rubles()
+    item.RushSurcharge = priceDetails.RushSurcharge.Rubles()
+    item.LeatherCost = priceDetails.LeatherCost.Rubles()
+    item.ProcessCost = priceDetails.ProcessingCost.Rubles()
+    item.TotalCost = priceDetails.TotalCost.Rubles()
+    item.Commission = priceDetails.Commission.Rubles()
+    item.Tax = priceDetails.Tax.Rubles()
+    item.NetRevenue = priceDetails.NetRevenue.Rubles()
+    item.Profit = priceDetails.Profit.Rubles()
+}
+
+func (b *Bot) SendUserConfirmation(ctx context.Context, order storage.Order) {
+    chatID := order.UserID
+
     // Сохраняем согласие и телефон пользователя
-    err := b.storage.SaveUserAgreement(ctx, chatID, phone)
+    err := b.storage.SaveUserAgreement(ctx, chatID, order.Contact)
     if err != nil {
         b.logger.Error("Failed to save user agreement", zap.Error(err))
     }
     
     // This will be the ONLY confirmation message for user
     msgText := fmt.Sprintf(
-        "✅ Ваш заказ #%d оформлен!\n"+
-            "Размер: %d×%d см\n"+
+        "✅ Ваш заказ %s оформлен!\n"+
+            "%s\n"+
+            "Срок выполнения: %s%s\n"+
+            "Получение: %s\n"+
+            "%s%s%s%s"+
             "Итоговая цена: %.2f ₽\n\n"+
             "С вами свяжутся в ближайшее время.",
-        orderID,
-        width, height,
-        priceDetails["final_price"],
+        order.Number(),
+        FormatOrderItems(order),
+        FormatDueDate(order.DueDate), FormatRushMark(order.Rush),
+        order.Fulfilment,
+        FormatOrderLoyalty(order),
+        FormatOrderPromo(order),
+        FormatPriceAdjustment(order.PriceAdjustment),
+        FormatDeliveryFee(order.DeliveryFee),
+        order.Price,
     )
     
     msg := tgbotapi.NewMessage(chatID, msgText)
     msg.ReplyMarkup = b.CreateMainMenuKeyboard() // Добавляем главное меню
     b.SendMessage(msg)
     // show keyboard for another order
-    b.ShowMainMenu(ctx, chatID, phone)
+    b.ShowMainMenu(ctx, chatID, order.Contact)
 }
 
 func (b *Bot) HandleNewOrder(ctx context.Context, chatID int64) {
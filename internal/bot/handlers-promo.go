@@ -0,0 +1,350 @@
+package bot
+
+import (
+    "adtime-bot/internal/storage"
+    "adtime-bot/pkg/money"
+    "context"
+    "errors"
+    "fmt"
+    "regexp"
+    "strconv"
+    "strings"
+    "time"
+
+    tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+    "go.uber.org/zap"
+)
+
+const promoUsage = `Использование:
+/promos — промокоды и их использование
+/promos add <КОД> <10% | 500> [from=ДД.ММ.ГГГГ] [until=ДД.ММ.ГГГГ] [uses=N] [per_user=N] [textures=Питон,Кожа Люкс] — новый промокод
+/promos off <КОД> — отключить промокод
+uses — сколько всего заказов может использовать код, per_user — сколько раз один клиент (по умолчанию 1); 0 — без ограничения.
+textures указывается последним и ограничивает код этими текстурами.`
+
+var promoCodePattern = regexp.MustCompile(`^[\p{L}\p{N}_-]{3,32}$`)
+
+// PromoAllowance hands a promo code's discount out to the items it covers: a
+// percentage to each of them, a fixed amount until it is used up. A nil
+// allowance gives no discount.
+type PromoAllowance struct {
+    promo     *storage.PromoCode
+    remaining money.Kopecks
+}
+
+func NewPromoAllowance(promo *storage.PromoCode) *PromoAllowance {
+    if promo == nil {
+        return nil
+    }
+    allowance := &PromoAllowance{promo: promo}
+    if promo.Kind == storage.PromoFixed {
+        allowance.remaining = money.FromRubles(promo.Value)
+    }
+    return allowance
+}
+
+// Params adds the promo discount to the pricing of an item of the texture
+func (a *PromoAllowance) Params(params PriceParams, textureName string) PriceParams {
+    if a == nil || !a.promo.AppliesTo(textureName) {
+        return params
+    }
+    switch a.promo.Kind {
+    case storage.PromoPercent:
+        params.PromoRate = a.promo.Value / 100
+    case storage.PromoFixed:
+        params.PromoAmount = a.remaining
+    }
+    return params
+}
+
+// Use takes the discount an item got off what is left of a fixed amount
+func (a *PromoAllowance) Use(discount money.Kopecks) {
+    if a == nil || a.promo.Kind != storage.PromoFixed {
+        return
+    }
+    a.remaining = max(a.remaining-discount, 0)
+}
+
+// IsPromoCodeError reports whether err says a promo code cannot be used
+func IsPromoCodeError(err error) bool {
+    return errors.Is(err, storage.ErrPromoCodeNotFound) ||
+        errors.Is(err, storage.ErrPromoCodeInactive) ||
+        errors.Is(err, storage.ErrPromoCodeExhausted) ||
+        errors.Is(err, storage.ErrPromoCodeUsed)
+}
+
+// PromoCodeErrorText explains to the customer why a promo code cannot be used
+func PromoCodeErrorText(err error) string {
+    switch {
+    case errors.Is(err, storage.ErrPromoCodeNotFound):
+        return "Промокод не найден"
+    case errors.Is(err, storage.ErrPromoCodeInactive):
+        return "Промокод сейчас не действует"
+    case errors.Is(err, storage.ErrPromoCodeExhausted):
+        return "Промокод больше не действует: его уже использовали максимальное число раз"
+    case errors.Is(err, storage.ErrPromoCodeUsed):
+        return "Вы уже использовали этот промокод"
+    default:
+        return "Не удалось проверить промокод, попробуйте позже"
+    }
+}
+
+// HandlePromoCommand applies a customer's promo code to the order they are
+// putting together, or shows the code applied now
+func (b *Bot) HandlePromoCommand(ctx context.Context, chatID int64, args []string) {
+    state, err := b.state.GetFullState(ctx, chatID)
+    if err != nil {
+        state = UserState{}
+    }
+
+    if len(args) == 0 {
+        if state.PromoCode == "" {
+            b.SendMessage(tgbotapi.NewMessage(chatID, "Использование: /promo <промокод>"))
+            return
+        }
+        b.SendMessage(tgbotapi.NewMessage(chatID, fmt.Sprintf(
+            "🎟 Применён промокод %s. Чтобы заменить его, отправьте /promo <промокод>", state.PromoCode)))
+        return
+    }
+
+    promo, err := b.storage.CheckPromoCode(ctx, args[0], chatID, time.Now())
+    if err != nil {
+        if !IsPromoCodeError(err) {
+            b.logger.Error("Failed to check promo code",
+                zap.Int64("chat_id", chatID),
+                zap.String("code", args[0]),
+                zap.Error(err))
+        }
+        b.SendError(chatID, PromoCodeErrorText(err))
+        return
+    }
+
+    if err := b.state.SetPromoCode(ctx, chatID, promo.Code); err != nil {
+        b.logger.Error("Failed to save promo code",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+        b.SendError(chatID, "Ошибка при применении промокода")
+        return
+    }
+
+    b.SendMessage(tgbotapi.NewMessage(chatID, fmt.Sprintf(
+        "✅ Промокод %s применён: скидка %s. Она будет учтена в цене заказа.",
+        promo.Code, FormatPromoDiscount(*promo))))
+
+    // The customer looking at the order review sees the new price at once
+    if state.Step == StepOrderConfirmation {
+        b.ShowOrderReview(ctx, chatID)
+    }
+}
+
+// cartPromo returns the promo code applied to the customer's order if it can
+// still be used. A code that can no longer be used is removed and the
+// customer told why.
+func (b *Bot) cartPromo(ctx context.Context, chatID int64, state UserState) *storage.PromoCode {
+    if state.PromoCode == "" {
+        return nil
+    }
+
+    promo, err := b.storage.CheckPromoCode(ctx, state.PromoCode, chatID, time.Now())
+    if err == nil {
+        return promo
+    }
+    if !IsPromoCodeError(err) {
+        b.logger.Error("Failed to check promo code",
+            zap.Int64("chat_id", chatID),
+            zap.String("code", state.PromoCode),
+            zap.Error(err))
+        return nil
+    }
+    b.dropPromoCode(ctx, chatID, state.PromoCode, err)
+    return nil
+}
+
+// dropPromoCode removes a promo code that can no longer be used from the
+// customer's order
+func (b *Bot) dropPromoCode(ctx context.Context, chatID int64, code string, reason error) {
+    if err := b.state.SetPromoCode(ctx, chatID, ""); err != nil {
+        b.logger.Error("Failed to remove promo code",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+    }
+    b.SendError(chatID, fmt.Sprintf("%s. Промокод %s убран из заказа.", PromoCodeErrorText(reason), code))
+}
+
+// orderPromoAllowance is what the promo code of a saved order leaves for
+// repricing one of its items; the other items keep their discount
+func (b *Bot) orderPromoAllowance(ctx context.Context, order storage.Order, repriced int) *PromoAllowance {
+    if order.PromoCode == nil {
+        return nil
+    }
+
+    promo, err := b.storage.GetPromoCode(ctx, *order.PromoCode)
+    if err != nil {
+        b.logger.Error("Failed to get order promo code",
+            zap.Int64("order_id", order.ID),
+            zap.String("code", *order.PromoCode),
+            zap.Error(err))
+        return nil
+    }
+
+    allowance := NewPromoAllowance(promo)
+    for i, item := range order.Items {
+        if i != repriced {
+            allowance.Use(money.FromRubles(item.PromoDiscount))
+        }
+    }
+    return allowance
+}
+
+// HandlePromosCommand lists, creates and disables promo codes
+func (b *Bot) HandlePromosCommand(ctx context.Context, chatID int64, args []string) {
+    if len(args) == 0 {
+        b.showPromoCodes(ctx, chatID)
+        return
+    }
+
+    switch {
+    case args[0] == "add" && len(args) >= 3:
+        promo, err := ParsePromoCode(args[1:])
+        if err != nil {
+            b.SendError(chatID, fmt.Sprintf("%s\n\n%s", capitalize(err.Error()), promoUsage))
+            return
+        }
+        for _, name := range promo.Textures {
+            if _, err := b.storage.GetTextureByName(ctx, name); err != nil {
+                b.SendError(chatID, fmt.Sprintf("Текстура «%s» не найдена", name))
+                return
+            }
+        }
+        promo.CreatedBy = &chatID
+
+        if err := b.storage.SavePromoCode(ctx, promo); err != nil {
+            if errors.Is(err, storage.ErrPromoCodeExists) {
+                b.SendError(chatID, fmt.Sprintf("Промокод %s уже существует", promo.Code))
+                return
+            }
+            b.logger.Error("Failed to save promo code",
+                zap.String("code", promo.Code),
+                zap.Error(err))
+            b.SendError(chatID, "Ошибка при сохранении промокода")
+            return
+        }
+        b.SendMessage(tgbotapi.NewMessage(chatID, "✅ Промокод создан:\n"+FormatPromoCode(storage.PromoCodeStats{PromoCode: promo})))
+
+    case args[0] == "off" && len(args) == 2:
+        if err := b.storage.DeactivatePromoCode(ctx, args[1]); err != nil {
+            if errors.Is(err, storage.ErrPromoCodeNotFound) {
+                b.SendError(chatID, "Промокод не найден")
+                return
+            }
+            b.logger.Error("Failed to deactivate promo code",
+                zap.String("code", args[1]),
+                zap.Error(err))
+            b.SendError(chatID, "Ошибка при отключении промокода")
+            return
+        }
+        b.SendMessage(tgbotapi.NewMessage(chatID, "✅ Промокод отключён. Уже оформленные заказы сохраняют скидку."))
+
+    default:
+        b.SendError(chatID, promoUsage)
+    }
+}
+
+func (b *Bot) showPromoCodes(ctx context.Context, chatID int64) {
+    stats, err := b.storage.GetPromoCodeStats(ctx)
+    if err != nil {
+        b.logger.Error("Failed to get promo code stats", zap.Error(err))
+        b.SendError(chatID, "Ошибка при получении промокодов")
+        return
+    }
+
+    var sb strings.Builder
+    sb.WriteString("🎟 Промокоды:\n")
+    if len(stats) == 0 {
+        sb.WriteString("пока нет ни одного\n")
+    }
+    for _, promo := range stats {
+        sb.WriteString(FormatPromoCode(promo) + "\n")
+    }
+    sb.WriteString("\n" + promoUsage)
+    b.SendMessage(tgbotapi.NewMessage(chatID, sb.String()))
+}
+
+// ParsePromoCode reads the arguments of /promos add: the code, the discount
+// ("10%" or an amount in roubles) and key=value options, of which textures=
+// takes the rest of the line
+func ParsePromoCode(args []string) (storage.PromoCode, error) {
+    if len(args) < 2 {
+        return storage.PromoCode{}, errors.New("укажите код и скидку")
+    }
+
+    promo := storage.PromoCode{
+        Code:           storage.NormalizePromoCode(args[0]),
+        MaxUsesPerUser: 1,
+        Active:         true,
+    }
+    if !promoCodePattern.MatchString(promo.Code) {
+        return storage.PromoCode{}, errors.New("код должен состоять из 3–32 букв, цифр, «-» или «_»")
+    }
+
+    if percent, ok := strings.CutSuffix(args[1], "%"); ok {
+        value, err := strconv.ParseFloat(strings.ReplaceAll(percent, ",", "."), 64)
+        if err != nil || value <= 0 || value >= 100 {
+            return storage.PromoCode{}, errors.New("скидка в процентах должна быть больше 0 и меньше 100")
+        }
+        promo.Kind, promo.Value = storage.PromoPercent, value
+    } else {
+        amount, err := money.ParseRubles(args[1])
+        if err != nil || amount <= 0 {
+            return storage.PromoCode{}, errors.New("неверная сумма скидки")
+        }
+        promo.Kind, promo.Value = storage.PromoFixed, amount.Rubles()
+    }
+
+    for i := 2; i < len(args); i++ {
+        key, value, ok := strings.Cut(args[i], "=")
+        if !ok {
+            return storage.PromoCode{}, fmt.Errorf("непонятный параметр «%s»", args[i])
+        }
+
+        var err error
+        switch key {
+        case "from", "until":
+            var date time.Time
+            date, err = time.Parse("02.01.2006", value)
+            if key == "from" {
+                promo.ValidFrom = &date
+            } else {
+                promo.ValidUntil = &date
+            }
+        case "uses":
+            promo.MaxUses, err = strconv.Atoi(value)
+            if promo.MaxUses < 0 {
+                err = errors.New("negative")
+            }
+        case "per_user":
+            promo.MaxUsesPerUser, err = strconv.Atoi(value)
+            if promo.MaxUsesPerUser < 0 {
+                err = errors.New("negative")
+            }
+        case "textures":
+            names := strings.Join(append([]string{value}, args[i+1:]...), " ")
+            for _, name := range strings.Split(names, ",") {
+                if name = strings.TrimSpace(name); name != "" {
+                    promo.Textures = append(promo.Textures, name)
+                }
+            }
+            i = len(args)
+        default:
+            return storage.PromoCode{}, fmt.Errorf("неизвестный параметр «%s»", key)
+        }
+        if err != nil {
+            return storage.PromoCode{}, fmt.Errorf("неверное значение параметра «%s»", key)
+        }
+    }
+
+    if promo.ValidFrom != nil && promo.ValidUntil != nil && promo.ValidUntil.Before(*promo.ValidFrom) {
+        return storage.PromoCode{}, errors.New("дата окончания раньше даты начала")
+    }
+    return promo, nil
+}
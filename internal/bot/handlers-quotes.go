@@ -0,0 +1,361 @@
+package bot
+
+import (
+	"adtime-bot/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// SubmitQuoteRequest saves an order for a texture outside the catalogue
+// without a price and asks the admins to quote it
+func (b *Bot) SubmitQuoteRequest(ctx context.Context, chatID int64, state UserState) error {
+    dueDate, err := time.Parse("02.01.2006", state.Date)
+    if err != nil {
+        return fmt.Errorf("invalid due date %q: %w", state.Date, err)
+    }
+
+    item := state.CurrentItem()
+    quote := storage.QuoteRequest{
+        UserID:             chatID,
+        Contact:            state.PhoneNumber,
+        TextureDescription: item.ServiceType,
+        WidthCM:            item.WidthCM,
+        HeightCM:           item.HeightCM,
+        Shape:              item.Shape,
+        CornerRadiusCM:     item.CornerRadiusCM,
+        Quantity:           item.PriceParams().Quantity,
+        DueDate:            &dueDate,
+        Status:             storage.QuoteStatusPending,
+        CreatedAt:          time.Now(),
+        Fulfilment:         state.Fulfilment,
+    }
+
+    quote.ID, err = b.storage.SaveQuoteRequest(ctx, quote)
+    if err != nil {
+        return err
+    }
+
+    if err := b.storage.SaveUserAgreement(ctx, chatID, quote.Contact); err != nil {
+        b.logger.Error("Failed to save user agreement", zap.Error(err))
+    }
+
+    msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
+        "📨 Запрос на расчёт #%d отправлен!\n\n"+
+            "Текстура «%s» не из нашего каталога, поэтому стоимость рассчитает менеджер. "+
+            "Мы пришлём предложение в этот чат, и вы сможете его принять.",
+        quote.ID, quote.TextureDescription))
+    msg.ReplyMarkup = b.CreateMainMenuKeyboard()
+    b.SendMessage(msg)
+
+    b.NotifyAdminText(ctx, FormatQuoteRequest(quote), b.CreateQuotePriceKeyboard(quote.ID))
+
+    // Reference files are the main input for pricing an unknown texture
+    attachments := orderAttachmentsFromPending(state.Attachments, chatID)
+    for _, adminID := range b.adminChatIDs() {
+        b.sendAttachments(adminID, attachments)
+    }
+    return nil
+}
+
+// HandleQuotePriceButton asks the admin for the price per dm² of a quote request
+func (b *Bot) HandleQuotePriceButton(ctx context.Context, chatID int64, quoteIDStr string) {
+    if !b.IsAdmin(chatID) {
+        b.SendError(chatID, "У вас нет прав для этого действия")
+        return
+    }
+
+    quoteID, err := strconv.ParseInt(quoteIDStr, 10, 64)
+    if err != nil {
+        b.SendError(chatID, "Неверный формат номера запроса")
+        return
+    }
+
+    if err := b.state.SetQuoteID(ctx, chatID, quoteID); err != nil {
+        b.logger.Error("Failed to save quote to price",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+        b.SendError(chatID, "Произошла ошибка, попробуйте позже")
+        return
+    }
+
+    msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
+        "Введите цену материала за дм² для запроса #%d (например: 40 или 37.5)", quoteID))
+    msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
+        tgbotapi.NewKeyboardButtonRow(
+            tgbotapi.NewKeyboardButton("Назад"),
+        ),
+    )
+    b.SendMessage(msg)
+
+    if err := b.state.SetStep(ctx, chatID, StepQuotePrice); err != nil {
+        b.logger.Error("Failed to set quote price state",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+    }
+}
+
+func (b *Bot) HandleQuotePriceInput(ctx context.Context, chatID int64, text string) {
+    state, err := b.state.GetFullState(ctx, chatID)
+    if err != nil || state.QuoteID == 0 {
+        b.SendError(chatID, "Не удалось определить запрос на расчёт")
+        b.state.ResetOrderState(ctx, chatID)
+        return
+    }
+
+    if text == "Назад" {
+        b.state.ResetOrderState(ctx, chatID)
+        msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Цена для запроса #%d не указана.", state.QuoteID))
+        msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+        b.SendMessage(msg)
+        return
+    }
+
+    if b.HandleQuotePrice(ctx, chatID, strconv.FormatInt(state.QuoteID, 10), text) {
+        b.state.ResetOrderState(ctx, chatID)
+    }
+}
+
+// HandleQuotePrice prices a quote request and sends the offer to the customer.
+// It reports whether the price was saved.
+func (b *Bot) HandleQuotePrice(ctx context.Context, chatID int64, quoteIDStr, priceStr string) bool {
+    if !b.IsAdmin(chatID) {
+        b.SendError(chatID, "У вас нет прав для этого действия")
+        return false
+    }
+
+    quoteID, err := strconv.ParseInt(quoteIDStr, 10, 64)
+    if err != nil {
+        b.SendError(chatID, "Неверный формат номера запроса")
+        return false
+    }
+
+    pricePerDM2, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(priceStr), ",", "."), 64)
+    if err != nil || pricePerDM2 <= 0 {
+        b.SendError(chatID, "Цена за дм² должна быть положительным числом")
+        return false
+    }
+
+    quote, err := b.storage.SetQuotePrice(ctx, quoteID, pricePerDM2, chatID)
+    switch {
+    case errors.Is(err, storage.ErrQuoteNotFound):
+        b.SendError(chatID, "Запрос на расчёт не найден")
+        return false
+    case errors.Is(err, storage.ErrQuoteNotOpen):
+        b.SendError(chatID, fmt.Sprintf("Запрос #%d уже закрыт", quoteID))
+        return false
+    case err != nil:
+        b.logger.Error("Failed to set quote price",
+            zap.Int64("quote_id", quoteID),
+            zap.Error(err))
+        b.SendError(chatID, "Ошибка при сохранении цены")
+        return false
+    }
+
+    priceDetails, err := b.quotePriceDetails(*quote)
+    if err != nil {
+        b.logger.Error("Failed to calculate quote price",
+            zap.Int64("quote_id", quoteID),
+            zap.Error(err))
+        b.SendError(chatID, "Ошибка при расчете цены")
+        return false
+    }
+
+    // The offer shows what accepting it will cost, see priceOrder
+    adjustment := b.orderAdjustment(priceDetails.FinalPrice)
+    offer := tgbotapi.NewMessage(quote.UserID, FormatQuoteOffer(*quote, priceDetails, adjustment,
+        b.DeliveryFee(quote.Fulfilment.Method, priceDetails.FinalPrice+adjustment)))
+    offer.ReplyMarkup = b.CreateQuoteOfferKeyboard(quote.ID)
+    if _, err := b.bot.Send(offer); err != nil {
+        b.logger.Warn("Failed to send quote to customer",
+            zap.Int64("user_id", quote.UserID),
+            zap.Error(err))
+        b.SendError(chatID, "Цена сохранена, но отправить предложение клиенту не удалось")
+        return true
+    }
+
+    msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
+        "✅ Предложение по запросу #%d отправлено клиенту: %s ₽ (%.2f ₽/дм²)",
+        quote.ID, priceDetails.FinalPrice, pricePerDM2))
+    msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+    b.SendMessage(msg)
+    return true
+}
+
+// HandleQuoteAccept turns a quoted request into a normal order
+func (b *Bot) HandleQuoteAccept(ctx context.Context, chatID int64, quoteIDStr string) {
+    quoteID, err := strconv.ParseInt(quoteIDStr, 10, 64)
+    if err != nil {
+        b.SendError(chatID, "Неверный формат номера запроса")
+        return
+    }
+
+    quote, err := b.storage.GetQuoteRequest(ctx, quoteID)
+    if err != nil || quote.UserID != chatID {
+        if err != nil && !errors.Is(err, storage.ErrQuoteNotFound) {
+            b.logger.Error("Failed to get quote request",
+                zap.Int64("quote_id", quoteID),
+                zap.Error(err))
+        }
+        b.SendError(chatID, "Запрос на расчёт не найден")
+        return
+    }
+    if quote.Status != storage.QuoteStatusQuoted || quote.TextureID == nil {
+        b.SendError(chatID, fmt.Sprintf("Предложение по запросу #%d уже неактуально", quoteID))
+        return
+    }
+
+    texture, err := b.storage.GetTextureByID(ctx, *quote.TextureID)
+    if err != nil {
+        b.logger.Error("Failed to get quote texture",
+            zap.Int64("quote_id", quoteID),
+            zap.Error(err))
+        b.SendError(chatID, "Ошибка при оформлении заказа. Пожалуйста, попробуйте позже.")
+        return
+    }
+
+    params := quotePriceParams(*quote)
+    priceDetails, err := b.CalculateOrderPrice(ctx, params, texture)
+    if err != nil {
+        b.logger.Error("Failed to calculate quote order price",
+            zap.Int64("quote_id", quoteID),
+            zap.Error(err))
+        b.SendError(chatID, "Ошибка при расчете цены")
+        return
+    }
+
+    item := storage.OrderItem{
+        Position:       1,
+        TextureID:      texture.ID,
+        TextureName:    texture.Name,
+        WidthCM:        quote.WidthCM,
+        HeightCM:       quote.HeightCM,
+        Shape:          quote.Shape,
+        CornerRadiusCM: quote.CornerRadiusCM,
+        Quantity:       params.Quantity,
+    }
+    ApplyPriceDetails(&item, priceDetails)
+
+    order := storage.Order{
+        UserID:    chatID,
+        Contact:   quote.Contact,
+        Status:    storage.StatusNew,
+        DueDate:    quote.DueDate,
+        CreatedAt:  time.Now(),
+        Items:      []storage.OrderItem{item},
+        Fulfilment: quote.Fulfilment,
+    }
+    b.priceOrder(&order)
+
+    err = b.storage.AcceptQuote(ctx, quoteID, chatID, &order)
+    if errors.Is(err, storage.ErrQuoteNotOpen) || errors.Is(err, storage.ErrQuoteNotFound) {
+        b.SendError(chatID, fmt.Sprintf("Предложение по запросу #%d уже неактуально", quoteID))
+        return
+    }
+    if errors.Is(err, storage.ErrDayFull) {
+        b.quoteDayFull(ctx, *quote, ShapeArea(params)/100*float64(params.Quantity))
+        return
+    }
+    if err != nil {
+        b.logger.Error("Failed to accept quote",
+            zap.Int64("quote_id", quoteID),
+            zap.Error(err))
+        b.SendError(chatID, "Ошибка при оформлении заказа. Пожалуйста, попробуйте позже.")
+        return
+    }
+
+    b.announceOrder(ctx, order)
+}
+
+// quoteDayFull tells the customer that the quoted date was taken by other
+// orders and asks an admin to agree on a new one
+func (b *Bot) quoteDayFull(ctx context.Context, quote storage.QuoteRequest, areaDM2 float64) {
+    text := fmt.Sprintf("К сожалению, на %s производство уже загружено, поэтому заказ по запросу #%d не оформлен.",
+        quote.DueDate.Format("02.01.2006"), quote.ID)
+    day, found, err := b.storage.NearestFreeDay(ctx, quote.DueDate.AddDate(0, 0, 1), areaDM2)
+    if err != nil {
+        b.logger.Error("Failed to find free day",
+            zap.Int64("quote_id", quote.ID),
+            zap.Error(err))
+    }
+    if found {
+        text += fmt.Sprintf("\nБлижайшая свободная дата: %s.", day.Format("02.01.2006"))
+    }
+    text += "\nМенеджер свяжется с вами, чтобы согласовать новый срок."
+    b.SendMessage(tgbotapi.NewMessage(quote.UserID, text))
+
+    b.NotifyAdminText(ctx, fmt.Sprintf("📅 Клиент не смог принять предложение по запросу #%d: на %s нет свободной мощности",
+        quote.ID, quote.DueDate.Format("02.01.2006")), nil)
+}
+
+func (b *Bot) HandleQuoteDecline(ctx context.Context, chatID int64, quoteIDStr string) {
+    quoteID, err := strconv.ParseInt(quoteIDStr, 10, 64)
+    if err != nil {
+        b.SendError(chatID, "Неверный формат номера запроса")
+        return
+    }
+
+    err = b.storage.DeclineQuote(ctx, quoteID, chatID)
+    if errors.Is(err, storage.ErrQuoteNotOpen) {
+        b.SendError(chatID, fmt.Sprintf("Запрос #%d уже закрыт", quoteID))
+        return
+    }
+    if err != nil {
+        b.logger.Error("Failed to decline quote",
+            zap.Int64("quote_id", quoteID),
+            zap.Error(err))
+        b.SendError(chatID, "Произошла ошибка, попробуйте позже")
+        return
+    }
+
+    msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Запрос на расчёт #%d закрыт. Будем рады помочь с другим заказом!", quoteID))
+    msg.ReplyMarkup = b.CreateMainMenuKeyboard()
+    b.SendMessage(msg)
+
+    b.NotifyAdminText(ctx, fmt.Sprintf("❌ Клиент отказался от предложения по запросу #%d", quoteID), nil)
+}
+
+// HandleOpenQuotes lists quote requests that still need a price or an answer
+func (b *Bot) HandleOpenQuotes(ctx context.Context, chatID int64) {
+    quotes, err := b.storage.GetOpenQuoteRequests(ctx)
+    if err != nil {
+        b.logger.Error("Failed to get open quote requests", zap.Error(err))
+        b.SendError(chatID, "Ошибка при получении запросов на расчёт")
+        return
+    }
+
+    if len(quotes) == 0 {
+        b.SendMessage(tgbotapi.NewMessage(chatID, "Открытых запросов на расчёт нет"))
+        return
+    }
+
+    for _, quote := range quotes {
+        msg := tgbotapi.NewMessage(chatID, FormatQuoteRequest(quote))
+        msg.ReplyMarkup = b.CreateQuotePriceKeyboard(quote.ID)
+        b.SendMessage(msg)
+    }
+}
+
+func (b *Bot) quotePriceDetails(quote storage.QuoteRequest) (PriceBreakdown, error) {
+    if quote.PricePerDM2 == nil {
+        return PriceBreakdown{}, fmt.Errorf("quote %d has no price", quote.ID)
+    }
+    params := quotePriceParams(quote)
+    return CalculateItemPrice(params, NewPricingConfig(*quote.PricePerDM2, b.cfg))
+}
+
+func quotePriceParams(quote storage.QuoteRequest) PriceParams {
+    return PriceParams{
+        WidthCM:        quote.WidthCM,
+        HeightCM:       quote.HeightCM,
+        Quantity:       quote.Quantity,
+        Shape:          quote.Shape,
+        CornerRadiusCM: quote.CornerRadiusCM,
+    }
+}
@@ -0,0 +1,227 @@
+package bot
+
+import (
+	"adtime-bot/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// SearchPageSize is the number of orders on one page of /find results
+const SearchPageSize = 5
+
+const findUsage = `Использование: /find <фильтр>=<значение> ...
+Фильтры:
+phone=+79991234567 — телефон клиента
+user=123456789 — Telegram ID клиента
+texture=Крокодил — текстура (часть названия)
+status=new — статус (` + "new, confirmed, in_production, ready, delivered, cancelled" + `)
+from=01.03.2026 to=31.03.2026 — дата создания заказа
+
+Пример: /find status=new texture=Питон`
+
+// ParseOrderFilter reads /find arguments of the form key=value. Words without
+// "=" continue the previous value, so texture names may contain spaces.
+func ParseOrderFilter(args []string) (storage.OrderFilter, error) {
+    var filter storage.OrderFilter
+    if len(args) == 0 {
+        return filter, errors.New("не указаны условия поиска")
+    }
+
+    var pairs [][2]string
+    for _, arg := range args {
+        key, value, ok := strings.Cut(arg, "=")
+        if !ok {
+            if len(pairs) == 0 {
+                return filter, fmt.Errorf("непонятное условие «%s»", arg)
+            }
+            pairs[len(pairs)-1][1] += " " + arg
+            continue
+        }
+        pairs = append(pairs, [2]string{strings.ToLower(key), value})
+    }
+
+    for _, pair := range pairs {
+        key, value := pair[0], strings.TrimSpace(pair[1])
+        if value == "" {
+            return filter, fmt.Errorf("пустое значение фильтра «%s»", key)
+        }
+
+        switch key {
+        case "phone":
+            phone := NormalizePhoneNumber(value)
+            if !IsValidPhoneNumber(phone) {
+                return filter, fmt.Errorf("неверный номер телефона «%s»", value)
+            }
+            filter.Phone = phone
+        case "user":
+            userID, err := strconv.ParseInt(value, 10, 64)
+            if err != nil || userID <= 0 {
+                return filter, fmt.Errorf("неверный Telegram ID «%s»", value)
+            }
+            filter.UserID = userID
+        case "texture":
+            filter.Texture = value
+        case "status":
+            if !storage.IsValidStatus(value) {
+                return filter, fmt.Errorf("неизвестный статус «%s»", value)
+            }
+            filter.Status = value
+        case "from", "to":
+            date, err := time.ParseInLocation("02.01.2006", value, time.Local)
+            if err != nil {
+                return filter, fmt.Errorf("неверная дата «%s», нужен формат ДД.ММ.ГГГГ", value)
+            }
+            if key == "from" {
+                filter.CreatedFrom = &date
+            } else {
+                filter.CreatedTo = &date
+            }
+        default:
+            return filter, fmt.Errorf("неизвестный фильтр «%s»", key)
+        }
+    }
+
+    if filter.CreatedFrom != nil && filter.CreatedTo != nil && filter.CreatedTo.Before(*filter.CreatedFrom) {
+        return filter, errors.New("дата «to» раньше даты «from»")
+    }
+    return filter, nil
+}
+
+// HandleFindOrders runs an admin's /find query and shows the first page of results
+func (b *Bot) HandleFindOrders(ctx context.Context, chatID int64, args []string) {
+    filter, err := ParseOrderFilter(args)
+    if err != nil {
+        b.SendError(chatID, fmt.Sprintf("%s\n\n%s", capitalize(err.Error()), findUsage))
+        return
+    }
+
+    if err := b.state.SetSearchFilter(ctx, chatID, filter); err != nil {
+        b.logger.Error("Failed to save search filter",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+    }
+    b.showSearchPage(ctx, chatID, 0, filter, 0, 0)
+}
+
+// HandleFindPage moves between pages of the last /find results in place.
+// The callback data is "older:<id>" or "newer:<id>".
+func (b *Bot) HandleFindPage(ctx context.Context, chatID int64, messageID int, data string) {
+    if !b.IsAdmin(chatID) {
+        b.SendError(chatID, "У вас нет прав для этого действия")
+        return
+    }
+
+    state, err := b.state.GetFullState(ctx, chatID)
+    if err != nil || state.SearchFilter == nil {
+        b.SendError(chatID, "Поиск устарел, повторите /find")
+        return
+    }
+
+    direction, idStr, _ := strings.Cut(data, ":")
+    id, err := strconv.ParseInt(idStr, 10, 64)
+    if err != nil {
+        b.SendError(chatID, "Неверный формат команды")
+        return
+    }
+
+    var beforeID, afterID int64
+    if direction == "newer" {
+        afterID = id
+    } else {
+        beforeID = id
+    }
+    b.showSearchPage(ctx, chatID, messageID, *state.SearchFilter, beforeID, afterID)
+}
+
+func (b *Bot) showSearchPage(ctx context.Context, chatID int64, messageID int, filter storage.OrderFilter, beforeID, afterID int64) {
+    page, err := b.storage.FindOrders(ctx, filter, beforeID, afterID, SearchPageSize)
+    if err != nil {
+        b.logger.Error("Failed to find orders",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+        b.SendError(chatID, "Ошибка при поиске заказов")
+        return
+    }
+
+    if len(page.Orders) == 0 {
+        b.SendMessage(tgbotapi.NewMessage(chatID, "🔍 Заказы не найдены"))
+        return
+    }
+
+    var sb strings.Builder
+    sb.WriteString("🔍 Найденные заказы:\n\n")
+    for _, order := range page.Orders {
+        sb.WriteString(fmt.Sprintf(
+            "🆔 %s%s от %s — %s\n👤 %s (ID %d)\n📏 %s\n💵 %.2f ₽\n\n",
+            order.Number(), FormatRushMark(order.Rush),
+            order.CreatedAt.Format("02.01.2006"),
+            StatusLabel(order.Status),
+            FormatPhoneNumber(order.Contact),
+            order.UserID,
+            FormatOrderHeadline(order),
+            order.Price,
+        ))
+    }
+    keyboard := b.CreateSearchResultsKeyboard(page)
+
+    if messageID != 0 {
+        edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, sb.String(), keyboard)
+        if _, err := b.bot.Send(edit); err == nil {
+            return
+        }
+        b.logger.Warn("Failed to update search results message",
+            zap.Int64("chat_id", chatID),
+            zap.Int("message_id", messageID))
+    }
+
+    msg := tgbotapi.NewMessage(chatID, sb.String())
+    msg.ReplyMarkup = keyboard
+    b.SendMessage(msg)
+}
+
+// HandleStatusMenu offers the status transitions of an order
+func (b *Bot) HandleStatusMenu(ctx context.Context, chatID int64, orderRef string) {
+    if !b.IsAdmin(chatID) {
+        b.SendError(chatID, "У вас нет прав для этого действия")
+        return
+    }
+
+    order, ok := b.getVisibleOrder(ctx, chatID, orderRef)
+    if !ok {
+        return
+    }
+
+    if len(storage.AllowedStatusTransitions(order.Status)) == 0 {
+        b.SendError(chatID, FormatStatusTransitionError(order.Number(), order.Status, order.Status))
+        return
+    }
+
+    msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
+        "Заказ %s сейчас в статусе «%s». Выберите новый статус:", order.Number(), StatusLabel(order.Status)))
+    msg.ReplyMarkup = b.CreateStatusKeyboard(order.Number(), order.Status)
+    b.SendMessage(msg)
+}
+
+// HandleExportOrderCallback sends the Excel file of one order to an admin
+func (b *Bot) HandleExportOrderCallback(ctx context.Context, chatID int64, orderRef string) {
+    if !b.IsAdmin(chatID) {
+        b.SendError(chatID, "У вас нет прав для этого действия")
+        return
+    }
+    b.exportSingleOrder(ctx, chatID, orderRef)
+}
+
+func capitalize(text string) string {
+    if text == "" {
+        return text
+    }
+    runes := []rune(text)
+    return strings.ToUpper(string(runes[0])) + string(runes[1:])
+}
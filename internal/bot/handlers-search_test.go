@@ -0,0 +1,44 @@
+package bot
+
+import (
+    "testing"
+)
+
+func TestParseOrderFilter(t *testing.T) {
+    filter, err := ParseOrderFilter([]string{
+        "phone=8(999)123-45-67", "status=new", "texture=Кожа", "питона", "from=01.03.2026", "to=31.03.2026",
+    })
+    if err != nil {
+        t.Fatalf("ParseOrderFilter failed: %v", err)
+    }
+
+    if filter.Phone != "+79991234567" {
+        t.Errorf("Phone not normalized, got %q", filter.Phone)
+    }
+    if filter.Status != "new" {
+        t.Errorf("Incorrect status, got %q", filter.Status)
+    }
+    if filter.Texture != "Кожа питона" {
+        t.Errorf("Texture words not joined, got %q", filter.Texture)
+    }
+    if filter.CreatedFrom == nil || filter.CreatedTo == nil || filter.CreatedFrom.Day() != 1 || filter.CreatedTo.Day() != 31 {
+        t.Errorf("Incorrect date range, got %v - %v", filter.CreatedFrom, filter.CreatedTo)
+    }
+}
+
+func TestParseOrderFilter_Invalid(t *testing.T) {
+    invalid := [][]string{
+        nil,
+        {"Кожа"},
+        {"status=shipped"},
+        {"user=abc"},
+        {"from=2026-03-01"},
+        {"from=31.03.2026", "to=01.03.2026"},
+        {"color=red"},
+    }
+    for _, args := range invalid {
+        if _, err := ParseOrderFilter(args); err == nil {
+            t.Errorf("ParseOrderFilter(%q) should fail", args)
+        }
+    }
+}
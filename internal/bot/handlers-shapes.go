@@ -0,0 +1,59 @@
+package bot
+
+import (
+    "context"
+
+    tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+    "go.uber.org/zap"
+)
+
+// StartShapeSelection asks for the shape of the current item
+func (b *Bot) StartShapeSelection(ctx context.Context, chatID int64) {
+    msg := tgbotapi.NewMessage(chatID, "Выберите форму изделия:")
+    msg.ReplyMarkup = b.CreateShapeKeyboard()
+    b.SendMessage(msg)
+
+    if err := b.state.SetStep(ctx, chatID, StepShape); err != nil {
+        b.logger.Error("Failed to set shape state",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+    }
+}
+
+func (b *Bot) HandleShape(ctx context.Context, chatID int64, text string) {
+    if text == "Назад" {
+        b.HandleCancel(ctx, chatID)
+        return
+    }
+
+    shape, ok := ShapeFromButton(text)
+    if !ok {
+        b.SendError(chatID, "Пожалуйста, выберите форму из списка")
+        return
+    }
+
+    if err := b.state.SetShape(ctx, chatID, shape); err != nil {
+        b.logger.Error("Failed to set shape",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+        b.SendError(chatID, "Ошибка при сохранении формы")
+        return
+    }
+
+    // The size is entered differently for every shape, so it is asked
+    // again even when the shape is changed from the order review
+    b.AskDimensions(ctx, chatID, shape)
+}
+
+// AskDimensions asks for the size of a piece of the given shape
+func (b *Bot) AskDimensions(ctx context.Context, chatID int64, shape string) {
+    msg := tgbotapi.NewMessage(chatID, DimensionsPrompt(shape))
+    msg.ReplyMarkup = b.CreateDimensionsKeyboard(shape)
+    b.SendMessage(msg)
+
+    if err := b.state.SetStep(ctx, chatID, StepDimensions); err != nil {
+        b.logger.Error("Failed to set dimensions state",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+    }
+}
@@ -0,0 +1,225 @@
+package bot
+
+import (
+    "adtime-bot/internal/storage"
+    "context"
+    "errors"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+
+    tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+    "go.uber.org/zap"
+)
+
+const staffUsage = `Использование:
+/staff — список мастеров
+/staff add <telegram_id> <имя> — добавить мастера
+/staff remove <telegram_id> — убрать мастера`
+
+// HandleStaffCommand lists, adds and removes craftsmen
+func (b *Bot) HandleStaffCommand(ctx context.Context, chatID int64, args []string) {
+    if len(args) == 0 {
+        b.showStaff(ctx, chatID)
+        return
+    }
+
+    switch {
+    case args[0] == "add" && len(args) >= 3:
+        userID, err := strconv.ParseInt(args[1], 10, 64)
+        if err != nil || userID <= 0 {
+            b.SendError(chatID, "Неверный Telegram ID")
+            return
+        }
+        member := storage.StaffMember{
+            UserID:    userID,
+            Name:      strings.Join(args[2:], " "),
+            Role:      storage.StaffRoleCraftsman,
+            CreatedBy: &chatID,
+        }
+        if err := b.storage.SaveStaffMember(ctx, member); err != nil {
+            b.logger.Error("Failed to save staff member",
+                zap.Int64("user_id", userID),
+                zap.Error(err))
+            b.SendError(chatID, "Ошибка при добавлении мастера")
+            return
+        }
+        b.SendMessage(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ %s добавлен(а) в мастера", member.Name)))
+
+    case args[0] == "remove" && len(args) == 2:
+        userID, err := strconv.ParseInt(args[1], 10, 64)
+        if err != nil {
+            b.SendError(chatID, "Неверный Telegram ID")
+            return
+        }
+        if err := b.storage.DeactivateStaffMember(ctx, userID); err != nil {
+            if errors.Is(err, storage.ErrStaffNotFound) {
+                b.SendError(chatID, "Мастер не найден")
+                return
+            }
+            b.logger.Error("Failed to deactivate staff member",
+                zap.Int64("user_id", userID),
+                zap.Error(err))
+            b.SendError(chatID, "Ошибка при удалении мастера")
+            return
+        }
+        b.SendMessage(tgbotapi.NewMessage(chatID, "✅ Мастер убран из списка. Назначенные ему заказы остались за ним — переназначьте их при необходимости."))
+
+    default:
+        b.SendError(chatID, staffUsage)
+    }
+}
+
+func (b *Bot) showStaff(ctx context.Context, chatID int64) {
+    craftsmen, err := b.storage.GetCraftsmen(ctx)
+    if err != nil {
+        b.logger.Error("Failed to get craftsmen", zap.Error(err))
+        b.SendError(chatID, "Ошибка при получении списка мастеров")
+        return
+    }
+
+    var sb strings.Builder
+    sb.WriteString("👷 Мастера:\n")
+    if len(craftsmen) == 0 {
+        sb.WriteString("пока никого нет\n")
+    }
+    for _, craftsman := range craftsmen {
+        sb.WriteString(fmt.Sprintf("• %s (ID %d)\n", craftsman.Name, craftsman.UserID))
+    }
+    sb.WriteString("\n" + staffUsage)
+    b.SendMessage(tgbotapi.NewMessage(chatID, sb.String()))
+}
+
+// HandleAssignMenu offers the craftsmen an order can be assigned to
+func (b *Bot) HandleAssignMenu(ctx context.Context, chatID int64, orderRef string) {
+    if !b.IsAdmin(chatID) {
+        b.SendError(chatID, "У вас нет прав для этого действия")
+        return
+    }
+
+    order, ok := b.getVisibleOrder(ctx, chatID, orderRef)
+    if !ok {
+        return
+    }
+    if !order.IsOpen() {
+        b.SendError(chatID, fmt.Sprintf("Заказ %s уже %s", order.Number(), StatusLabel(order.Status)))
+        return
+    }
+
+    craftsmen, err := b.storage.GetCraftsmen(ctx)
+    if err != nil {
+        b.logger.Error("Failed to get craftsmen", zap.Error(err))
+        b.SendError(chatID, "Ошибка при получении списка мастеров")
+        return
+    }
+    if len(craftsmen) == 0 {
+        b.SendError(chatID, "Мастера не добавлены. Используйте /staff add <telegram_id> <имя>")
+        return
+    }
+
+    msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Кому назначить заказ %s?", order.Number()))
+    msg.ReplyMarkup = b.CreateAssignKeyboard(order.Number(), craftsmen, order.AssignedTo)
+    b.SendMessage(msg)
+}
+
+// HandleAssignOrder makes a craftsman responsible for an order and lets them
+// and the previous assignee know
+func (b *Bot) HandleAssignOrder(ctx context.Context, chatID int64, orderRef, craftsmanRef string) {
+    if !b.IsAdmin(chatID) {
+        b.SendError(chatID, "У вас нет прав для этого действия")
+        return
+    }
+
+    craftsmanID, err := strconv.ParseInt(craftsmanRef, 10, 64)
+    if err != nil {
+        b.SendError(chatID, "Неверный Telegram ID мастера")
+        return
+    }
+
+    order, ok := b.getVisibleOrder(ctx, chatID, orderRef)
+    if !ok {
+        return
+    }
+    if !order.IsOpen() {
+        b.SendError(chatID, fmt.Sprintf("Заказ %s уже %s", order.Number(), StatusLabel(order.Status)))
+        return
+    }
+
+    craftsman, err := b.storage.GetStaffMember(ctx, craftsmanID)
+    if err != nil {
+        if errors.Is(err, storage.ErrStaffNotFound) {
+            b.SendError(chatID, "Мастер не найден")
+            return
+        }
+        b.logger.Error("Failed to get staff member",
+            zap.Int64("user_id", craftsmanID),
+            zap.Error(err))
+        b.SendError(chatID, "Ошибка при получении мастера")
+        return
+    }
+    if craftsman.Role != storage.StaffRoleCraftsman {
+        b.SendError(chatID, fmt.Sprintf("%s не мастер", craftsman.Name))
+        return
+    }
+
+    if order.AssignedTo != nil && *order.AssignedTo == craftsman.UserID {
+        b.SendMessage(tgbotapi.NewMessage(chatID, fmt.Sprintf("Заказ %s уже назначен: %s", order.Number(), craftsman.Name)))
+        return
+    }
+
+    if err := b.storage.AssignOrder(ctx, order.ID, craftsman.UserID); err != nil {
+        b.logger.Error("Failed to assign order",
+            zap.Int64("order_id", order.ID),
+            zap.Int64("craftsman_id", craftsman.UserID),
+            zap.Error(err))
+        b.SendError(chatID, "Ошибка при назначении заказа")
+        return
+    }
+
+    b.logger.Info("Order assigned",
+        zap.Int64("order_id", order.ID),
+        zap.Int64("craftsman_id", craftsman.UserID),
+        zap.Int64("admin_id", chatID))
+    b.SendMessage(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Заказ %s назначен: %s", order.Number(), craftsman.Name)))
+
+    if _, err := b.bot.Send(tgbotapi.NewMessage(craftsman.UserID, FormatCraftsmanAssignment(*order))); err != nil {
+        b.logger.Warn("Failed to notify craftsman about assignment",
+            zap.Int64("craftsman_id", craftsman.UserID),
+            zap.Error(err))
+    }
+
+    if order.AssignedTo != nil {
+        previous := tgbotapi.NewMessage(*order.AssignedTo, fmt.Sprintf(
+            "ℹ️ Заказ %s передан другому мастеру", order.Number()))
+        if _, err := b.bot.Send(previous); err != nil {
+            b.logger.Warn("Failed to notify previous craftsman",
+                zap.Int64("craftsman_id", *order.AssignedTo),
+                zap.Error(err))
+        }
+    }
+}
+
+// HandleMyQueue shows a craftsman the open orders assigned to them
+func (b *Bot) HandleMyQueue(ctx context.Context, chatID int64) {
+    if _, err := b.storage.GetStaffMember(ctx, chatID); err != nil {
+        if !errors.Is(err, storage.ErrStaffNotFound) {
+            b.logger.Error("Failed to get staff member",
+                zap.Int64("user_id", chatID),
+                zap.Error(err))
+        }
+        b.SendError(chatID, "Очередь заказов доступна только мастерам")
+        return
+    }
+
+    orders, err := b.storage.GetCraftsmanQueue(ctx, chatID)
+    if err != nil {
+        b.logger.Error("Failed to get craftsman queue",
+            zap.Int64("user_id", chatID),
+            zap.Error(err))
+        b.SendError(chatID, "Ошибка при получении очереди")
+        return
+    }
+
+    b.SendMessage(tgbotapi.NewMessage(chatID, FormatCraftsmanQueue(orders, time.Now())))
+}
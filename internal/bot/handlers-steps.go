@@ -1,9 +1,11 @@
 package bot
 
 import (
+	"adtime-bot/internal/storage"
+	"adtime-bot/pkg/money"
 	"context"
+	"errors"
 	"fmt"
-	"strconv"
 	"strings"
 	"time"
 
@@ -67,7 +69,7 @@ func (b *Bot) HandleServiceType(ctx context.Context, chatID int64, text string)
         "Натуральная кожа": true,
         "Искусственная кожа": true,
         "Замша": true,
-        "Другая текстура": true,
+        CustomTextureService: true,
     }
 
     if !validServices[text] {
@@ -80,7 +82,13 @@ func (b *Bot) HandleServiceType(ctx context.Context, chatID int64, text string)
     }
 
     // Handle "Другая текстура" case first
-    if text == "Другая текстура" {
+    if text == CustomTextureService {
+        // A quote request holds a single item, so it cannot join a cart
+        if state, err := b.state.GetFullState(ctx, chatID); err == nil && len(state.Items) > 0 {
+            b.SendError(chatID, "Индивидуальную текстуру можно заказать только отдельным заказом. Выберите текстуру из каталога")
+            return
+        }
+
         if err := b.state.SetService(ctx, chatID, text); err != nil {
             b.logger.Error("Failed to set service",
                 zap.Int64("chat_id", chatID),
@@ -89,6 +97,13 @@ func (b *Bot) HandleServiceType(ctx context.Context, chatID int64, text string)
             return
         }
 
+        // A previously chosen catalogue texture must not leak into the custom order
+        if err := b.state.ClearTexture(ctx, chatID); err != nil {
+            b.logger.Error("Failed to clear texture",
+                zap.Int64("chat_id", chatID),
+                zap.Error(err))
+        }
+
         msg := tgbotapi.NewMessage(chatID, "Введите желаемую текстуру:")
         msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
             tgbotapi.NewKeyboardButtonRow(
@@ -140,16 +155,19 @@ func (b *Bot) HandleServiceType(ctx context.Context, chatID int64, text string)
         return
     }
 
-    // Proceed to dimensions input
-    msg := tgbotapi.NewMessage(chatID, "Введите ширину и длину в сантиметрах через пробел (например: 30 40)\nМаксимальный размер: 80x50 см")
-    msg.ReplyMarkup = b.CreateDimensionsKeyboard()
-    b.SendMessage(msg)
-    
-    if err := b.state.SetStep(ctx, chatID, StepDimensions); err != nil {
-        b.logger.Error("Failed to set dimensions state",
+    // Drop a custom texture name possibly entered earlier
+    if err := b.state.SetServiceType(ctx, chatID, ""); err != nil {
+        b.logger.Error("Failed to reset custom texture",
             zap.Int64("chat_id", chatID),
             zap.Error(err))
     }
+
+    if b.returnToReview(ctx, chatID) {
+        return
+    }
+
+    // Proceed to shape selection
+    b.StartShapeSelection(ctx, chatID)
 }
 
 func (b *Bot) HandleDebugState(ctx context.Context, chatID int64) {
@@ -191,16 +209,12 @@ func (b *Bot) HandleCustomTextureInput(ctx context.Context, chatID int64, text s
         return
     }
 
-    // Переходим к вводу размеров
-    msg := tgbotapi.NewMessage(chatID, "Введите ширину и длину в сантиметрах через пробел (например: 30 40)\nМаксимальный размер: 80x50 см")
-    msg.ReplyMarkup = b.CreateDimensionsKeyboard()
-    b.SendMessage(msg)
-    
-    if err := b.state.SetStep(ctx, chatID, StepDimensions); err != nil {
-        b.logger.Error("Failed to set dimensions state",
-            zap.Int64("chatID", chatID),
-            zap.Error(err))
+    if b.returnToReview(ctx, chatID) {
+        return
     }
+
+    // Переходим к выбору формы
+    b.StartShapeSelection(ctx, chatID)
 }
 
 
@@ -211,35 +225,77 @@ func (b *Bot) HandleDimensionsSize(ctx context.Context, chatID int64, text strin
         return
     }
     
+    state, err := b.state.GetFullState(ctx, chatID)
+    if err != nil {
+        b.logger.Error("Failed to get state for dimensions",
+            zap.Int64("chatID", chatID),
+            zap.Error(err))
+        b.HandleError(ctx, chatID, "Ошибка при сохранении размеров")
+        return
+    }
+
     // Process the dimensions input
-    parts := strings.Split(text, " ")
-    if len(parts) != 2 {
-        b.HandleError(ctx, chatID, "Неверный формат. Введите ширину и длину через пробел (например: 30 40)")
+    width, height, radius, err := ParseShapeDimensions(state.Shape, text)
+    if err != nil {
+        b.SendError(chatID, err.Error())
         return
     }
 
-	width, err := strconv.Atoi(parts[0])
-    if err != nil || width <= 0 || width > 80 {
-        b.HandleError(ctx, chatID, "Некорректная ширина. Допустимый диапазон: 1-80 см")
+	if err := b.state.SetDimensions(ctx, chatID, width, height, radius); err != nil {
+        b.logger.Error("Failed to set dimensions",
+            zap.Int64("chatID", chatID),
+            zap.Error(err))
+        b.HandleError(ctx, chatID, "Ошибка при сохранении размеров")
         return
     }
 
-	height, err := strconv.Atoi(parts[1])
-    if err != nil || height <= 0 || height > 50 {
-        b.HandleError(ctx, chatID, "Некорректная длина. Допустимый диапазон: 1-50 см")
+    if b.returnToReview(ctx, chatID) {
         return
     }
 
-	if err := b.state.SetDimensions(ctx, chatID, width, height); err != nil {
-        b.logger.Error("Failed to set dimensions",
+    msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Сколько одинаковых изделий нужно? Введите число от 1 до %d", MaxQuantity))
+    msg.ReplyMarkup = b.CreateQuantityKeyboard()
+    b.SendMessage(msg)
+
+    if err := b.state.SetStep(ctx, chatID, StepQuantity); err != nil {
+        b.logger.Error("Failed to set quantity state",
             zap.Int64("chatID", chatID),
             zap.Error(err))
-        b.HandleError(ctx, chatID, "Ошибка при сохранении размеров")
+    }
+}
+
+func (b *Bot) HandleQuantity(ctx context.Context, chatID int64, text string) {
+    if text == "Назад" {
+        b.HandleCancel(ctx, chatID)
+        return
+    }
+
+    quantity, err := ParseQuantity(text)
+    if err != nil {
+        b.SendError(chatID, err.Error())
+        return
+    }
+
+    if err := b.state.SetQuantity(ctx, chatID, quantity); err != nil {
+        b.logger.Error("Failed to set quantity",
+            zap.Int64("chatID", chatID),
+            zap.Error(err))
+        b.HandleError(ctx, chatID, "Ошибка при сохранении количества")
+        return
+    }
+
+    if b.returnToReview(ctx, chatID) {
+        return
+    }
+
+    // Additional cart items share the date and contact of the first one
+    if state, err := b.state.GetFullState(ctx, chatID); err == nil && len(state.Items) > 0 {
+        b.ShowOrderReview(ctx, chatID)
         return
     }
 
 	msg := tgbotapi.NewMessage(chatID, "Когда вам удобно выполнить заказ?")
-    msg.ReplyMarkup = b.CreateDateSelectionKeyboard()
+    msg.ReplyMarkup = b.dateSelectionKeyboard(ctx, chatID)
     b.SendMessage(msg)
 	
 	if err := b.state.SetStep(ctx, chatID, StepDateSelection); err != nil {
@@ -250,21 +306,20 @@ func (b *Bot) HandleDimensionsSize(ctx context.Context, chatID int64, text strin
 }
 
 func (b *Bot) HandleDateSelection(ctx context.Context, chatID int64, text string) {
-	var selectedDate string
+	var date time.Time
 	
     if text == "Назад" {
-        msg := tgbotapi.NewMessage(chatID, "Введите размеры снова:")
-        msg.ReplyMarkup = b.CreateDimensionsKeyboard()
-        b.SendMessage(msg)
+        b.HandleCancel(ctx, chatID)
         return
     }
 
-	switch text {
-	case "Сегодня":
-		selectedDate = time.Now().Format("02.01.2006")
-	case "Завтра":
-		selectedDate = time.Now().Add(24 * time.Hour).Format("02.01.2006")
-	case "Выбрать дату вручную":
+	// A full day is refused by the capacity check below with a suggestion
+	switch choice := strings.TrimSuffix(text, FullDaySuffix); {
+	case choice == TodayButton:
+		date = time.Now()
+	case choice == TomorrowButton:
+		date = time.Now().AddDate(0, 0, 1)
+	case choice == ManualDateButton:
 		msg := tgbotapi.NewMessage(chatID, "Введите дату вручную в формате ДД.ММ.ГГГГ")
 		b.SendMessage(msg)
 		if err := b.state.SetStep(ctx, chatID, StepManualDateInput); err != nil {
@@ -273,11 +328,23 @@ func (b *Bot) HandleDateSelection(ctx context.Context, chatID int64, text string
 				zap.Error(err))
 		}
 		return
+	case strings.HasPrefix(choice, SuggestedDatePrefix):
+		suggested, err := ParseDueDate(strings.TrimPrefix(choice, SuggestedDatePrefix))
+		if err != nil {
+			b.SendError(chatID, err.Error())
+			return
+		}
+		date = suggested
 	default:
 		b.SendError(chatID, "Пожалуйста, выберите один из предложенных вариантов")
 		return
 	}
 
+	if !b.checkDateCapacity(ctx, chatID, date) {
+		return
+	}
+	selectedDate := date.Format("02.01.2006")
+
 	if err := b.state.SetDate(ctx, chatID, selectedDate); err != nil {
 		b.logger.Error("Failed to set date",
 			zap.Int64("chat_id", chatID),
@@ -290,22 +357,15 @@ func (b *Bot) HandleDateSelection(ctx context.Context, chatID int64, text string
 }
 
 func (b *Bot) HandleManualDateInput(ctx context.Context, chatID int64, text string) {
-	// Автокоррекция года
-    if len(text) == 8 { // формат ДД.ММ.ГГ
-        text = text[:6] + "20" + text[6:]
-    }
-    
-    _, err := time.Parse("02.01.2006", text)
+    inputDate, err := ParseDueDate(text)
     if err != nil {
-        b.SendError(chatID, "Неверный формат даты. Пожалуйста, введите дату в формате ДД.ММ.ГГГГ")
+        b.SendError(chatID, err.Error())
         return
     }
-
-	inputDate, _ := time.Parse("02.01.2006", text)
-	if inputDate.Before(time.Now().Truncate(24 * time.Hour)) {
-		b.SendError(chatID, "Пожалуйста, выберите дату в будущем")
-		return
-	}
+    if !b.checkDateCapacity(ctx, chatID, inputDate) {
+        return
+    }
+    text = inputDate.Format("02.01.2006")
 
 	if err := b.state.SetDate(ctx, chatID, text); err != nil {
 		b.logger.Error("Failed to set manual date",
@@ -324,12 +384,15 @@ func (b *Bot) ConfirmDateSelection(ctx context.Context, chatID int64, date strin
 	text := fmt.Sprintf("Отлично! Вы выбрали дату: %s\nДо этой даты %d рабочих дней (без учёта выходных и праздников).", date, days)
 
 	msg := tgbotapi.NewMessage(chatID, text)
-	msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
-		tgbotapi.NewKeyboardButtonRow(
-			tgbotapi.NewKeyboardButton("🔁 Сменить дату"),
-			tgbotapi.NewKeyboardButton("✅ Подтвердить дату"),
-		),
-	)
+	msg.ReplyMarkup = b.CreateDateConfirmationKeyboard()
+	if b.IsRushDate(date) {
+		msg.Text += fmt.Sprintf(
+			"\n\n⚡ Обычно на изготовление нужно не меньше %d рабочих дней. "+
+				"К этой дате мы можем выполнить заказ срочно — с наценкой %.0f%%, "+
+				"или выберите дату позже.",
+			b.cfg.Rush.MinWorkingDays, b.cfg.Rush.SurchargeRate*100)
+		msg.ReplyMarkup = b.CreateRushDateKeyboard(b.cfg.Rush.SurchargeRate)
+	}
 	b.SendMessage(msg)
 	if err := b.state.SetStep(ctx, chatID, StepDateConfirmation); err != nil {
 		b.logger.Error("Failed to set date confirmation state",
@@ -351,9 +414,44 @@ func (b *Bot) CalculateWorkingDays(date string) int {
 	return days
 }
 
+// IsRushDate reports whether date is too close for normal production
+func (b *Bot) IsRushDate(date string) bool {
+	return b.cfg.Rush.MinWorkingDays > 0 && b.CalculateWorkingDays(date) < b.cfg.Rush.MinWorkingDays
+}
+
 func (b *Bot) HandleDateConfirmation(ctx context.Context, chatID int64, text string) {
+    rushChosen := strings.HasPrefix(text, RushButtonPrefix)
+    if rushChosen {
+        text = "✅ Подтвердить дату"
+    }
+
     switch text {
     case "✅ Подтвердить дату":
+        state, err := b.state.GetFullState(ctx, chatID)
+        if err != nil {
+            b.logger.Error("Failed to get state for date confirmation",
+                zap.Int64("chat_id", chatID),
+                zap.Error(err))
+            b.SendError(chatID, "Ошибка при подтверждении даты")
+            return
+        }
+        rush := b.IsRushDate(state.Date)
+        if rush && !rushChosen {
+            b.SendError(chatID, "До этой даты слишком мало времени: выберите срочное выполнение или другую дату")
+            return
+        }
+        if err := b.state.SetRush(ctx, chatID, rush); err != nil {
+            b.logger.Error("Failed to set rush",
+                zap.Int64("chat_id", chatID),
+                zap.Error(err))
+            b.SendError(chatID, "Ошибка при подтверждении даты")
+            return
+        }
+
+        if b.returnToReview(ctx, chatID) {
+            return
+        }
+
         msg := tgbotapi.NewMessage(chatID, "Как вам удобно предоставить контактные данные?")
         msg.ReplyMarkup = b.CreatePhoneInputKeyboard()
         b.SendMessage(msg)
@@ -367,7 +465,7 @@ func (b *Bot) HandleDateConfirmation(ctx context.Context, chatID int64, text str
     case "🔁 Сменить дату":
         // Go back to date selection
         msg := tgbotapi.NewMessage(chatID, "Когда вам удобно выполнить заказ?")
-        msg.ReplyMarkup = b.CreateDateSelectionKeyboard()
+        msg.ReplyMarkup = b.dateSelectionKeyboard(ctx, chatID)
         b.SendMessage(msg)
         
         if err := b.state.SetStep(ctx, chatID, StepDateSelection); err != nil {
@@ -423,23 +521,311 @@ func (b *Bot) HandlePhoneNumber(ctx context.Context, chatID int64, text string)
         zap.String("normalized", normalized),
         zap.Bool("is_valid", IsValidPhoneNumber(normalized)))
 
-    // Create and save the order
-    _, err := b.CreateOrder(ctx, chatID, normalized)
+    if err := b.state.SetPhoneNumber(ctx, chatID, normalized); err != nil {
+        b.logger.Error("Failed to save phone number",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+        b.SendError(chatID, "Ошибка при сохранении номера телефона")
+        return
+    }
+
+    // Let the customer check everything before the order is saved
+    b.ShowOrderReview(ctx, chatID)
+}
+
+// ShowOrderReview sends the full order summary with the price and edit buttons
+func (b *Bot) ShowOrderReview(ctx context.Context, chatID int64) {
+    state, err := b.state.GetFullState(ctx, chatID)
     if err != nil {
-        b.logger.Error("Failed to create order",
+        b.logger.Error("Failed to get order state for review",
             zap.Int64("chat_id", chatID),
-            zap.String("phone", normalized),
             zap.Error(err))
-        b.SendError(chatID, "Ошибка при оформлении заказа. Пожалуйста, попробуйте позже.")
+        b.HandleError(ctx, chatID, "Не удалось получить данные заказа. Начните заново.")
+        return
+    }
+
+    if state.WidthCM <= 0 || state.HeightCM <= 0 || state.Date == "" || state.PhoneNumber == "" {
+        b.HandleError(ctx, chatID, "Данные заказа неполные. Пожалуйста, начните заново.")
+        return
+    }
+
+    // The way of getting the order is the last thing asked before the review
+    if state.Fulfilment.Method == "" {
+        if err := b.state.SetEditing(ctx, chatID, false); err != nil {
+            b.logger.Error("Failed to reset editing flag",
+                zap.Int64("chat_id", chatID),
+                zap.Error(err))
+        }
+        b.StartFulfilment(ctx, chatID)
+        return
+    }
+
+    cart := state.CartItems()
+    promo := b.cartPromo(ctx, chatID, state)
+    allowance := NewPromoAllowance(promo)
+    loyaltyRate := b.loyaltyRate(ctx, chatID)
+    var (
+        lines           strings.Builder
+        total           money.Kopecks
+        rush            money.Kopecks
+        loyaltyDiscount money.Kopecks
+        promoDiscount   money.Kopecks
+        priced          = true
+    )
+    for i, item := range cart {
+        textureName := item.Service
+        priceText := "цена будет рассчитана менеджером"
+        if texture, err := b.getItemTexture(ctx, item); err == nil {
+            textureName = texture.Name
+            params := allowance.Params(state.ItemPriceParams(item), texture.Name)
+            params.LoyaltyRate = loyaltyRate
+            priceDetails, err := b.CalculateOrderPrice(ctx, params, texture)
+            if err != nil {
+                b.logger.Error("Failed to calculate price for review",
+                    zap.Int64("chat_id", chatID),
+                    zap.Error(err))
+                b.SendError(chatID, "Ошибка при расчете цены")
+                return
+            }
+            allowance.Use(priceDetails.PromoDiscount)
+            total += priceDetails.FinalPrice
+            rush += priceDetails.RushSurcharge
+            loyaltyDiscount += priceDetails.LoyaltyDiscount
+            promoDiscount += priceDetails.PromoDiscount
+            priceText = fmt.Sprintf("%s ₽", priceDetails.FinalPrice)
+            if priceDetails.Discount > 0 {
+                priceText += fmt.Sprintf(" (скидка %.0f%%: −%s ₽)",
+                    priceDetails.DiscountRate*100, priceDetails.Discount)
+            }
+        } else {
+            priced = false
+            if item.ServiceType != "" {
+                textureName = item.ServiceType
+            }
+        }
+        lines.WriteString(fmt.Sprintf("%d. 🧵 %s, 📏 %s × %d шт. — %s\n",
+            i+1, textureName, FormatItemSize(item.Shape, item.WidthCM, item.HeightCM, item.CornerRadiusCM),
+            item.PriceParams().Quantity, priceText))
+    }
+
+    if rush > 0 {
+        lines.WriteString(fmt.Sprintf("⚡ Срочность: +%s ₽ (включено в цены позиций)\n", rush))
+    }
+    if loyaltyDiscount > 0 {
+        lines.WriteString(fmt.Sprintf("💎 Скидка постоянного клиента %.0f%%: −%s ₽ (включено в цены позиций)\n",
+            loyaltyRate*100, loyaltyDiscount))
+    }
+    if promo != nil {
+        if promoDiscount > 0 {
+            lines.WriteString(fmt.Sprintf("🎟 Промокод %s: −%s ₽ (включено в цены позиций)\n", promo.Code, promoDiscount))
+        } else {
+            lines.WriteString(fmt.Sprintf("🎟 Промокод %s не действует на выбранные позиции\n", promo.Code))
+        }
+    }
+
+    // Without every price the manager settles the minimum and rounding
+    if priced {
+        if adjustment := b.orderAdjustment(total); adjustment != 0 {
+            lines.WriteString(fmt.Sprintf("🧾 Округление и минимальная сумма заказа: %+.2f ₽\n", adjustment.Rubles()))
+            total += adjustment
+        }
+    }
+
+    deliveryFee := b.DeliveryFee(state.Fulfilment.Method, total)
+    if deliveryFee > 0 {
+        lines.WriteString(fmt.Sprintf("🚚 Доставка: %s ₽\n", deliveryFee))
+    }
+
+    priceLine := "💰 Стоимость: будет рассчитана менеджером"
+    if priced {
+        priceLine = fmt.Sprintf("💰 Итоговая цена: %s ₽", total+deliveryFee)
+    }
+    if len(state.Attachments) > 0 {
+        lines.WriteString(fmt.Sprintf("📎 Файлов: %d\n", len(state.Attachments)))
+    }
+
+    if err := b.state.SetEditing(ctx, chatID, false); err != nil {
+        b.logger.Error("Failed to reset editing flag",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+    }
+
+    msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
+        "📝 Проверьте ваш заказ:\n\n"+
+            "%s\n"+
+            "🗓 Срок выполнения: %s (%d раб. дней)%s\n"+
+            "📱 Контакт: %s\n"+
+            "📦 Получение: %s\n\n"+
+            "%s\n\n"+
+            "Если всё верно, нажмите «✅ Подтвердить заказ» или измените нужный пункт. "+
+            "Кнопки «✏️ Текстура», «✏️ Форма» и «✏️ Размер» меняют последнюю позицию.",
+        lines.String(),
+        state.Date, b.CalculateWorkingDays(state.Date), FormatRushMark(state.Rush),
+        FormatPhoneNumber(state.PhoneNumber),
+        state.Fulfilment,
+        priceLine,
+    ))
+    msg.ReplyMarkup = b.CreateConfirmationKeyboard(len(cart), !state.CurrentItem().IsCustomTexture())
+    b.SendMessage(msg)
+
+    if err := b.state.SetStep(ctx, chatID, StepOrderConfirmation); err != nil {
+        b.logger.Error("Failed to set order confirmation state",
+            zap.Int64("chat_id", chatID),
+            zap.Error(err))
+    }
+}
+
+func (b *Bot) HandleOrderConfirmation(ctx context.Context, chatID int64, text string) {
+    var (
+        prompt   string
+        keyboard tgbotapi.ReplyKeyboardMarkup
+        step     string
+    )
+
+    switch text {
+    case "✅ Подтвердить заказ":
+        state, err := b.state.GetFullState(ctx, chatID)
+        if err != nil {
+            b.logger.Error("Failed to get order state",
+                zap.Int64("chat_id", chatID),
+                zap.Error(err))
+            b.SendError(chatID, "Ошибка при оформлении заказа. Пожалуйста, попробуйте позже.")
+            return
+        }
+
+        // A texture outside the catalogue cannot be priced automatically
+        if state.CurrentItem().IsCustomTexture() {
+            if err := b.SubmitQuoteRequest(ctx, chatID, state); err != nil {
+                b.logger.Error("Failed to submit quote request",
+                    zap.Int64("chat_id", chatID),
+                    zap.Error(err))
+                b.SendError(chatID, "Ошибка при отправке запроса. Пожалуйста, попробуйте позже.")
+                return
+            }
+            if err := b.state.ClearState(ctx, chatID); err != nil {
+                b.logger.Error("Failed to clear user state",
+                    zap.Int64("chat_id", chatID),
+                    zap.Error(err))
+            }
+            return
+        }
+
+        // Create and save the order
+        if _, err := b.CreateOrder(ctx, chatID, state.PhoneNumber); err != nil {
+            // The promo code ran out or expired since the review
+            if IsPromoCodeError(err) {
+                b.dropPromoCode(ctx, chatID, state.PromoCode, err)
+                b.ShowOrderReview(ctx, chatID)
+                return
+            }
+            // Other orders took the date since the customer picked it
+            if errors.Is(err, storage.ErrDayFull) {
+                if date, perr := time.Parse("02.01.2006", state.Date); perr == nil && !b.checkDateCapacity(ctx, chatID, date) {
+                    return
+                }
+            }
+            b.logger.Error("Failed to create order",
+                zap.Int64("chat_id", chatID),
+                zap.String("phone", state.PhoneNumber),
+                zap.Error(err))
+            b.SendError(chatID, "Ошибка при оформлении заказа. Пожалуйста, попробуйте позже.")
+            return
+        }
+
+        // Clear user state but DON'T send another confirmation
+        if err := b.state.ClearState(ctx, chatID); err != nil {
+            b.logger.Error("Failed to clear user state",
+                zap.Int64("chat_id", chatID),
+                zap.Error(err))
+        }
+        return
+
+    case "✏️ Текстура":
+        prompt, keyboard, step = "Выберите тип услуги:", b.CreateServiceTypeKeyboard(), StepServiceType
+    case "✏️ Форма":
+        prompt, keyboard, step = "Выберите форму изделия:", b.CreateShapeKeyboard(), StepShape
+    case "✏️ Размер":
+        shape := ""
+        if state, err := b.state.GetFullState(ctx, chatID); err == nil {
+            shape = state.Shape
+        }
+        prompt, keyboard, step = DimensionsPrompt(shape), b.CreateDimensionsKeyboard(shape), StepDimensions
+    case "✏️ Количество":
+        prompt = fmt.Sprintf("Сколько одинаковых изделий нужно? Введите число от 1 до %d", MaxQuantity)
+        keyboard, step = b.CreateQuantityKeyboard(), StepQuantity
+    case "✏️ Дата":
+        prompt, keyboard, step = "Когда вам удобно выполнить заказ?", b.dateSelectionKeyboard(ctx, chatID), StepDateSelection
+    case "✏️ Контакт":
+        prompt, keyboard, step = "Как вам удобно предоставить контактные данные?", b.CreatePhoneInputKeyboard(), StepContactMethod
+    case "✏️ Получение":
+        prompt = "Как вы хотите получить заказ?\n\n" + b.courierFeeNote()
+        keyboard, step = b.CreateFulfilmentKeyboard(), StepFulfilment
+    case "📎 Прикрепить файл":
+        b.StartOrderAttachments(ctx, chatID)
+        return
+    case "➕ Добавить позицию":
+        if state, err := b.state.GetFullState(ctx, chatID); err == nil && state.CurrentItem().IsCustomTexture() {
+            b.SendError(chatID, "Заказ с индивидуальной текстурой оформляется отдельным запросом на расчёт")
+            return
+        }
+        if err := b.state.AddCurrentItemToCart(ctx, chatID); err != nil {
+            b.logger.Error("Failed to add item to cart",
+                zap.Int64("chat_id", chatID),
+                zap.Error(err))
+            b.SendError(chatID, "Произошла ошибка, попробуйте позже")
+            return
+        }
+        msg := tgbotapi.NewMessage(chatID, "Новая позиция. Выберите тип услуги:")
+        msg.ReplyMarkup = b.CreateServiceTypeKeyboard()
+        b.SendMessage(msg)
+        if err := b.state.SetStep(ctx, chatID, StepServiceType); err != nil {
+            b.logger.Error("Failed to set service type state",
+                zap.Int64("chat_id", chatID),
+                zap.Error(err))
+        }
+        return
+    case "🗑 Убрать позицию":
+        if err := b.state.RemoveCurrentItem(ctx, chatID); err != nil {
+            b.SendError(chatID, "В заказе должна остаться хотя бы одна позиция")
+            return
+        }
+        b.ShowOrderReview(ctx, chatID)
+        return
+    case "❌ Отмена":
+        b.HandleCancel(ctx, chatID)
+        return
+    default:
+        b.SendError(chatID, "Пожалуйста, используйте кнопки для продолжения")
         return
     }
 
-    // Clear user state but DON'T send another confirmation
-    if err := b.state.ClearState(ctx, chatID); err != nil {
-        b.logger.Error("Failed to clear user state",
+    // Jump back to the chosen step; it returns to the review once the field is saved
+    if err := b.state.SetEditing(ctx, chatID, true); err != nil {
+        b.logger.Error("Failed to set editing flag",
             zap.Int64("chat_id", chatID),
             zap.Error(err))
     }
+
+    msg := tgbotapi.NewMessage(chatID, prompt)
+    msg.ReplyMarkup = keyboard
+    b.SendMessage(msg)
+
+    if err := b.state.SetStep(ctx, chatID, step); err != nil {
+        b.logger.Error("Failed to set edit step",
+            zap.Int64("chat_id", chatID),
+            zap.String("step", step),
+            zap.Error(err))
+    }
+}
+
+// returnToReview shows the order summary again if the customer came from it to edit a field
+func (b *Bot) returnToReview(ctx context.Context, chatID int64) bool {
+    state, err := b.state.GetFullState(ctx, chatID)
+    if err != nil || !state.Editing {
+        return false
+    }
+    b.ShowOrderReview(ctx, chatID)
+    return true
 }
 
 func (b *Bot) HandleDeleteRequest(ctx context.Context, chatID int64) {
@@ -40,29 +40,20 @@ func (b *Bot) HandleTextureSelection(ctx context.Context, callback *tgbotapi.Cal
         return
     }
 
-    // Create temporary pricing config using texture price
-    pricingConfig := PricingConfig{
-        LeatherPricePerDM2:    texture.PricePerDM2,
-        ProcessingCostPerDM2:  b.cfg.Pricing.ProcessingCostPerDM2,
-        PaymentCommissionRate: b.cfg.Pricing.PaymentCommissionRate,
-        SalesTaxRate:          b.cfg.Pricing.SalesTaxRate,
-        MarkupMultiplier:      b.cfg.Pricing.MarkupMultiplier,
-    }
-
-    // Calculate full price details
-    priceDetails, err := CalculatePrice(width, height, pricingConfig)
+    // Calculate price
+    priceDetails, err := b.CalculateOrderPrice(ctx, PriceParams{WidthCM: width, HeightCM: height, Quantity: 1}, texture)
     if err != nil {
         b.logger.Error("Failed to calculate price",
             zap.Int("width", width),
             zap.Int("height", height),
-            zap.Any("pricing_config", pricingConfig),
+            zap.String("texture", texture.Name),
             zap.Error(err))
         b.SendError(chatID, "Ошибка при расчете цены")
         return
     }
 
     // Save texture selection to state
-    if err := b.state.SetTexture(ctx, chatID, textureID, priceDetails["final_price"]); err != nil {
+    if err := b.state.SetTexture(ctx, chatID, textureID, priceDetails.FinalPrice.Rubles()); err != nil {
         b.logger.Error("Failed to set texture",
             zap.Int64("chat_id", chatID),
             zap.Error(err))
@@ -76,10 +67,10 @@ func (b *Bot) HandleTextureSelection(ctx context.Context, callback *tgbotapi.Cal
         fmt.Sprintf(
             "Вы выбрали текстуру: %s\n%s\n\nКогда вам удобно выполнить заказ?",
             texture.Name,
-            FormatSimplePriceBreakdown(width, height, priceDetails["final_price"]),
+            FormatSimplePriceBreakdown(width, height, priceDetails.FinalPrice),
         ),
     )
-    msg.ReplyMarkup = b.CreateDateSelectionKeyboard()
+    msg.ReplyMarkup = b.dateSelectionKeyboard(ctx, chatID)
     b.SendMessage(msg)
 
     // Update user step
@@ -134,26 +125,19 @@ func (b *Bot) HandleTextureSelectionMessage(ctx context.Context, chatID int64, t
     }
 
     // Calculate price
-    pricingConfig := PricingConfig{
-        LeatherPricePerDM2:    texture.PricePerDM2,
-        ProcessingCostPerDM2:  b.cfg.Pricing.ProcessingCostPerDM2,
-        PaymentCommissionRate: b.cfg.Pricing.PaymentCommissionRate,
-        SalesTaxRate:          b.cfg.Pricing.SalesTaxRate,
-        MarkupMultiplier:      b.cfg.Pricing.MarkupMultiplier,
-    }
-    priceDetails, err := CalculatePrice(width, height, pricingConfig)
+    priceDetails, err := b.CalculateOrderPrice(ctx, PriceParams{WidthCM: width, HeightCM: height, Quantity: 1}, texture)
     if err != nil {
         b.logger.Error("Failed to calculate price",
             zap.Int("width", width),
             zap.Int("height", height),
-            zap.Any("pricing_config", pricingConfig),
+            zap.String("texture", texture.Name),
             zap.Error(err))
         b.SendError(chatID, "Ошибка при расчете цены")
         return
     }
 
     // Save selection
-    if err := b.state.SetTexture(ctx, chatID, texture.ID, priceDetails["final_price"]); err != nil {
+    if err := b.state.SetTexture(ctx, chatID, texture.ID, priceDetails.FinalPrice.Rubles()); err != nil {
         b.logger.Error("Failed to set texture",
             zap.Int64("chat_id", chatID),
             zap.Error(err))
@@ -167,10 +151,10 @@ func (b *Bot) HandleTextureSelectionMessage(ctx context.Context, chatID int64, t
         fmt.Sprintf(
             "Вы выбрали текстуру: %s\n%s\n\nКогда вам удобно выполнить заказ?",
             texture.Name,
-            FormatSimplePriceBreakdown(width, height, priceDetails["final_price"]),
+            FormatSimplePriceBreakdown(width, height, priceDetails.FinalPrice),
         ),
     )
-    msg.ReplyMarkup = b.CreateDateSelectionKeyboard()
+    msg.ReplyMarkup = b.dateSelectionKeyboard(ctx, chatID)
     b.SendMessage(msg)
 
     // Update user step
@@ -3,6 +3,8 @@ package bot
 import (
 	"adtime-bot/internal/storage"
 	"fmt"
+	"slices"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
@@ -32,15 +34,222 @@ func (b *Bot) CreateMainMenuKeyboardAgreedTPA() tgbotapi.ReplyKeyboardMarkup {
     )
 }
 
-func (b *Bot) CreateConfirmationKeyboard() tgbotapi.ReplyKeyboardMarkup {
-	return tgbotapi.NewReplyKeyboard(
+// CreateConfirmationKeyboard offers edits of the reviewed order. Quote
+// requests hold a single item, so canAddItem hides the cart button for them.
+func (b *Bot) CreateConfirmationKeyboard(itemCount int, canAddItem bool) tgbotapi.ReplyKeyboardMarkup {
+	rows := [][]tgbotapi.KeyboardButton{
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton("✏️ Текстура"),
+			tgbotapi.NewKeyboardButton("✏️ Форма"),
+			tgbotapi.NewKeyboardButton("✏️ Размер"),
+			tgbotapi.NewKeyboardButton("✏️ Количество"),
+		),
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton("✏️ Дата"),
+			tgbotapi.NewKeyboardButton("✏️ Контакт"),
+			tgbotapi.NewKeyboardButton("✏️ Получение"),
+		),
+	}
+
+	cartRow := []tgbotapi.KeyboardButton{tgbotapi.NewKeyboardButton("📎 Прикрепить файл")}
+	if canAddItem {
+		cartRow = append(cartRow, tgbotapi.NewKeyboardButton("➕ Добавить позицию"))
+	}
+	if itemCount > 1 {
+		cartRow = append(cartRow, tgbotapi.NewKeyboardButton("🗑 Убрать позицию"))
+	}
+	rows = append(rows, cartRow)
+
+	rows = append(rows,
 		tgbotapi.NewKeyboardButtonRow(
-			tgbotapi.NewKeyboardButton("🔁 Сменить дату"),
 			tgbotapi.NewKeyboardButton("✅ Подтвердить заказ"),
 		),
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton("❌ Отмена"),
+		),
+	)
+	return tgbotapi.NewReplyKeyboard(rows...)
+}
+
+func (b *Bot) CreateAttachmentsKeyboard() tgbotapi.ReplyKeyboardMarkup {
+	return tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton("✅ Готово"),
+		),
 	)
 }
 
+func (b *Bot) CreateCancelReasonKeyboard() tgbotapi.ReplyKeyboardMarkup {
+	return tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton("Пропустить"),
+		),
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton("Назад"),
+		),
+	)
+}
+
+// CreateOrderHistoryKeyboard opens the card of every order on the page and
+// moves between pages
+func (b *Bot) CreateOrderHistoryKeyboard(page *storage.OrdersPage) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, order := range page.Orders {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("🆔 %s · %s", order.Number(), StatusLabel(order.Status)),
+				"order_card:"+order.Number(),
+			),
+		))
+	}
+
+	if nav := pageNavigationRow(page, "history"); len(nav) > 0 {
+		rows = append(rows, nav)
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// CreateSearchResultsKeyboard gives every found order a row with its card and
+// a row of quick actions
+func (b *Bot) CreateSearchResultsKeyboard(page *storage.OrdersPage) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, order := range page.Orders {
+		rows = append(rows,
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(
+					fmt.Sprintf("🆔 %s · %s", order.Number(), StatusLabel(order.Status)),
+					"order_card:"+order.Number(),
+				),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🔄 Статус", "status_menu:"+order.Number()),
+				tgbotapi.NewInlineKeyboardButtonData("📤 Excel", "export_order:"+order.Number()),
+				tgbotapi.NewInlineKeyboardButtonData("💬 Чат", "order_message:"+order.Number()),
+			),
+		)
+	}
+
+	if nav := pageNavigationRow(page, "find"); len(nav) > 0 {
+		rows = append(rows, nav)
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// pageNavigationRow links to the newer and older neighbours of a page; the
+// callback data is "<prefix>:newer:<id>" or "<prefix>:older:<id>"
+func pageNavigationRow(page *storage.OrdersPage, prefix string) []tgbotapi.InlineKeyboardButton {
+	var nav []tgbotapi.InlineKeyboardButton
+	if len(page.Orders) == 0 {
+		return nav
+	}
+	if page.HasNewer {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(
+			"◀️ Новее", fmt.Sprintf("%s:newer:%d", prefix, page.Orders[0].ID)))
+	}
+	if page.HasOlder {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(
+			"Старее ▶️", fmt.Sprintf("%s:older:%d", prefix, page.Orders[len(page.Orders)-1].ID)))
+	}
+	return nav
+}
+
+// CreateOrderCardKeyboard offers the actions available to the customer for
+// one order: repeat, question and files always, edit and cancel while allowed
+func (b *Bot) CreateOrderCardKeyboard(order storage.Order) tgbotapi.InlineKeyboardMarkup {
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔁 Повторить", "repeat_order:"+order.Number()),
+			tgbotapi.NewInlineKeyboardButtonData("💬 Вопрос", "order_message:"+order.Number()),
+			tgbotapi.NewInlineKeyboardButtonData("📎 Файл", "attach_order:"+order.Number()),
+		),
+	}
+
+	var row []tgbotapi.InlineKeyboardButton
+	if slices.Contains(storage.CustomerEditableStatuses, order.Status) {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(
+			"✏️ Изменить", "edit_order:"+order.Number()))
+	}
+	if storage.CanCustomerCancel(order.Status) {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(
+			"❌ Отменить", "cancel_order:"+order.Number()))
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("📋 Все заказы", "history:first"),
+	))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// CreateOrderMessageKeyboard lets either side of an order conversation
+// answer; orderRef is the order's public code
+func (b *Bot) CreateOrderMessageKeyboard(orderRef string) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("↩️ Ответить", "order_message:"+orderRef),
+		),
+	)
+}
+
+// CreateQuotePriceKeyboard lets an admin price a quote request
+func (b *Bot) CreateQuotePriceKeyboard(quoteID int64) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("💰 Указать цену", fmt.Sprintf("quote_price:%d", quoteID)),
+		),
+	)
+}
+
+// CreateQuoteOfferKeyboard lets the customer answer a priced quote
+func (b *Bot) CreateQuoteOfferKeyboard(quoteID int64) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Принять", fmt.Sprintf("quote_accept:%d", quoteID)),
+			tgbotapi.NewInlineKeyboardButtonData("❌ Отказаться", fmt.Sprintf("quote_decline:%d", quoteID)),
+		),
+	)
+}
+
+// CreateEditOrderKeyboard offers the editable fields; with several items
+// the size and texture buttons are numbered per item
+func (b *Bot) CreateEditOrderKeyboard(itemCount int) tgbotapi.ReplyKeyboardMarkup {
+	var rows [][]tgbotapi.KeyboardButton
+	if itemCount <= 1 {
+		rows = append(rows, tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton("📏 Размер"),
+			tgbotapi.NewKeyboardButton("🧵 Текстура"),
+		))
+	} else {
+		for i := 1; i <= itemCount; i++ {
+			rows = append(rows, tgbotapi.NewKeyboardButtonRow(
+				tgbotapi.NewKeyboardButton(fmt.Sprintf("📏 Размер #%d", i)),
+				tgbotapi.NewKeyboardButton(fmt.Sprintf("🧵 Текстура #%d", i)),
+			))
+		}
+	}
+	rows = append(rows, tgbotapi.NewKeyboardButtonRow(
+		tgbotapi.NewKeyboardButton("🗓 Дата"),
+		tgbotapi.NewKeyboardButton("❌ Отмена"),
+	))
+	return tgbotapi.NewReplyKeyboard(rows...)
+}
+
+// CreateTextureNamesKeyboard lists catalogue textures as reply buttons
+func (b *Bot) CreateTextureNamesKeyboard(textures []storage.Texture) tgbotapi.ReplyKeyboardMarkup {
+	var rows [][]tgbotapi.KeyboardButton
+	for _, texture := range textures {
+		rows = append(rows, tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton(texture.Name),
+		))
+	}
+	rows = append(rows, tgbotapi.NewKeyboardButtonRow(
+		tgbotapi.NewKeyboardButton("Назад"),
+	))
+	return tgbotapi.NewReplyKeyboard(rows...)
+}
+
 func (b *Bot) CreateContactRequestKeyboard() tgbotapi.ReplyKeyboardMarkup {
 	return tgbotapi.NewReplyKeyboard(
 		tgbotapi.NewKeyboardButtonRow(
@@ -68,33 +277,127 @@ func (b *Bot) CreateOrderInitKeyboard() tgbotapi.ReplyKeyboardMarkup {
 	)
 }
 
-func (b *Bot) CreateDateSelectionKeyboard() tgbotapi.ReplyKeyboardMarkup {
-    return tgbotapi.NewReplyKeyboard(
+// Date selection buttons. A full day keeps its button with FullDaySuffix so
+// the customer sees why it is not available.
+const (
+    TodayButton         = "Сегодня"
+    TomorrowButton      = "Завтра"
+    ManualDateButton    = "Выбрать дату вручную"
+    FullDaySuffix       = " — мест нет"
+    SuggestedDatePrefix = "📅 "
+)
+
+// DateAvailability tells the date keyboard which quick choices are full and
+// which free date to offer instead
+type DateAvailability struct {
+    TodayFull    bool
+    TomorrowFull bool
+    Suggested    *time.Time
+}
+
+func (b *Bot) CreateDateSelectionKeyboard(availability DateAvailability) tgbotapi.ReplyKeyboardMarkup {
+    today, tomorrow := TodayButton, TomorrowButton
+    if availability.TodayFull {
+        today += FullDaySuffix
+    }
+    if availability.TomorrowFull {
+        tomorrow += FullDaySuffix
+    }
+
+    rows := [][]tgbotapi.KeyboardButton{
         tgbotapi.NewKeyboardButtonRow(
-            tgbotapi.NewKeyboardButton("Сегодня"),
-            tgbotapi.NewKeyboardButton("Завтра"),
+            tgbotapi.NewKeyboardButton(today),
+            tgbotapi.NewKeyboardButton(tomorrow),
         ),
+    }
+    if availability.Suggested != nil {
+        rows = append(rows, tgbotapi.NewKeyboardButtonRow(
+            tgbotapi.NewKeyboardButton(SuggestedDatePrefix+availability.Suggested.Format("02.01.2006")),
+        ))
+    }
+    rows = append(rows,
         tgbotapi.NewKeyboardButtonRow(
-            tgbotapi.NewKeyboardButton("Выбрать дату вручную"),
+            tgbotapi.NewKeyboardButton(ManualDateButton),
         ),
         tgbotapi.NewKeyboardButtonRow(
             tgbotapi.NewKeyboardButton("Назад"),
         ),
     )
+    return tgbotapi.NewReplyKeyboard(rows...)
+}
+
+// shapeButtons lists the shape keyboard in display order
+var shapeButtons = []struct {
+    label string
+    shape string
+}{
+    {"▭ Прямоугольник", storage.ShapeRectangle},
+    {"⚪ Круг", storage.ShapeCircle},
+    {"⬭ Овал", storage.ShapeOval},
+    {"▢ Скруглённые углы", storage.ShapeRoundedRect},
 }
 
-func (b *Bot) CreateDimensionsKeyboard() tgbotapi.ReplyKeyboardMarkup {
+// ShapeFromButton returns the shape chosen with a shape keyboard button
+func ShapeFromButton(text string) (string, bool) {
+    for _, button := range shapeButtons {
+        if button.label == text {
+            return button.shape, true
+        }
+    }
+    return "", false
+}
+
+func (b *Bot) CreateShapeKeyboard() tgbotapi.ReplyKeyboardMarkup {
     return tgbotapi.NewReplyKeyboard(
         tgbotapi.NewKeyboardButtonRow(
-            tgbotapi.NewKeyboardButton("30 40"),
-            tgbotapi.NewKeyboardButton("50 40"),
+            tgbotapi.NewKeyboardButton(shapeButtons[0].label),
+            tgbotapi.NewKeyboardButton(shapeButtons[1].label),
+        ),
+        tgbotapi.NewKeyboardButtonRow(
+            tgbotapi.NewKeyboardButton(shapeButtons[2].label),
+            tgbotapi.NewKeyboardButton(shapeButtons[3].label),
+        ),
+        tgbotapi.NewKeyboardButtonRow(
+            tgbotapi.NewKeyboardButton("Назад"),
         ),
+    )
+}
+
+// CreateDimensionsKeyboard suggests typical sizes in the format the shape expects
+func (b *Bot) CreateDimensionsKeyboard(shape string) tgbotapi.ReplyKeyboardMarkup {
+    presets := []string{"30 40", "50 40"}
+    switch shape {
+    case storage.ShapeCircle:
+        presets = []string{"20", "30", "40"}
+    case storage.ShapeRoundedRect:
+        presets = []string{"30 40 3", "50 40 5"}
+    }
+
+    row := make([]tgbotapi.KeyboardButton, 0, len(presets))
+    for _, preset := range presets {
+        row = append(row, tgbotapi.NewKeyboardButton(preset))
+    }
+    return tgbotapi.NewReplyKeyboard(
+        row,
         tgbotapi.NewKeyboardButtonRow(
             tgbotapi.NewKeyboardButton("Назад"),
         ),
     )
 }
 
+func (b *Bot) CreateQuantityKeyboard() tgbotapi.ReplyKeyboardMarkup {
+    return tgbotapi.NewReplyKeyboard(
+        tgbotapi.NewKeyboardButtonRow(
+            tgbotapi.NewKeyboardButton("1"),
+            tgbotapi.NewKeyboardButton("2"),
+            tgbotapi.NewKeyboardButton("5"),
+            tgbotapi.NewKeyboardButton("10"),
+        ),
+        tgbotapi.NewKeyboardButtonRow(
+            tgbotapi.NewKeyboardButton("Назад"),
+        ),
+    )
+}
 
 func (b *Bot) CreateDateConfirmationKeyboard() tgbotapi.ReplyKeyboardMarkup {
 	return tgbotapi.NewReplyKeyboard(
@@ -105,6 +408,52 @@ func (b *Bot) CreateDateConfirmationKeyboard() tgbotapi.ReplyKeyboardMarkup {
 	)
 }
 
+// RushButtonPrefix starts the rush option button; the rest is the surcharge
+const RushButtonPrefix = "⚡ Срочно"
+
+// CreateRushDateKeyboard is shown for a date too close for normal production:
+// the customer takes the rush option or picks another date
+func (b *Bot) CreateRushDateKeyboard(surchargeRate float64) tgbotapi.ReplyKeyboardMarkup {
+	return tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton(fmt.Sprintf("%s (+%.0f%%)", RushButtonPrefix, surchargeRate*100)),
+		),
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton("🔁 Сменить дату"),
+		),
+	)
+}
+
+// CreateFulfilmentKeyboard offers every pickup point and courier delivery
+func (b *Bot) CreateFulfilmentKeyboard() tgbotapi.ReplyKeyboardMarkup {
+	var rows [][]tgbotapi.KeyboardButton
+	for _, point := range b.cfg.Delivery.PickupPoints {
+		rows = append(rows, tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton(PickupButtonPrefix+point),
+		))
+	}
+	rows = append(rows,
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton(CourierButton),
+		),
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton("Назад"),
+		),
+	)
+	return tgbotapi.NewReplyKeyboard(rows...)
+}
+
+func (b *Bot) CreateDeliveryAddressKeyboard() tgbotapi.ReplyKeyboardMarkup {
+	return tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButtonLocation("📍 Отправить геопозицию"),
+		),
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton("Назад"),
+		),
+	)
+}
+
 func (b *Bot) CreatePhoneInputKeyboard() tgbotapi.ReplyKeyboardMarkup {
 	return tgbotapi.NewReplyKeyboard(
 		tgbotapi.NewKeyboardButtonRow(
@@ -161,3 +510,59 @@ func (b *Bot) CreateTextureSelectionKeyboard(textures []storage.Texture) tgbotap
     
     return tgbotapi.NewInlineKeyboardMarkup(rows...)
 }
+
+var statusActionLabels = map[string]string{
+    storage.StatusConfirmed:    "👍 Подтвердить",
+    storage.StatusInProduction: "🔄 В производство",
+    storage.StatusReady:        "📦 Готов",
+    storage.StatusDelivered:    "✅ Выдан",
+    storage.StatusCancelled:    "❌ Отменить",
+}
+
+// CreateStatusKeyboard offers the admin every status reachable from the
+// current one; orderRef is the order's public code
+func (b *Bot) CreateStatusKeyboard(orderRef string, currentStatus string) tgbotapi.InlineKeyboardMarkup {
+    var row []tgbotapi.InlineKeyboardButton
+    for _, status := range storage.AllowedStatusTransitions(currentStatus) {
+        row = append(row, tgbotapi.NewInlineKeyboardButtonData(
+            statusActionLabels[status],
+            fmt.Sprintf("status:%s:%s", orderRef, status),
+        ))
+    }
+    return tgbotapi.NewInlineKeyboardMarkup(row)
+}
+
+// CreateAdminOrderKeyboard is attached to new-order notifications for admins
+func (b *Bot) CreateAdminOrderKeyboard(order storage.Order) tgbotapi.InlineKeyboardMarkup {
+    keyboard := b.CreateStatusKeyboard(order.Number(), order.Status)
+    if slices.Contains(storage.AdminEditableStatuses, order.Status) {
+        keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, tgbotapi.NewInlineKeyboardRow(
+            tgbotapi.NewInlineKeyboardButtonData("✏️ Изменить", "edit_order:"+order.Number()),
+        ))
+    }
+    if order.IsOpen() {
+        keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, tgbotapi.NewInlineKeyboardRow(
+            tgbotapi.NewInlineKeyboardButtonData("👷 Назначить мастера", "assign_menu:"+order.Number()),
+        ))
+    }
+    keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, tgbotapi.NewInlineKeyboardRow(
+        tgbotapi.NewInlineKeyboardButtonData("💬 Написать клиенту", "order_message:"+order.Number()),
+    ))
+    return keyboard
+}
+
+// CreateAssignKeyboard lists the craftsmen an order can be given to, one per
+// row, marking the current assignee
+func (b *Bot) CreateAssignKeyboard(orderRef string, craftsmen []storage.StaffMember, assignedTo *int64) tgbotapi.InlineKeyboardMarkup {
+    var rows [][]tgbotapi.InlineKeyboardButton
+    for _, craftsman := range craftsmen {
+        label := craftsman.Name
+        if assignedTo != nil && *assignedTo == craftsman.UserID {
+            label = "✅ " + label
+        }
+        rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+            tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("assign:%s:%d", orderRef, craftsman.UserID)),
+        ))
+    }
+    return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
@@ -4,6 +4,7 @@ import (
 	"adtime-bot/internal/storage"
 	"context"
 	"fmt"
+	"html"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"go.uber.org/zap"
@@ -52,17 +53,20 @@ func (b *Bot) NotifyNewOrderToChannel(ctx context.Context, order storage.Order,
         zap.String("username", username))
 
     text := fmt.Sprintf(
-        "📦 Новый заказ #%d\n"+
-        "Тип: %s\n"+
-        "Размер: %dx%d см\n"+
+        "📦 Новый заказ %s%s\n"+
+        "%s\n"+
         "Цена: %.2f руб\n"+
+        "Срок: %s\n"+
+        "Получение: %s\n"+
         "Контакт: %s\n"+
         "TG: @%s",
-        order.ID, order.TextureName, 
-        order.WidthCM, order.HeightCM,
+        order.Number(), FormatRushMark(order.Rush),
+        html.EscapeString(FormatOrderItems(order)),
         order.Price,
-        FormatPhoneNumber(order.Contact),
-        username,
+        FormatDueDate(order.DueDate),
+        html.EscapeString(order.Fulfilment.String()),
+        html.EscapeString(FormatPhoneNumber(order.Contact)),
+        html.EscapeString(username),
     )
 
     msg := tgbotapi.NewMessage(b.cfg.Admin.ChannelID, text)
@@ -77,22 +81,39 @@ func (b *Bot) NotifyNewOrderToChannel(ctx context.Context, order storage.Order,
 
 // отправляет детали заказа и Excel файл конкретному админу
 func (b *Bot) NotifyAdmin(ctx context.Context, order storage.Order) {
-    // Use a map to track notified admins
-    notifiedAdmins := make(map[int64]bool)
+    for _, adminID := range b.adminChatIDs() {
+        b.sendAdminNotification(ctx, adminID, order)
+    }
+}
 
-    // Send to main admin if not zero
-    if b.cfg.Admin.ChatID != 0 && !notifiedAdmins[b.cfg.Admin.ChatID] {
-        b.sendAdminNotification(ctx, b.cfg.Admin.ChatID, order)
-        notifiedAdmins[b.cfg.Admin.ChatID] = true
+// NotifyAdminText sends a short message to every admin, e.g. about order changes
+func (b *Bot) NotifyAdminText(ctx context.Context, text string, replyMarkup interface{}) {
+    for _, adminID := range b.adminChatIDs() {
+        msg := tgbotapi.NewMessage(adminID, text)
+        if replyMarkup != nil {
+            msg.ReplyMarkup = replyMarkup
+        }
+        if _, err := b.bot.Send(msg); err != nil {
+            b.logger.Error("Failed to send admin message",
+                zap.Int64("admin_id", adminID),
+                zap.Error(err))
+        }
     }
-    
-    // Send to additional admins
-    for _, adminID := range b.cfg.Admin.IDs {
-        if adminID != 0 && !notifiedAdmins[adminID] {
-            b.sendAdminNotification(ctx, adminID, order)
-            notifiedAdmins[adminID] = true
+}
+
+// adminChatIDs returns the main admin and additional admins without duplicates
+func (b *Bot) adminChatIDs() []int64 {
+    // Use a map to track notified admins
+    seen := make(map[int64]bool)
+    var ids []int64
+
+    for _, adminID := range append([]int64{b.cfg.Admin.ChatID}, b.cfg.Admin.IDs...) {
+        if adminID != 0 && !seen[adminID] {
+            ids = append(ids, adminID)
+            seen[adminID] = true
         }
     }
+    return ids
 }
 
 func (b *Bot) sendAdminNotification(ctx context.Context, chatID int64, order storage.Order) {
@@ -101,47 +122,75 @@ func (b *Bot) sendAdminNotification(ctx context.Context, chatID int64, order sto
         return
     }
 
-    // Create Excel file first (so we can fail fast if there's an error)
+    // Admins still get the text if the Excel file cannot be made, and the
+    // file if the text is rejected
     filepath, err := b.storage.ExportOrderToExcel(ctx, order)
     if err != nil {
         b.logger.Error("Failed to create Excel file for order",
             zap.Int64("order_id", order.ID),
             zap.Error(err))
-        return
     }
 
-    // Prepare the notification message
+    // Prepare the notification message. It is plain text: promo codes,
+    // addresses and contacts may hold Markdown characters.
     msg := tgbotapi.NewMessage(chatID, FormatOrderNotification(order))
-    msg.ParseMode = "Markdown"
 
     // Only add buttons if we have a valid order ID
     if order.ID > 0 {
-        msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
-            tgbotapi.NewInlineKeyboardRow(
-                tgbotapi.NewInlineKeyboardButtonData(
-                    "✅ В обработку", 
-                    fmt.Sprintf("status:%d:processing", order.ID)),
-                tgbotapi.NewInlineKeyboardButtonData(
-                    "❌ Отменить", 
-                    fmt.Sprintf("status:%d:cancelled", order.ID)),
-            ),
-        )
+        msg.ReplyMarkup = b.CreateAdminOrderKeyboard(order)
     }
 
     if _, err := b.bot.Send(msg); err != nil {
         b.logger.Error("Failed to send admin notification",
             zap.Int64("order_id", order.ID),
             zap.Error(err))
-        return
     }
 
     // Send the Excel file
-    doc := tgbotapi.NewDocument(chatID, tgbotapi.FilePath(filepath))
-    doc.Caption = fmt.Sprintf("📊 Детали заказа #%d", order.ID)
-    
-    if _, err := b.bot.Send(doc); err != nil {
-        b.logger.Error("Failed to send Excel file to admin",
-            zap.Int64("order_id", order.ID),
-            zap.Error(err))
+    if filepath != "" {
+        doc := tgbotapi.NewDocument(chatID, tgbotapi.FilePath(filepath))
+        doc.Caption = fmt.Sprintf("📊 Детали заказа %s", order.Number())
+
+        if _, err := b.bot.Send(doc); err != nil {
+            b.logger.Error("Failed to send Excel file to admin",
+                zap.Int64("order_id", order.ID),
+                zap.Error(err))
+        }
+    }
+
+    // Show where the courier should go
+    if order.Fulfilment.HasLocation() {
+        location := tgbotapi.NewLocation(chatID, *order.Fulfilment.Latitude, *order.Fulfilment.Longitude)
+        if _, err := b.bot.Send(location); err != nil {
+            b.logger.Error("Failed to send delivery location to admin",
+                zap.Int64("order_id", order.ID),
+                zap.Error(err))
+        }
+    }
+
+    // Forward the customer's reference files
+    b.sendAttachments(chatID, order.Attachments)
+}
+
+// sendAttachments re-sends stored Telegram files to chatID
+func (b *Bot) sendAttachments(chatID int64, attachments []storage.OrderAttachment) {
+    for _, attachment := range attachments {
+        var msg tgbotapi.Chattable
+        if attachment.Kind == storage.AttachmentPhoto {
+            photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileID(attachment.FileID))
+            photo.Caption = attachment.Caption
+            msg = photo
+        } else {
+            doc := tgbotapi.NewDocument(chatID, tgbotapi.FileID(attachment.FileID))
+            doc.Caption = attachment.Caption
+            msg = doc
+        }
+
+        if _, err := b.bot.Send(msg); err != nil {
+            b.logger.Error("Failed to send order attachment",
+                zap.Int64("chat_id", chatID),
+                zap.Int64("order_id", attachment.OrderID),
+                zap.Error(err))
+        }
     }
 }
@@ -0,0 +1,89 @@
+package bot
+
+import (
+    "adtime-bot/internal/storage"
+    "context"
+    "time"
+
+    tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+    "go.uber.org/zap"
+)
+
+// OverdueCheckInterval is how often the overdue watcher wakes up; the digest
+// itself goes out once a day
+const OverdueCheckInterval = 15 * time.Minute
+
+// watchOverdueOrders runs until ctx is cancelled
+func (b *Bot) watchOverdueOrders(ctx context.Context) {
+    ticker := time.NewTicker(OverdueCheckInterval)
+    defer ticker.Stop()
+
+    for {
+        b.checkOverdueOrders(ctx, time.Now())
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+// checkOverdueOrders sends the daily overdue digest to admins once the
+// digest hour has come, and apologises to customers if configured
+func (b *Bot) checkOverdueOrders(ctx context.Context, now time.Time) {
+    if now.Hour() < b.cfg.Overdue.DigestHour {
+        return
+    }
+
+    // The orders are loaded before the day is claimed, so that a failed
+    // query is retried on the next tick instead of skipping the digest
+    orders, err := b.storage.GetOverdueOrders(ctx, now)
+    if err != nil {
+        b.logger.Error("Failed to get overdue orders", zap.Error(err))
+        return
+    }
+
+    claimed, err := b.storage.ClaimOverdueDigest(ctx, now)
+    if err != nil {
+        b.logger.Error("Failed to claim overdue digest", zap.Error(err))
+        return
+    }
+    if !claimed {
+        return
+    }
+    b.logger.Info("Overdue orders checked", zap.Int("overdue", len(orders)))
+    if len(orders) == 0 {
+        return
+    }
+
+    b.NotifyAdminText(ctx, FormatOverdueDigest(orders, now), nil)
+
+    if b.cfg.Overdue.NotifyCustomers {
+        for _, order := range orders {
+            b.sendOverdueApology(ctx, order)
+        }
+    }
+}
+
+// sendOverdueApology tells the customer once that their order is late
+func (b *Bot) sendOverdueApology(ctx context.Context, order storage.Order) {
+    marked, err := b.storage.MarkOverdueNotified(ctx, order.ID)
+    if err != nil {
+        b.logger.Error("Failed to mark overdue notification",
+            zap.Int64("order_id", order.ID),
+            zap.Error(err))
+        return
+    }
+    if !marked {
+        return
+    }
+
+    msg := tgbotapi.NewMessage(order.UserID, FormatOverdueApology(b.cfg.Overdue.ApologyTemplate, order))
+    if _, err := b.bot.Send(msg); err != nil {
+        b.logger.Warn("Failed to send overdue apology",
+            zap.Int64("order_id", order.ID),
+            zap.Int64("user_id", order.UserID),
+            zap.Error(err))
+    }
+}
@@ -2,7 +2,14 @@ package bot
 
 import (
 	"adtime-bot/internal/config"
+	"adtime-bot/internal/storage"
+	"adtime-bot/pkg/formula"
+	"adtime-bot/pkg/money"
+	"errors"
 	"fmt"
+	"math"
+	"regexp"
+	"sort"
 )
 
 type PricingConfig struct {
@@ -11,6 +18,116 @@ type PricingConfig struct {
     PaymentCommissionRate float64 // 3% for Yookassa
     SalesTaxRate          float64 // 6% for СЗ
     MarkupMultiplier      float64
+    // QuantityDiscounts maps a minimum number of pieces to a discount rate,
+    // e.g. {5: 0.10} gives 10% off from five pieces on
+    QuantityDiscounts map[int]float64
+    // EdgeCostPerM is the cost of cutting and finishing one metre of edge
+    EdgeCostPerM float64
+    // RushSurchargeRate is added to the price of rush orders
+    RushSurchargeRate float64
+    // SetupFee is added to every item; MinFinalPrice is the least an order's
+    // items cost the customer, 0 for no minimum
+    SetupFee      money.Kopecks
+    MinFinalPrice money.Kopecks
+    // RoundingStep rounds the items total of an order up when RoundUp is set
+    // and to the nearest step otherwise; 0 keeps kopecks
+    RoundingStep money.Kopecks
+    RoundUp      bool
+    // Formula, when set, gives the marked-up price instead of total cost ×
+    // MarkupMultiplier. It can use FormulaVariables and FormulaParameters.
+    Formula           *formula.Expr
+    FormulaParameters map[string]float64
+}
+
+// FormulaVariables describes the values every price formula can use; area,
+// perimeter and cost cover all pieces of the item
+var FormulaVariables = map[string]string{
+    "width":      "ширина, см",
+    "height":     "высота, см",
+    "radius":     "радиус скругления, см",
+    "quantity":   "количество, шт.",
+    "area":       "площадь всех изделий, дм²",
+    "perimeter":  "длина кромки всех изделий, м",
+    "price":      "цена текстуры за дм²",
+    "processing": "стоимость обработки за дм²",
+    "edge_rate":  "стоимость кромки за м",
+    "markup":     "стандартная наценка",
+    "cost":       "себестоимость (кожа + обработка)",
+}
+
+var formulaParameterName = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// ParsePriceFormula checks a formula and its parameters: parameter names must
+// not hide the standard variables, and every name the formula uses has to be
+// one of them or a parameter
+func ParsePriceFormula(source string, parameters map[string]float64) (*formula.Expr, error) {
+    expr, err := formula.Parse(source)
+    if err != nil {
+        return nil, err
+    }
+
+    for name := range parameters {
+        if !formulaParameterName.MatchString(name) {
+            return nil, fmt.Errorf("invalid parameter name %q", name)
+        }
+        if _, ok := FormulaVariables[name]; ok {
+            return nil, fmt.Errorf("parameter %q hides a standard variable", name)
+        }
+    }
+
+    var unknown []string
+    for _, name := range expr.Variables() {
+        if _, ok := FormulaVariables[name]; ok {
+            continue
+        }
+        if _, ok := parameters[name]; !ok {
+            unknown = append(unknown, name)
+        }
+    }
+    if len(unknown) > 0 {
+        sort.Strings(unknown)
+        return nil, fmt.Errorf("unknown variables: %v", unknown)
+    }
+    return expr, nil
+}
+
+// formulaValues are the variables a formula is evaluated with
+func formulaValues(params PriceParams, cfg PricingConfig, price PriceBreakdown) map[string]float64 {
+    values := map[string]float64{
+        "width":      float64(params.WidthCM),
+        "height":     float64(params.HeightCM),
+        "radius":     float64(params.CornerRadiusCM),
+        "quantity":   float64(params.Quantity),
+        "area":       price.AreaDM2,
+        "perimeter":  price.PerimeterM,
+        "price":      cfg.LeatherPricePerDM2,
+        "processing": cfg.ProcessingCostPerDM2,
+        "edge_rate":  cfg.EdgeCostPerM,
+        "markup":     cfg.MarkupMultiplier,
+        "cost":       price.TotalCost.Rubles(),
+    }
+    for name, value := range cfg.FormulaParameters {
+        values[name] = value
+    }
+    return values
+}
+
+// PriceParams describes what is being priced. An empty Shape is a rectangle;
+// a circle uses WidthCM as its diameter.
+type PriceParams struct {
+    WidthCM        int
+    HeightCM       int
+    Quantity       int
+    Shape          string
+    CornerRadiusCM int
+    // Rush adds the rush surcharge
+    Rush bool
+    // LoyaltyRate is the customer's loyalty discount
+    LoyaltyRate float64
+    // PromoRate takes a promo code's share off the discounted price;
+    // PromoAmount takes up to that much off instead
+    PromoRate   float64
+    PromoAmount money.Kopecks
 }
 
 func NewDefaultPricing() PricingConfig {
@@ -30,39 +147,332 @@ func NewPricingConfig(texturePrice float64, cfg *config.Config) PricingConfig {
         PaymentCommissionRate: cfg.Pricing.PaymentCommissionRate,
         SalesTaxRate:          cfg.Pricing.SalesTaxRate,
         MarkupMultiplier:      cfg.Pricing.MarkupMultiplier,
+        QuantityDiscounts:     cfg.Pricing.QuantityDiscounts,
+        EdgeCostPerM:          cfg.Pricing.EdgeCostPerM,
+        RushSurchargeRate:     cfg.Rush.SurchargeRate,
+        SetupFee:              money.FromRubles(cfg.Pricing.SetupFee),
+        MinFinalPrice:         money.FromRubles(cfg.Pricing.MinFinalPrice),
+        RoundingStep:          money.Kopecks(cfg.Pricing.RoundingStep) * 100,
+        RoundUp:               cfg.Pricing.RoundingMode == config.RoundUp,
+    }
+}
+
+// RoundPrice rounds a customer price to the configured step. A positive
+// price is never rounded down to nothing: it is rounded up to one step.
+func (cfg PricingConfig) RoundPrice(price money.Kopecks) money.Kopecks {
+    if cfg.RoundUp {
+        return price.RoundUp(cfg.RoundingStep)
+    }
+    rounded := price.RoundNearest(cfg.RoundingStep)
+    if rounded <= 0 && price > 0 {
+        return price.RoundUp(cfg.RoundingStep)
+    }
+    return rounded
+}
+
+// OrderAdjustment is what rounding and the minimum order price add to the
+// items total of an order, negative when it is rounded down. Rounding comes
+// first so that the minimum is never rounded down.
+func (cfg PricingConfig) OrderAdjustment(itemsTotal money.Kopecks) money.Kopecks {
+    return max(cfg.RoundPrice(itemsTotal), cfg.MinFinalPrice) - itemsTotal
+}
+
+// ApplyOrderPricing sets the totals of an order from its priced items: the
+// price adjustment goes onto the items total, the delivery fee follows the
+// adjusted total, and commission, tax and profit are recomputed from it
+func ApplyOrderPricing(order *storage.Order, cfg PricingConfig, delivery DeliveryConfig) {
+    itemsTotal := money.FromRubles(order.ItemsTotal())
+    adjustment := cfg.OrderAdjustment(itemsTotal)
+    order.PriceAdjustment = adjustment.Rubles()
+    order.DeliveryFee = delivery.Fee(order.Fulfilment.Method, itemsTotal+adjustment).Rubles()
+    order.UpdateTotals()
+    if adjustment == 0 {
+        return
+    }
+
+    // The delivery fee is passed on to the courier, so it is left out
+    price := itemsTotal + adjustment
+    commission := price.MulRate(cfg.PaymentCommissionRate)
+    tax := price.MulRate(cfg.SalesTaxRate)
+    netRevenue := price - commission - tax
+    order.Commission = commission.Rubles()
+    order.Tax = tax.Rubles()
+    order.NetRevenue = netRevenue.Rubles()
+    order.Profit = (netRevenue - money.FromRubles(order.TotalCost)).Rubles()
+}
+
+// DeliveryConfig holds the fees for getting an order to the customer
+type DeliveryConfig struct {
+    CourierFee money.Kopecks
+    // FreeCourierFrom waives the courier fee from this items total on; 0 disables it
+    FreeCourierFrom money.Kopecks
+}
+
+func NewDeliveryConfig(cfg *config.Config) DeliveryConfig {
+    return DeliveryConfig{
+        CourierFee:      money.FromRubles(cfg.Delivery.CourierFee),
+        FreeCourierFrom: money.FromRubles(cfg.Delivery.FreeCourierFrom),
+    }
+}
+
+// Fee returns the delivery fee for an order whose items cost itemsTotal.
+// Pickup is free.
+func (cfg DeliveryConfig) Fee(method string, itemsTotal money.Kopecks) money.Kopecks {
+    if method != storage.FulfilmentCourier {
+        return 0
+    }
+    if cfg.FreeCourierFrom > 0 && itemsTotal >= cfg.FreeCourierFrom {
+        return 0
     }
+    return cfg.CourierFee
 }
 
-func CalculatePrice(widthCm, heightCm int, cfg PricingConfig) (map[string]float64, error) {
-    
+// LoyaltyTier is a discount for returning customers, reached with the
+// MinOrders-th order or once MinSpent is spent; a zero threshold is unused
+type LoyaltyTier struct {
+    MinOrders int
+    MinSpent  money.Kopecks
+    Rate      float64
+}
+
+// Reached reports whether the next order of a customer with stats gets the tier
+func (t LoyaltyTier) Reached(stats storage.CustomerStats) bool {
+    return (t.MinOrders > 0 && stats.Orders+1 >= t.MinOrders) ||
+        (t.MinSpent > 0 && money.FromRubles(stats.Spent) >= t.MinSpent)
+}
+
+// LoyaltyConfig holds the loyalty tiers, smallest discount first
+type LoyaltyConfig struct {
+    Tiers []LoyaltyTier
+}
+
+func NewLoyaltyConfig(cfg *config.Config) LoyaltyConfig {
+    var tiers []LoyaltyTier
+    for orderNumber, rate := range cfg.Loyalty.OrderTiers {
+        tiers = append(tiers, LoyaltyTier{MinOrders: orderNumber, Rate: rate})
+    }
+    for spent, rate := range cfg.Loyalty.SpendTiers {
+        tiers = append(tiers, LoyaltyTier{MinSpent: money.FromRubles(float64(spent)), Rate: rate})
+    }
+    sort.Slice(tiers, func(i, j int) bool {
+        if tiers[i].Rate != tiers[j].Rate {
+            return tiers[i].Rate < tiers[j].Rate
+        }
+        // Equal discounts: order tiers first, then the lower threshold
+        if (tiers[i].MinOrders > 0) != (tiers[j].MinOrders > 0) {
+            return tiers[i].MinOrders > 0
+        }
+        return tiers[i].MinOrders < tiers[j].MinOrders || tiers[i].MinSpent < tiers[j].MinSpent
+    })
+    return LoyaltyConfig{Tiers: tiers}
+}
+
+// Tier returns the tier with the largest discount the customer has reached
+func (cfg LoyaltyConfig) Tier(stats storage.CustomerStats) (LoyaltyTier, bool) {
+    var (
+        best  LoyaltyTier
+        found bool
+    )
+    for _, tier := range cfg.Tiers {
+        if tier.Reached(stats) && (!found || tier.Rate > best.Rate) {
+            best, found = tier, true
+        }
+    }
+    return best, found
+}
+
+// NextTier returns the smallest discount above the customer's current one
+// that is still to be reached
+func (cfg LoyaltyConfig) NextTier(stats storage.CustomerStats) (LoyaltyTier, bool) {
+    current, _ := cfg.Tier(stats)
+    for _, tier := range cfg.Tiers {
+        if tier.Rate > current.Rate && !tier.Reached(stats) {
+            return tier, true
+        }
+    }
+    return LoyaltyTier{}, false
+}
+
+// DiscountRate returns the rate of the largest discount tier reached by quantity
+func (cfg PricingConfig) DiscountRate(quantity int) float64 {
+    bestTier, rate := 0, 0.0
+    for minQuantity, tierRate := range cfg.QuantityDiscounts {
+        if quantity >= minQuantity && minQuantity > bestTier {
+            bestTier, rate = minQuantity, tierRate
+        }
+    }
+    return rate
+}
+
+// ValidateShape checks that the dimensions make sense for the shape
+func ValidateShape(params PriceParams) error {
+    if !storage.IsValidShape(params.Shape) {
+        return fmt.Errorf("unknown shape: %q", params.Shape)
+    }
+    if params.WidthCM <= 0 || params.HeightCM <= 0 {
+        return fmt.Errorf("invalid dimensions: %dx%d", params.WidthCM, params.HeightCM)
+    }
+
+    switch params.Shape {
+    case storage.ShapeCircle:
+        if params.WidthCM != params.HeightCM {
+            return errors.New("circle width and height must both be its diameter")
+        }
+    case storage.ShapeRoundedRect:
+        if params.CornerRadiusCM <= 0 || 2*params.CornerRadiusCM > min(params.WidthCM, params.HeightCM) {
+            return fmt.Errorf("invalid corner radius: %d", params.CornerRadiusCM)
+        }
+    }
+    return nil
+}
+
+// ShapeArea returns the area of one piece in cm²
+func ShapeArea(params PriceParams) float64 {
+    w, h := float64(params.WidthCM), float64(params.HeightCM)
+    switch params.Shape {
+    case storage.ShapeCircle, storage.ShapeOval:
+        return math.Pi * w * h / 4
+    case storage.ShapeRoundedRect:
+        r := float64(params.CornerRadiusCM)
+        return w*h - (4-math.Pi)*r*r
+    default:
+        return w * h
+    }
+}
+
+// ShapePerimeter returns the edge length of one piece in cm. The oval uses
+// Ramanujan's approximation of the ellipse perimeter.
+func ShapePerimeter(params PriceParams) float64 {
+    w, h := float64(params.WidthCM), float64(params.HeightCM)
+    switch params.Shape {
+    case storage.ShapeCircle, storage.ShapeOval:
+        a, b := w/2, h/2
+        return math.Pi * (3*(a+b) - math.Sqrt((3*a+b)*(a+3*b)))
+    case storage.ShapeRoundedRect:
+        r := float64(params.CornerRadiusCM)
+        return 2*(w+h) - (8-2*math.Pi)*r
+    default:
+        return 2 * (w + h)
+    }
+}
+
+// MinItemPrice is the least an item costs after discounts: saved items must
+// have a positive price, so a promo code never makes one free
+const MinItemPrice money.Kopecks = 1
+
+// PriceBreakdown is the price of one cart item and what it is made of. Money
+// is in whole kopecks: every amount derived from a rate is rounded once, half
+// away from zero, when it is computed, and the totals are exact sums of the
+// rounded parts.
+type PriceBreakdown struct {
+    AreaDM2    float64
+    PerimeterM float64
+
+    LeatherCost money.Kopecks
+    EdgeCost    money.Kopecks
+    // ProcessingCost includes EdgeCost
+    ProcessingCost money.Kopecks
+    TotalCost      money.Kopecks
+
+    // GrossPrice is the marked-up price before the discount
+    GrossPrice    money.Kopecks
+    DiscountRate  float64
+    Discount        money.Kopecks
+    LoyaltyDiscount money.Kopecks
+    PromoDiscount   money.Kopecks
+    RushSurcharge   money.Kopecks
+    SetupFee        money.Kopecks
+    // FinalPrice is what the customer pays for the item
+    FinalPrice money.Kopecks
+
+    Commission money.Kopecks
+    Tax        money.Kopecks
+    NetRevenue money.Kopecks
+    Profit     money.Kopecks
+}
+
+// CalculatePrice prices a single piece
+func CalculatePrice(widthCm, heightCm int, cfg PricingConfig) (PriceBreakdown, error) {
+    return CalculateItemPrice(PriceParams{WidthCM: widthCm, HeightCM: heightCm, Quantity: 1}, cfg)
+}
+
+// CalculateItemPrice prices params.Quantity identical pieces. Material and
+// processing follow the shape's area, edge finishing its perimeter. The
+// quantity discount is taken off the marked-up price; the loyalty discount
+// and the rush surcharge are both computed from what is left, and the promo
+// discount from what the loyalty discount leaves; the setup fee is added on
+// top. Commission, tax and profit are computed from what the customer
+// actually pays. The minimum order price and rounding apply to the whole
+// order, see ApplyOrderPricing.
+func CalculateItemPrice(params PriceParams, cfg PricingConfig) (PriceBreakdown, error) {
     if cfg.LeatherPricePerDM2 <= 0 {
-        return nil, fmt.Errorf("invalid leather price: %.2f", cfg.LeatherPricePerDM2)
+        return PriceBreakdown{}, fmt.Errorf("invalid leather price: %.2f", cfg.LeatherPricePerDM2)
     }
     if cfg.ProcessingCostPerDM2 < 0 {
-        return nil, fmt.Errorf("invalid processing cost: %.2f", cfg.ProcessingCostPerDM2)
+        return PriceBreakdown{}, fmt.Errorf("invalid processing cost: %.2f", cfg.ProcessingCostPerDM2)
     }
     if cfg.MarkupMultiplier < 1 {
-        return nil, fmt.Errorf("invalid markup multiplier: %.2f", cfg.MarkupMultiplier)
+        return PriceBreakdown{}, fmt.Errorf("invalid markup multiplier: %.2f", cfg.MarkupMultiplier)
+    }
+    if cfg.RushSurchargeRate < 0 {
+        return PriceBreakdown{}, fmt.Errorf("invalid rush surcharge: %.2f", cfg.RushSurchargeRate)
+    }
+    if cfg.SetupFee < 0 || cfg.MinFinalPrice < 0 || cfg.RoundingStep < 0 {
+        return PriceBreakdown{}, fmt.Errorf("invalid price limits: setup fee %s, minimum %s, rounding %s",
+            cfg.SetupFee, cfg.MinFinalPrice, cfg.RoundingStep)
     }
+    if params.Quantity <= 0 {
+        return PriceBreakdown{}, fmt.Errorf("invalid quantity: %d", params.Quantity)
+    }
+    if params.LoyaltyRate < 0 || params.LoyaltyRate >= 1 {
+        return PriceBreakdown{}, fmt.Errorf("invalid loyalty discount: %.2f", params.LoyaltyRate)
+    }
+    if params.PromoRate < 0 || params.PromoRate > 1 || params.PromoAmount < 0 {
+        return PriceBreakdown{}, fmt.Errorf("invalid promo discount: %.2f, %s", params.PromoRate, params.PromoAmount)
+    }
+    if err := ValidateShape(params); err != nil {
+        return PriceBreakdown{}, err
+    }
+
+    var price PriceBreakdown
+    price.AreaDM2 = ShapeArea(params) / 100 * float64(params.Quantity)
+    price.PerimeterM = ShapePerimeter(params) / 100 * float64(params.Quantity)
 
-    areaCm2 := float64(widthCm * heightCm)
-    areaDm2 := areaCm2 / 100
-    
-    priceDetails := make(map[string]float64)
-    
     // Base costs. Use texture price from database
-    priceDetails["leather_cost"] = areaDm2 * cfg.LeatherPricePerDM2
-    priceDetails["processing_cost"] = areaDm2 * cfg.ProcessingCostPerDM2
-    priceDetails["total_cost"] = priceDetails["leather_cost"] + priceDetails["processing_cost"]
-    
-    // Final price with markup
-    priceDetails["final_price"] = priceDetails["total_cost"] * cfg.MarkupMultiplier
-    
+    price.LeatherCost = money.FromRubles(price.AreaDM2 * cfg.LeatherPricePerDM2)
+    price.EdgeCost = money.FromRubles(price.PerimeterM * cfg.EdgeCostPerM)
+    price.ProcessingCost = money.FromRubles(price.AreaDM2*cfg.ProcessingCostPerDM2) + price.EdgeCost
+    price.TotalCost = price.LeatherCost + price.ProcessingCost
+
+    // Price with markup, then the quantity discount
+    price.GrossPrice = price.TotalCost.MulRate(cfg.MarkupMultiplier)
+    if cfg.Formula != nil {
+        gross, err := cfg.Formula.Eval(formulaValues(params, cfg, price))
+        if err != nil {
+            return PriceBreakdown{}, fmt.Errorf("price formula %q: %w", cfg.Formula, err)
+        }
+        price.GrossPrice = money.FromRubles(gross)
+        if price.GrossPrice <= 0 {
+            return PriceBreakdown{}, fmt.Errorf("price formula %q gave a non-positive price: %.2f", cfg.Formula, gross)
+        }
+    }
+    price.DiscountRate = cfg.DiscountRate(params.Quantity)
+    price.Discount = price.GrossPrice.MulRate(price.DiscountRate)
+    discounted := price.GrossPrice - price.Discount
+    price.LoyaltyDiscount = discounted.MulRate(params.LoyaltyRate)
+    afterLoyalty := discounted - price.LoyaltyDiscount
+    price.PromoDiscount = max(min(afterLoyalty.MulRate(params.PromoRate)+params.PromoAmount, afterLoyalty-MinItemPrice), 0)
+    if params.Rush {
+        price.RushSurcharge = discounted.MulRate(cfg.RushSurchargeRate)
+    }
+    price.SetupFee = cfg.SetupFee
+    price.FinalPrice = afterLoyalty - price.PromoDiscount + price.RushSurcharge + price.SetupFee
+
     // Revenue calculations
-    priceDetails["commission"] = priceDetails["final_price"] * cfg.PaymentCommissionRate
-    priceDetails["tax"] = priceDetails["final_price"] * cfg.SalesTaxRate
-    priceDetails["net_revenue"] = priceDetails["final_price"] - priceDetails["commission"] - priceDetails["tax"]
-    priceDetails["profit"] = priceDetails["net_revenue"] - priceDetails["total_cost"]
-    
-    return priceDetails, nil
+    price.Commission = price.FinalPrice.MulRate(cfg.PaymentCommissionRate)
+    price.Tax = price.FinalPrice.MulRate(cfg.SalesTaxRate)
+    price.NetRevenue = price.FinalPrice - price.Commission - price.Tax
+    price.Profit = price.NetRevenue - price.TotalCost
+
+    return price, nil
 }
@@ -1,6 +1,12 @@
 package bot
 
-import "testing"
+import (
+    "adtime-bot/internal/config"
+    "adtime-bot/internal/storage"
+    "adtime-bot/pkg/money"
+    "math"
+    "testing"
+)
 
 func TestCalculatePrice(t *testing.T) {
     cfg := PricingConfig{
@@ -16,10 +22,10 @@ func TestCalculatePrice(t *testing.T) {
         t.Fatalf("CalculatePrice failed: %v", err)
     }
     
-    expectedLeatherCost := 400.0 // 80*20/100*25
-    if prices["leather_cost"] != expectedLeatherCost {
-        t.Errorf("Incorrect leather cost, got %.2f, want %.2f", 
-            prices["leather_cost"], expectedLeatherCost)
+    expectedLeatherCost := money.Kopecks(40000) // 80*20/100*25
+    if prices.LeatherCost != expectedLeatherCost {
+        t.Errorf("Incorrect leather cost, got %s, want %s", 
+            prices.LeatherCost, expectedLeatherCost)
     }
     
 }
@@ -38,3 +44,634 @@ func TestCalculatePrice_InvalidInput(t *testing.T) {
         t.Error("Expected error for invalid leather price, got nil")
     }
 }
+
+func TestCalculateItemPrice_QuantityDiscount(t *testing.T) {
+    cfg := PricingConfig{
+        LeatherPricePerDM2:    25.0,
+        ProcessingCostPerDM2:  31.25,
+        PaymentCommissionRate: 0.03,
+        SalesTaxRate:          0.06,
+        MarkupMultiplier:      2.5,
+        QuantityDiscounts:     map[int]float64{5: 0.10, 10: 0.15},
+    }
+
+    single, err := CalculatePrice(20, 10, cfg)
+    if err != nil {
+        t.Fatalf("CalculatePrice failed: %v", err)
+    }
+
+    tests := []struct {
+        quantity int
+        rate     float64
+    }{
+        {1, 0},
+        {4, 0},
+        {5, 0.10},
+        {9, 0.10},
+        {10, 0.15},
+        {50, 0.15},
+    }
+
+    for _, tt := range tests {
+        prices, err := CalculateItemPrice(PriceParams{WidthCM: 20, HeightCM: 10, Quantity: tt.quantity}, cfg)
+        if err != nil {
+            t.Fatalf("quantity %d: CalculateItemPrice failed: %v", tt.quantity, err)
+        }
+
+        gross := single.FinalPrice * money.Kopecks(tt.quantity)
+        wantDiscount := gross.MulRate(tt.rate)
+        if prices.FinalPrice != gross-wantDiscount {
+            t.Errorf("quantity %d: final price %s, want %s", tt.quantity, prices.FinalPrice, gross-wantDiscount)
+        }
+        if prices.Discount != wantDiscount {
+            t.Errorf("quantity %d: discount %s, want %s", tt.quantity, prices.Discount, wantDiscount)
+        }
+
+        wantTax := prices.FinalPrice.MulRate(cfg.SalesTaxRate)
+        if prices.Tax != wantTax {
+            t.Errorf("quantity %d: tax %s, want %s", tt.quantity, prices.Tax, wantTax)
+        }
+    }
+
+    if _, err := CalculateItemPrice(PriceParams{WidthCM: 20, HeightCM: 10}, cfg); err == nil {
+        t.Error("Expected error for zero quantity, got nil")
+    }
+}
+
+func TestCalculateItemPrice_RushSurcharge(t *testing.T) {
+    cfg := PricingConfig{
+        LeatherPricePerDM2:    25.0,
+        ProcessingCostPerDM2:  31.25,
+        PaymentCommissionRate: 0.03,
+        SalesTaxRate:          0.06,
+        MarkupMultiplier:      2.5,
+        QuantityDiscounts:     map[int]float64{5: 0.10},
+        RushSurchargeRate:     0.3,
+    }
+    params := PriceParams{WidthCM: 20, HeightCM: 10, Quantity: 5}
+
+    normal, err := CalculateItemPrice(params, cfg)
+    if err != nil {
+        t.Fatalf("CalculateItemPrice failed: %v", err)
+    }
+    if normal.RushSurcharge != 0 {
+        t.Errorf("rush surcharge without rush: %s", normal.RushSurcharge)
+    }
+
+    params.Rush = true
+    rush, err := CalculateItemPrice(params, cfg)
+    if err != nil {
+        t.Fatalf("CalculateItemPrice failed: %v", err)
+    }
+
+    // The surcharge is added to the discounted price
+    wantSurcharge := normal.FinalPrice.MulRate(0.3)
+    if rush.RushSurcharge != wantSurcharge {
+        t.Errorf("rush surcharge %s, want %s", rush.RushSurcharge, wantSurcharge)
+    }
+    if rush.FinalPrice != normal.FinalPrice+wantSurcharge {
+        t.Errorf("rush final price %s, want %s", rush.FinalPrice, normal.FinalPrice+wantSurcharge)
+    }
+    if rush.Tax != rush.FinalPrice.MulRate(cfg.SalesTaxRate) {
+        t.Errorf("rush tax %s is not computed from the final price", rush.Tax)
+    }
+}
+
+func TestCalculateItemPrice_WholeKopecks(t *testing.T) {
+    cfg := PricingConfig{
+        LeatherPricePerDM2:    27.3,
+        ProcessingCostPerDM2:  31.25,
+        PaymentCommissionRate: 0.03,
+        SalesTaxRate:          0.06,
+        MarkupMultiplier:      2.5,
+        QuantityDiscounts:     map[int]float64{3: 0.07},
+        EdgeCostPerM:          45,
+        RushSurchargeRate:     0.3,
+    }
+    params := PriceParams{WidthCM: 33, HeightCM: 17, Quantity: 3, Shape: storage.ShapeOval, Rush: true}
+
+    prices, err := CalculateItemPrice(params, cfg)
+    if err != nil {
+        t.Fatalf("CalculateItemPrice failed: %v", err)
+    }
+
+    // Every total is the exact sum of its rounded parts
+    if prices.TotalCost != prices.LeatherCost+prices.ProcessingCost {
+        t.Errorf("total cost %s is not leather %s + processing %s", prices.TotalCost, prices.LeatherCost, prices.ProcessingCost)
+    }
+    if prices.FinalPrice != prices.GrossPrice-prices.Discount+prices.RushSurcharge {
+        t.Errorf("final price %s does not add up", prices.FinalPrice)
+    }
+    if prices.NetRevenue+prices.Commission+prices.Tax != prices.FinalPrice {
+        t.Errorf("net revenue %s, commission %s and tax %s do not add up to %s",
+            prices.NetRevenue, prices.Commission, prices.Tax, prices.FinalPrice)
+    }
+    if prices.Profit != prices.NetRevenue-prices.TotalCost {
+        t.Errorf("profit %s does not add up", prices.Profit)
+    }
+    if prices.Tax != prices.FinalPrice.MulRate(0.06) {
+        t.Errorf("tax %s is not 6%% of %s rounded to the kopeck", prices.Tax, prices.FinalPrice)
+    }
+}
+
+func TestDeliveryConfigFee(t *testing.T) {
+    cfg := DeliveryConfig{CourierFee: 35000, FreeCourierFrom: 500000}
+
+    if fee := cfg.Fee(storage.FulfilmentPickup, 1000); fee != 0 {
+        t.Errorf("Pickup should be free, got %s", fee)
+    }
+    if fee := cfg.Fee(storage.FulfilmentCourier, 100000); fee != 35000 {
+        t.Errorf("Incorrect courier fee, got %s, want 350.00", fee)
+    }
+    if fee := cfg.Fee(storage.FulfilmentCourier, 500000); fee != 0 {
+        t.Errorf("Courier should be free from 5000, got %s", fee)
+    }
+
+    cfg.FreeCourierFrom = 0
+    if fee := cfg.Fee(storage.FulfilmentCourier, 10000000); fee != 35000 {
+        t.Errorf("Free delivery should be disabled, got %s", fee)
+    }
+}
+
+func TestShapeAreaAndPerimeter(t *testing.T) {
+    tests := []struct {
+        params    PriceParams
+        area      float64
+        perimeter float64
+    }{
+        {PriceParams{WidthCM: 30, HeightCM: 20}, 600, 100},
+        {PriceParams{WidthCM: 30, HeightCM: 20, Shape: storage.ShapeRectangle}, 600, 100},
+        {PriceParams{WidthCM: 20, HeightCM: 20, Shape: storage.ShapeCircle}, 100 * math.Pi, 20 * math.Pi},
+        {PriceParams{WidthCM: 40, HeightCM: 20, Shape: storage.ShapeOval}, 200 * math.Pi, 96.88},
+        {PriceParams{WidthCM: 30, HeightCM: 20, Shape: storage.ShapeRoundedRect, CornerRadiusCM: 5},
+            600 - (4-math.Pi)*25, 100 - (8-2*math.Pi)*5},
+    }
+
+    for _, tt := range tests {
+        if area := ShapeArea(tt.params); math.Abs(area-tt.area) > 1e-9 {
+            t.Errorf("%+v: area %.2f, want %.2f", tt.params, area, tt.area)
+        }
+        if perimeter := ShapePerimeter(tt.params); math.Abs(perimeter-tt.perimeter) > 0.01 {
+            t.Errorf("%+v: perimeter %.2f, want %.2f", tt.params, perimeter, tt.perimeter)
+        }
+    }
+}
+
+func TestCalculateItemPrice_Shapes(t *testing.T) {
+    cfg := NewDefaultPricing()
+    cfg.EdgeCostPerM = 100
+
+    prices, err := CalculateItemPrice(PriceParams{WidthCM: 20, HeightCM: 20, Quantity: 2, Shape: storage.ShapeCircle}, cfg)
+    if err != nil {
+        t.Fatalf("CalculateItemPrice failed: %v", err)
+    }
+
+    area := 2 * math.Pi // two circles of π dm² each
+    edge := 2 * 0.2 * math.Pi * cfg.EdgeCostPerM
+    if prices.LeatherCost != money.FromRubles(area*cfg.LeatherPricePerDM2) {
+        t.Errorf("Incorrect leather cost, got %s", prices.LeatherCost)
+    }
+    if prices.ProcessingCost != money.FromRubles(area*cfg.ProcessingCostPerDM2)+money.FromRubles(edge) {
+        t.Errorf("Incorrect processing cost, got %s", prices.ProcessingCost)
+    }
+
+    invalid := []PriceParams{
+        {WidthCM: 20, HeightCM: 10, Quantity: 1, Shape: storage.ShapeCircle},
+        {WidthCM: 20, HeightCM: 10, Quantity: 1, Shape: storage.ShapeRoundedRect},
+        {WidthCM: 20, HeightCM: 10, Quantity: 1, Shape: storage.ShapeRoundedRect, CornerRadiusCM: 6},
+        {WidthCM: 20, HeightCM: 10, Quantity: 1, Shape: "triangle"},
+    }
+    for _, params := range invalid {
+        if _, err := CalculateItemPrice(params, cfg); err == nil {
+            t.Errorf("%+v: expected error, got nil", params)
+        }
+    }
+}
+
+func TestCalculateItemPrice_Formula(t *testing.T) {
+    params := map[string]float64{"k": 2}
+    expr, err := ParsePriceFormula("max(area * price * k, 500)", params)
+    if err != nil {
+        t.Fatalf("ParsePriceFormula failed: %v", err)
+    }
+    cfg := PricingConfig{
+        LeatherPricePerDM2:    25.0,
+        ProcessingCostPerDM2:  31.25,
+        PaymentCommissionRate: 0.03,
+        SalesTaxRate:          0.06,
+        MarkupMultiplier:      2.5,
+        QuantityDiscounts:     map[int]float64{5: 0.10},
+        Formula:               expr,
+        FormulaParameters:     params,
+    }
+
+    tests := []struct {
+        name      string
+        params    PriceParams
+        wantGross money.Kopecks
+    }{
+        {"minimum applies", PriceParams{WidthCM: 10, HeightCM: 10, Quantity: 1}, 50000},
+        // 5 × 3 dm² × 25 ₽ × 2
+        {"formula applies", PriceParams{WidthCM: 20, HeightCM: 15, Quantity: 5}, 75000},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, err := CalculateItemPrice(tt.params, cfg)
+            if err != nil {
+                t.Fatalf("CalculateItemPrice failed: %v", err)
+            }
+            if got.GrossPrice != tt.wantGross {
+                t.Errorf("gross price %s, want %s", got.GrossPrice, tt.wantGross)
+            }
+            // Discounts and revenue still follow the formula's price
+            wantFinal := tt.wantGross - tt.wantGross.MulRate(cfg.DiscountRate(tt.params.Quantity))
+            if got.FinalPrice != wantFinal {
+                t.Errorf("final price %s, want %s", got.FinalPrice, wantFinal)
+            }
+        })
+    }
+}
+
+func TestParsePriceFormula_Errors(t *testing.T) {
+    tests := []struct {
+        name   string
+        source string
+        params map[string]float64
+    }{
+        {"syntax", "area *", nil},
+        {"unknown variable", "area * k", nil},
+        {"parameter hides variable", "area * price", map[string]float64{"price": 10}},
+        {"invalid parameter name", "area * k", map[string]float64{"k": 1, "Bad-Name": 2}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if _, err := ParsePriceFormula(tt.source, tt.params); err == nil {
+                t.Errorf("ParsePriceFormula(%q) succeeded, want an error", tt.source)
+            }
+        })
+    }
+}
+
+func TestParseFormulaDefinition(t *testing.T) {
+    f, err := ParseFormulaDefinition("Кожа Люкс = max(area * price * k, m); k=2,4; m = 500")
+    if err != nil {
+        t.Fatalf("ParseFormulaDefinition failed: %v", err)
+    }
+    if f.ServiceType != "Кожа Люкс" || f.Formula != "max(area * price * k, m)" {
+        t.Errorf("got service %q formula %q", f.ServiceType, f.Formula)
+    }
+    if f.Parameters["k"] != 2.4 || f.Parameters["m"] != 500 {
+        t.Errorf("got parameters %v", f.Parameters)
+    }
+
+    for _, definition := range []string{"area * 2", "Кожа = ", "Кожа = area; k", "Кожа = area; k=x"} {
+        if _, err := ParseFormulaDefinition(definition); err == nil {
+            t.Errorf("ParseFormulaDefinition(%q) succeeded, want an error", definition)
+        }
+    }
+}
+
+func TestCalculateItemPrice_Promo(t *testing.T) {
+    cfg := PricingConfig{
+        LeatherPricePerDM2:    25.0,
+        ProcessingCostPerDM2:  31.25,
+        PaymentCommissionRate: 0.03,
+        SalesTaxRate:          0.06,
+        MarkupMultiplier:      2.5,
+        QuantityDiscounts:     map[int]float64{5: 0.10},
+        RushSurchargeRate:     0.3,
+    }
+    params := PriceParams{WidthCM: 20, HeightCM: 10, Quantity: 5}
+
+    plain, err := CalculateItemPrice(params, cfg)
+    if err != nil {
+        t.Fatalf("CalculateItemPrice failed: %v", err)
+    }
+
+    // The promo percentage is taken off the price after the quantity discount
+    params.PromoRate = 0.2
+    percent, err := CalculateItemPrice(params, cfg)
+    if err != nil {
+        t.Fatalf("CalculateItemPrice failed: %v", err)
+    }
+    if want := plain.FinalPrice.MulRate(0.2); percent.PromoDiscount != want {
+        t.Errorf("promo discount %s, want %s", percent.PromoDiscount, want)
+    }
+    if percent.FinalPrice != plain.FinalPrice-percent.PromoDiscount {
+        t.Errorf("final price %s does not include the promo discount", percent.FinalPrice)
+    }
+    if percent.Tax != percent.FinalPrice.MulRate(cfg.SalesTaxRate) {
+        t.Errorf("tax %s is not computed from the discounted price", percent.Tax)
+    }
+
+    // A fixed amount or a full percentage never makes the item free
+    params.PromoRate, params.PromoAmount = 0, plain.FinalPrice+100000
+    fixed, err := CalculateItemPrice(params, cfg)
+    if err != nil {
+        t.Fatalf("CalculateItemPrice failed: %v", err)
+    }
+    if fixed.PromoDiscount != plain.FinalPrice-MinItemPrice || fixed.FinalPrice != MinItemPrice {
+        t.Errorf("fixed promo gave discount %s and final price %s", fixed.PromoDiscount, fixed.FinalPrice)
+    }
+    params.PromoRate, params.PromoAmount = 1, 0
+    full, err := CalculateItemPrice(params, cfg)
+    if err != nil {
+        t.Fatalf("CalculateItemPrice failed: %v", err)
+    }
+    if full.FinalPrice != MinItemPrice {
+        t.Errorf("100%% promo gave final price %s, want %s", full.FinalPrice, MinItemPrice)
+    }
+    params.PromoRate, params.PromoAmount = 0, plain.FinalPrice+100000
+
+    // The rush surcharge does not shrink with the promo discount
+    params.Rush = true
+    rush, err := CalculateItemPrice(params, cfg)
+    if err != nil {
+        t.Fatalf("CalculateItemPrice failed: %v", err)
+    }
+    if want := plain.FinalPrice.MulRate(0.3) + MinItemPrice; rush.FinalPrice != want {
+        t.Errorf("rush final price %s, want the surcharge and the least item price: %s", rush.FinalPrice, want)
+    }
+
+    params.PromoRate = 1.5
+    if _, err := CalculateItemPrice(params, cfg); err == nil {
+        t.Error("Expected error for a promo rate above 100%, got nil")
+    }
+}
+
+func TestPromoAllowance(t *testing.T) {
+    fixed := NewPromoAllowance(&storage.PromoCode{Kind: storage.PromoFixed, Value: 500, Textures: []string{"Питон"}})
+
+    if params := fixed.Params(PriceParams{}, "Крокодил"); params.PromoAmount != 0 {
+        t.Errorf("texture outside the code got %s", params.PromoAmount)
+    }
+    if params := fixed.Params(PriceParams{}, "Питон"); params.PromoAmount != 50000 {
+        t.Errorf("first item got %s, want 500.00", params.PromoAmount)
+    }
+    fixed.Use(30000)
+    if params := fixed.Params(PriceParams{}, "Питон"); params.PromoAmount != 20000 {
+        t.Errorf("second item got %s, want what is left: 200.00", params.PromoAmount)
+    }
+
+    percent := NewPromoAllowance(&storage.PromoCode{Kind: storage.PromoPercent, Value: 15})
+    percent.Use(30000)
+    if params := percent.Params(PriceParams{}, "Питон"); params.PromoRate != 0.15 {
+        t.Errorf("percent code gave rate %v, want 0.15", params.PromoRate)
+    }
+
+    var none *PromoAllowance
+    none.Use(100)
+    if params := none.Params(PriceParams{Quantity: 2}, "Питон"); params != (PriceParams{Quantity: 2}) {
+        t.Errorf("no promo changed the params: %+v", params)
+    }
+}
+
+func TestParsePromoCode(t *testing.T) {
+    promo, err := ParsePromoCode([]string{"осень-10", "10%", "until=30.11.2026", "uses=100", "textures=Питон,", "Кожа", "Люкс"})
+    if err != nil {
+        t.Fatalf("ParsePromoCode failed: %v", err)
+    }
+    if promo.Code != "ОСЕНЬ-10" || promo.Kind != storage.PromoPercent || promo.Value != 10 {
+        t.Errorf("got code %q, kind %q, value %v", promo.Code, promo.Kind, promo.Value)
+    }
+    if promo.ValidUntil == nil || promo.ValidUntil.Format("02.01.2006") != "30.11.2026" || promo.ValidFrom != nil {
+        t.Errorf("got validity %v – %v", promo.ValidFrom, promo.ValidUntil)
+    }
+    if promo.MaxUses != 100 || promo.MaxUsesPerUser != 1 {
+        t.Errorf("got limits %d, %d per user", promo.MaxUses, promo.MaxUsesPerUser)
+    }
+    if len(promo.Textures) != 2 || promo.Textures[0] != "Питон" || promo.Textures[1] != "Кожа Люкс" {
+        t.Errorf("got textures %q", promo.Textures)
+    }
+
+    fixed, err := ParsePromoCode([]string{"WELCOME", "499,90", "per_user=0"})
+    if err != nil {
+        t.Fatalf("ParsePromoCode failed: %v", err)
+    }
+    if fixed.Kind != storage.PromoFixed || fixed.Value != 499.9 || fixed.MaxUsesPerUser != 0 {
+        t.Errorf("got kind %q, value %v, per user %d", fixed.Kind, fixed.Value, fixed.MaxUsesPerUser)
+    }
+
+    invalid := [][]string{
+        {"AB", "10%"},
+        {"CODE", "0%"},
+        {"CODE", "100%"},
+        {"CODE", "101%"},
+        {"CODE", "-5"},
+        {"CODE", "10%", "uses=-1"},
+        {"CODE", "10%", "until=31.02.2026"},
+        {"CODE", "10%", "from=02.11.2026", "until=01.11.2026"},
+        {"CODE", "10%", "limit=5"},
+        {"CODE"},
+    }
+    for _, args := range invalid {
+        if _, err := ParsePromoCode(args); err == nil {
+            t.Errorf("ParsePromoCode(%q) succeeded, want an error", args)
+        }
+    }
+}
+
+func TestLoyaltyConfig(t *testing.T) {
+    var appCfg config.Config
+    appCfg.Loyalty.OrderTiers = map[int]float64{3: 0.05}
+    appCfg.Loyalty.SpendTiers = map[int]float64{10000: 0.10}
+    cfg := NewLoyaltyConfig(&appCfg)
+
+    tests := []struct {
+        name     string
+        stats    storage.CustomerStats
+        wantRate float64
+        nextRate float64
+    }{
+        {"new customer", storage.CustomerStats{}, 0, 0.05},
+        {"second order", storage.CustomerStats{Orders: 1, Spent: 2000}, 0, 0.05},
+        {"third order", storage.CustomerStats{Orders: 2, Spent: 4000}, 0.05, 0.10},
+        {"spent enough", storage.CustomerStats{Orders: 1, Spent: 10000}, 0.10, 0},
+        {"both tiers", storage.CustomerStats{Orders: 7, Spent: 25000}, 0.10, 0},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tier, _ := cfg.Tier(tt.stats)
+            if tier.Rate != tt.wantRate {
+                t.Errorf("Tier rate = %v, want %v", tier.Rate, tt.wantRate)
+            }
+            next, _ := cfg.NextTier(tt.stats)
+            if next.Rate != tt.nextRate {
+                t.Errorf("NextTier rate = %v, want %v", next.Rate, tt.nextRate)
+            }
+        })
+    }
+
+    status := FormatLoyaltyStatus(cfg, storage.CustomerStats{Orders: 2, Spent: 7500})
+    want := "💎 Ваша скидка постоянного клиента: 5% с 3-го заказа\n" +
+        "Следующая скидка: 10% при покупках от 10000.00 ₽ (осталось 2500.00 ₽)"
+    if status != want {
+        t.Errorf("FormatLoyaltyStatus = %q, want %q", status, want)
+    }
+    if status := FormatLoyaltyStatus(LoyaltyConfig{}, storage.CustomerStats{Orders: 5}); status != "" {
+        t.Errorf("FormatLoyaltyStatus without tiers = %q, want empty", status)
+    }
+}
+
+func TestCalculateItemPrice_Loyalty(t *testing.T) {
+    cfg := PricingConfig{
+        LeatherPricePerDM2:    25.0,
+        ProcessingCostPerDM2:  31.25,
+        PaymentCommissionRate: 0.03,
+        SalesTaxRate:          0.06,
+        MarkupMultiplier:      2.5,
+        QuantityDiscounts:     map[int]float64{5: 0.10},
+        RushSurchargeRate:     0.3,
+    }
+    params := PriceParams{WidthCM: 20, HeightCM: 10, Quantity: 5}
+
+    plain, err := CalculateItemPrice(params, cfg)
+    if err != nil {
+        t.Fatalf("CalculateItemPrice failed: %v", err)
+    }
+
+    // The loyalty discount follows the quantity discount
+    params.LoyaltyRate = 0.05
+    loyal, err := CalculateItemPrice(params, cfg)
+    if err != nil {
+        t.Fatalf("CalculateItemPrice failed: %v", err)
+    }
+    if want := plain.FinalPrice.MulRate(0.05); loyal.LoyaltyDiscount != want {
+        t.Errorf("loyalty discount %s, want %s", loyal.LoyaltyDiscount, want)
+    }
+    if loyal.FinalPrice != plain.FinalPrice-loyal.LoyaltyDiscount {
+        t.Errorf("final price %s does not include the loyalty discount", loyal.FinalPrice)
+    }
+
+    // A promo percentage is taken from what the loyalty discount leaves
+    params.PromoRate = 0.2
+    both, err := CalculateItemPrice(params, cfg)
+    if err != nil {
+        t.Fatalf("CalculateItemPrice failed: %v", err)
+    }
+    if want := loyal.FinalPrice.MulRate(0.2); both.PromoDiscount != want {
+        t.Errorf("promo discount %s, want %s", both.PromoDiscount, want)
+    }
+    if both.FinalPrice != loyal.FinalPrice-both.PromoDiscount {
+        t.Errorf("final price %s, want %s", both.FinalPrice, loyal.FinalPrice-both.PromoDiscount)
+    }
+
+    // The rush surcharge does not shrink with the loyalty discount
+    params.PromoRate, params.Rush = 0, true
+    rush, err := CalculateItemPrice(params, cfg)
+    if err != nil {
+        t.Fatalf("CalculateItemPrice failed: %v", err)
+    }
+    if rush.RushSurcharge != plain.FinalPrice.MulRate(0.3) {
+        t.Errorf("rush surcharge %s is not computed before the loyalty discount", rush.RushSurcharge)
+    }
+
+    params.LoyaltyRate = 1
+    if _, err := CalculateItemPrice(params, cfg); err == nil {
+        t.Error("Expected error for a 100% loyalty discount, got nil")
+    }
+}
+
+func TestCalculateItemPrice_SetupFee(t *testing.T) {
+    cfg := PricingConfig{
+        LeatherPricePerDM2:    25.0,
+        ProcessingCostPerDM2:  31.25,
+        PaymentCommissionRate: 0.03,
+        SalesTaxRate:          0.06,
+        MarkupMultiplier:      2.5,
+        // The minimum and rounding apply to whole orders only
+        MinFinalPrice: 50000,
+        RoundingStep:  5000,
+    }
+    // 3×2 cm: 0.06 dm² cost 3.38 ₽, marked up to 8.45 ₽
+    params := PriceParams{WidthCM: 3, HeightCM: 2, Quantity: 1}
+
+    price, err := CalculateItemPrice(params, cfg)
+    if err != nil {
+        t.Fatalf("CalculateItemPrice failed: %v", err)
+    }
+    if price.FinalPrice != 845 {
+        t.Errorf("final price %s, want 8.45", price.FinalPrice)
+    }
+
+    cfg.SetupFee = 15000
+    withSetup, err := CalculateItemPrice(params, cfg)
+    if err != nil {
+        t.Fatalf("CalculateItemPrice failed: %v", err)
+    }
+    if withSetup.SetupFee != 15000 || withSetup.FinalPrice != 15845 {
+        t.Errorf("setup fee %s, final price %s, want 150.00 and 158.45", withSetup.SetupFee, withSetup.FinalPrice)
+    }
+    if withSetup.Tax != withSetup.FinalPrice.MulRate(cfg.SalesTaxRate) {
+        t.Errorf("tax %s is not computed from the price with the setup fee", withSetup.Tax)
+    }
+
+    cfg.SetupFee = -100
+    if _, err := CalculateItemPrice(params, cfg); err == nil {
+        t.Error("Expected error for a negative setup fee, got nil")
+    }
+}
+
+func TestOrderAdjustment(t *testing.T) {
+    tests := []struct {
+        name    string
+        total   money.Kopecks
+        minimum money.Kopecks
+        step    money.Kopecks
+        up      bool
+        want    money.Kopecks
+    }{
+        {"no rules", 8734, 0, 0, false, 0},
+        {"nearest rouble", 8734, 0, 100, false, -34},
+        {"up to 10 roubles", 8734, 0, 1000, true, 266},
+        {"nearest 50 roubles", 12400, 0, 5000, false, -2400},
+        {"minimum", 8734, 50000, 0, false, 41266},
+        {"minimum is not rounded down", 12400, 17500, 5000, false, 5100},
+        // 1×1 cm and 2×2 cm pieces must not become free
+        {"small price with 10 rouble step", 140, 0, 1000, false, 860},
+        {"small price with 50 rouble step", 563, 0, 5000, false, 4437},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            cfg := PricingConfig{MinFinalPrice: tt.minimum, RoundingStep: tt.step, RoundUp: tt.up}
+            if got := cfg.OrderAdjustment(tt.total); got != tt.want {
+                t.Errorf("OrderAdjustment(%s) = %s, want %s", tt.total, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestApplyOrderPricing(t *testing.T) {
+    cfg := PricingConfig{
+        PaymentCommissionRate: 0.03,
+        SalesTaxRate:          0.06,
+        MinFinalPrice:         50000,
+        RoundingStep:          1000,
+    }
+    delivery := DeliveryConfig{CourierFee: 35000, FreeCourierFrom: 50000}
+    item := storage.OrderItem{Price: 120, TotalCost: 50, Commission: 3.6, Tax: 7.2, NetRevenue: 109.2, Profit: 59.2}
+    order := storage.Order{
+        Items:      []storage.OrderItem{item, item, item},
+        Fulfilment: storage.Fulfilment{Method: storage.FulfilmentCourier},
+    }
+
+    ApplyOrderPricing(&order, cfg, delivery)
+
+    // The minimum is charged once for the whole cart, which makes delivery free
+    if order.PriceAdjustment != 140 || order.Price != 500 || order.DeliveryFee != 0 {
+        t.Errorf("got adjustment %.2f, price %.2f, delivery %.2f; want 140, 500, 0",
+            order.PriceAdjustment, order.Price, order.DeliveryFee)
+    }
+    if order.Commission != 15 || order.Tax != 30 || order.NetRevenue != 455 || order.Profit != 305 {
+        t.Errorf("got commission %.2f, tax %.2f, net revenue %.2f, profit %.2f; want 15, 30, 455, 305",
+            order.Commission, order.Tax, order.NetRevenue, order.Profit)
+    }
+
+    // Without an adjustment the totals are the sums of the items
+    cfg.MinFinalPrice, cfg.RoundingStep = 0, 0
+    ApplyOrderPricing(&order, cfg, delivery)
+    if order.PriceAdjustment != 0 || order.Price != 710 || order.Commission != 10.8 || order.Profit != 177.6 {
+        t.Errorf("got adjustment %.2f, price %.2f, commission %.2f, profit %.2f; want 0, 710, 10.8, 177.6",
+            order.PriceAdjustment, order.Price, order.Commission, order.Profit)
+    }
+}
@@ -15,11 +15,123 @@ type UserState struct {
 	Service     string `json:"service"`
 	ServiceType string `json:"service_type"`
 	Date        string `json:"date"`
+	// Rush is set when the customer took the rush option for a close date
+	Rush        bool   `json:"rush,omitempty"`
+	// PromoCode is the code applied with /promo to the next order
+	PromoCode string `json:"promo_code,omitempty"`
 	PhoneNumber string `json:"phone_number"`
 	WidthCM     int    `json:"width_cm"`
 	HeightCM    int    `json:"height_cm"`
+	Shape       string `json:"shape,omitempty"`
+	CornerRadiusCM int `json:"corner_radius_cm,omitempty"`
+	Quantity    int    `json:"quantity,omitempty"`
 	TextureID   string `json:"texture_id"`
 	Price       string `json:"price"`
+	// Editing is set when the customer jumped back from the order review to change a field
+	Editing bool `json:"editing,omitempty"`
+	// CancelOrderID is the order the customer is currently cancelling
+	CancelOrderID int64 `json:"cancel_order_id,omitempty"`
+	// CancelReturnStep is the step the customer left to cancel the order
+	CancelReturnStep string `json:"cancel_return_step,omitempty"`
+	// EditOrderID, EditField and EditItem describe a saved order being edited
+	EditOrderID int64  `json:"edit_order_id,omitempty"`
+	EditField   string `json:"edit_field,omitempty"`
+	EditItem    int    `json:"edit_item,omitempty"`
+	// MessageOrderID is the order the user is writing a message about
+	MessageOrderID int64 `json:"message_order_id,omitempty"`
+	// QuoteID is the quote request an admin is pricing
+	QuoteID int64 `json:"quote_id,omitempty"`
+	// Items holds the cart positions completed before the one being configured now
+	Items []CartItem `json:"items,omitempty"`
+	// Attachments are photos and files for the order being put together
+	Attachments []PendingAttachment `json:"attachments,omitempty"`
+	// AttachOrderID is the saved order the customer is adding files to
+	AttachOrderID int64 `json:"attach_order_id,omitempty"`
+	// Fulfilment is how the order being put together reaches the customer
+	Fulfilment storage.Fulfilment `json:"fulfilment"`
+	// SearchFilter is the admin's last /find query, kept for paging through results
+	SearchFilter *storage.OrderFilter `json:"search_filter,omitempty"`
+}
+
+// PendingAttachment is a file sent before the order is saved
+type PendingAttachment struct {
+	Kind     string `json:"kind"`
+	FileID   string `json:"file_id"`
+	FileName string `json:"file_name,omitempty"`
+	Caption  string `json:"caption,omitempty"`
+}
+
+// CartItem is one position of the order being put together
+type CartItem struct {
+	Service     string `json:"service"`
+	ServiceType string `json:"service_type"`
+	TextureID   string `json:"texture_id"`
+	WidthCM     int    `json:"width_cm"`
+	HeightCM    int    `json:"height_cm"`
+	Shape       string `json:"shape,omitempty"`
+	CornerRadiusCM int `json:"corner_radius_cm,omitempty"`
+	Quantity    int    `json:"quantity,omitempty"`
+}
+
+// CurrentItem returns the position being configured right now
+func (s UserState) CurrentItem() CartItem {
+	return CartItem{
+		Service:     s.Service,
+		ServiceType: s.ServiceType,
+		TextureID:   s.TextureID,
+		WidthCM:     s.WidthCM,
+		HeightCM:    s.HeightCM,
+		Shape:       s.Shape,
+		CornerRadiusCM: s.CornerRadiusCM,
+		Quantity:    s.Quantity,
+	}
+}
+
+// setCurrentItem makes item the position being configured
+func (s *UserState) setCurrentItem(item CartItem) {
+	s.Service = item.Service
+	s.ServiceType = item.ServiceType
+	s.TextureID = item.TextureID
+	s.Price = ""
+	s.WidthCM = item.WidthCM
+	s.HeightCM = item.HeightCM
+	s.Shape = item.Shape
+	s.CornerRadiusCM = item.CornerRadiusCM
+	s.Quantity = item.Quantity
+}
+
+// PriceParams describes the item for pricing; states saved before
+// quantities existed count as a single piece
+func (c CartItem) PriceParams() PriceParams {
+	quantity := c.Quantity
+	if quantity <= 0 {
+		quantity = 1
+	}
+	return PriceParams{
+		WidthCM:        c.WidthCM,
+		HeightCM:       c.HeightCM,
+		Quantity:       quantity,
+		Shape:          c.Shape,
+		CornerRadiusCM: c.CornerRadiusCM,
+	}
+}
+
+// ItemPriceParams describes a cart item for pricing together with the
+// order-wide options
+func (s UserState) ItemPriceParams(item CartItem) PriceParams {
+	params := item.PriceParams()
+	params.Rush = s.Rush
+	return params
+}
+
+// IsCustomTexture reports whether the item needs a quote instead of a catalogue price
+func (c CartItem) IsCustomTexture() bool {
+	return c.TextureID == "" && c.Service == CustomTextureService
+}
+
+// CartItems returns the completed positions followed by the current one
+func (s UserState) CartItems() []CartItem {
+	return append(append([]CartItem{}, s.Items...), s.CurrentItem())
 }
 
 type StateStorage struct {
@@ -98,13 +210,48 @@ func (s *StateStorage) SetTexture(ctx context.Context, chatID int64, textureID s
 	return s.Save(ctx, chatID, state)
 }
 
-func (s *StateStorage) SetDimensions(ctx context.Context, chatID int64, width, height int) error {
+// SetDimensions stores the size of the current item; radius is the corner
+// radius of a rounded rectangle and zero for other shapes
+func (s *StateStorage) SetDimensions(ctx context.Context, chatID int64, width, height, radius int) error {
 	state, err := s.Get(ctx, chatID)
 	if err != nil {
 		state = UserState{}
 	}
 	state.WidthCM = width
 	state.HeightCM = height
+	state.CornerRadiusCM = radius
+	return s.Save(ctx, chatID, state)
+}
+
+// SetShape changes the shape of the current item. A size entered for another
+// shape is fitted to the new one, so the item stays valid if the customer
+// leaves the size step without entering a new one.
+func (s *StateStorage) SetShape(ctx context.Context, chatID int64, shape string) error {
+	state, err := s.Get(ctx, chatID)
+	if err != nil {
+		state = UserState{}
+	}
+	state.Shape = shape
+
+	switch shape {
+	case storage.ShapeCircle:
+		diameter := min(state.WidthCM, state.HeightCM)
+		state.WidthCM, state.HeightCM, state.CornerRadiusCM = diameter, diameter, 0
+	case storage.ShapeRoundedRect:
+		maxRadius := min(state.WidthCM, state.HeightCM) / 2
+		state.CornerRadiusCM = max(1, min(state.CornerRadiusCM, maxRadius))
+	default:
+		state.CornerRadiusCM = 0
+	}
+	return s.Save(ctx, chatID, state)
+}
+
+func (s *StateStorage) SetQuantity(ctx context.Context, chatID int64, quantity int) error {
+	state, err := s.Get(ctx, chatID)
+	if err != nil {
+		state = UserState{}
+	}
+	state.Quantity = quantity
 	return s.Save(ctx, chatID, state)
 }
 
@@ -114,6 +261,28 @@ func (s *StateStorage) SetDate(ctx context.Context, chatID int64, date string) e
 		state = UserState{}
 	}
 	state.Date = date
+	// A new date has to be confirmed again, with or without rush
+	state.Rush = false
+	return s.Save(ctx, chatID, state)
+}
+
+func (s *StateStorage) SetRush(ctx context.Context, chatID int64, rush bool) error {
+	state, err := s.Get(ctx, chatID)
+	if err != nil {
+		return fmt.Errorf("failed to get state: %w", err)
+	}
+	state.Rush = rush
+	return s.Save(ctx, chatID, state)
+}
+
+// SetPromoCode applies a promo code to the order being put together; an
+// empty code removes it
+func (s *StateStorage) SetPromoCode(ctx context.Context, chatID int64, code string) error {
+	state, err := s.Get(ctx, chatID)
+	if err != nil {
+		state = UserState{}
+	}
+	state.PromoCode = code
 	return s.Save(ctx, chatID, state)
 }
 
@@ -135,6 +304,177 @@ func (s *StateStorage) SetServiceType(ctx context.Context, chatID int64, service
 	return s.Save(ctx, chatID, state)
 }
 
+func (s *StateStorage) SetEditing(ctx context.Context, chatID int64, editing bool) error {
+	state, err := s.Get(ctx, chatID)
+	if err != nil {
+		state = UserState{}
+	}
+	state.Editing = editing
+	return s.Save(ctx, chatID, state)
+}
+
+// ClearTexture forgets the selected catalogue texture, e.g. when a custom one is requested
+func (s *StateStorage) ClearTexture(ctx context.Context, chatID int64) error {
+	state, err := s.Get(ctx, chatID)
+	if err != nil {
+		state = UserState{}
+	}
+	state.TextureID = ""
+	state.Price = ""
+	return s.Save(ctx, chatID, state)
+}
+
+// StartCancellation asks for the reason to cancel orderID and remembers the
+// current step, so that an order being put together is not lost
+func (s *StateStorage) StartCancellation(ctx context.Context, chatID int64, orderID int64) error {
+	state, err := s.Get(ctx, chatID)
+	if err != nil {
+		state = UserState{}
+	}
+	if state.Step != StepCancelReason {
+		state.CancelReturnStep = state.Step
+	}
+	state.CancelOrderID = orderID
+	state.Step = StepCancelReason
+	return s.Save(ctx, chatID, state)
+}
+
+// FinishCancellation forgets the order being cancelled and returns the
+// customer to the step they left
+func (s *StateStorage) FinishCancellation(ctx context.Context, chatID int64) (UserState, error) {
+	state, err := s.Get(ctx, chatID)
+	if err != nil {
+		state = UserState{}
+	}
+	state.Step = state.CancelReturnStep
+	if state.Step == "" {
+		state.Step = StepServiceType
+	}
+	state.CancelOrderID = 0
+	state.CancelReturnStep = ""
+	return state, s.Save(ctx, chatID, state)
+}
+
+func (s *StateStorage) SetMessageOrderID(ctx context.Context, chatID int64, orderID int64) error {
+	state, err := s.Get(ctx, chatID)
+	if err != nil {
+		state = UserState{}
+	}
+	state.MessageOrderID = orderID
+	return s.Save(ctx, chatID, state)
+}
+
+func (s *StateStorage) SetFulfilment(ctx context.Context, chatID int64, fulfilment storage.Fulfilment) error {
+	state, err := s.Get(ctx, chatID)
+	if err != nil {
+		state = UserState{}
+	}
+	state.Fulfilment = fulfilment
+	return s.Save(ctx, chatID, state)
+}
+
+// SetSearchFilter remembers the admin's order search for paging
+func (s *StateStorage) SetSearchFilter(ctx context.Context, chatID int64, filter storage.OrderFilter) error {
+	state, err := s.Get(ctx, chatID)
+	if err != nil {
+		state = UserState{}
+	}
+	state.SearchFilter = &filter
+	return s.Save(ctx, chatID, state)
+}
+
+// AddAttachment keeps a file for the order being put together and returns how many there are
+func (s *StateStorage) AddAttachment(ctx context.Context, chatID int64, attachment PendingAttachment) (int, error) {
+	state, err := s.Get(ctx, chatID)
+	if err != nil {
+		return 0, err
+	}
+	state.Attachments = append(state.Attachments, attachment)
+	return len(state.Attachments), s.Save(ctx, chatID, state)
+}
+
+func (s *StateStorage) SetAttachOrderID(ctx context.Context, chatID int64, orderID int64) error {
+	state, err := s.Get(ctx, chatID)
+	if err != nil {
+		state = UserState{}
+	}
+	state.AttachOrderID = orderID
+	return s.Save(ctx, chatID, state)
+}
+
+func (s *StateStorage) SetQuoteID(ctx context.Context, chatID int64, quoteID int64) error {
+	state, err := s.Get(ctx, chatID)
+	if err != nil {
+		state = UserState{}
+	}
+	state.QuoteID = quoteID
+	return s.Save(ctx, chatID, state)
+}
+
+func (s *StateStorage) SetEditOrder(ctx context.Context, chatID int64, orderID int64, field string, item int) error {
+	state, err := s.Get(ctx, chatID)
+	if err != nil {
+		state = UserState{}
+	}
+	state.EditOrderID = orderID
+	state.EditField = field
+	state.EditItem = item
+	return s.Save(ctx, chatID, state)
+}
+
+// PrefillOrder replaces the order being put together with the given cart,
+// e.g. when repeating an old order. The last item becomes the current one.
+func (s *StateStorage) PrefillOrder(ctx context.Context, chatID int64, items []CartItem, phone string) error {
+	if len(items) == 0 {
+		return errors.New("cart is empty")
+	}
+
+	current, err := s.Get(ctx, chatID)
+	if err != nil {
+		current = UserState{}
+	}
+	if current.PhoneNumber != "" {
+		phone = current.PhoneNumber
+	}
+
+	state := UserState{
+		PhoneNumber: phone,
+		PromoCode:   current.PromoCode,
+		Items:       items[:len(items)-1],
+		// Once the date is chosen the customer goes straight to the review
+		Editing: true,
+	}
+	state.setCurrentItem(items[len(items)-1])
+	return s.Save(ctx, chatID, state)
+}
+
+// AddCurrentItemToCart completes the current position and starts a blank one
+func (s *StateStorage) AddCurrentItemToCart(ctx context.Context, chatID int64) error {
+	state, err := s.Get(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	state.Items = append(state.Items, state.CurrentItem())
+	state.setCurrentItem(CartItem{})
+	return s.Save(ctx, chatID, state)
+}
+
+// RemoveCurrentItem drops the current position and makes the last completed one current again
+func (s *StateStorage) RemoveCurrentItem(ctx context.Context, chatID int64) error {
+	state, err := s.Get(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	if len(state.Items) == 0 {
+		return errors.New("cart has a single item")
+	}
+
+	last := state.Items[len(state.Items)-1]
+	state.Items = state.Items[:len(state.Items)-1]
+	state.setCurrentItem(last)
+	return s.Save(ctx, chatID, state)
+}
+
 func getStateKey(chatID int64) string {
 	return fmt.Sprintf("state:%d", chatID)
 }
@@ -221,10 +561,12 @@ func (s *StateStorage) ResetOrderState(ctx context.Context, chatID int64) error
         currentState = UserState{}
     }
 
-    // Reset all fields except phone number
+    // Reset all fields except phone number, the promo code and the admin's search
     return s.Save(ctx, chatID, UserState{
-        PhoneNumber: currentState.PhoneNumber, // Preserve phone
-        Step:        StepServiceType,          // Or StepPrivacyAgreement if needed
+        PhoneNumber:  currentState.PhoneNumber, // Preserve phone
+        Step:         StepServiceType,          // Or StepPrivacyAgreement if needed
+        PromoCode:    currentState.PromoCode,
+        SearchFilter: currentState.SearchFilter,
     })
 }
 
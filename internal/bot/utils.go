@@ -2,11 +2,156 @@ package bot
 
 import (
 	"adtime-bot/internal/storage"
+	"adtime-bot/pkg/money"
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 	"unicode"
 )
 
+// Limits of the workshop's cutting table, mirrored by the orders table constraints
+const (
+    MaxWidthCM  = 80
+    MaxHeightCM = 50
+    MaxQuantity = 100
+)
+
+// ParseDimensions parses "width height" in centimetres.
+// Errors carry a message that can be shown to the customer as is.
+func ParseDimensions(text string) (width, height int, err error) {
+    parts := strings.Fields(text)
+    if len(parts) != 2 {
+        return 0, 0, errors.New("Неверный формат. Введите ширину и длину через пробел (например: 30 40)")
+    }
+
+    width, err = strconv.Atoi(parts[0])
+    if err != nil || width <= 0 || width > MaxWidthCM {
+        return 0, 0, fmt.Errorf("Некорректная ширина. Допустимый диапазон: 1-%d см", MaxWidthCM)
+    }
+
+    height, err = strconv.Atoi(parts[1])
+    if err != nil || height <= 0 || height > MaxHeightCM {
+        return 0, 0, fmt.Errorf("Некорректная длина. Допустимый диапазон: 1-%d см", MaxHeightCM)
+    }
+
+    return width, height, nil
+}
+
+// ParseShapeDimensions reads the size of a piece of the given shape: the
+// diameter of a circle, width, length and corner radius of a rounded
+// rectangle, width and length otherwise. A circle's diameter is returned as
+// both width and height.
+func ParseShapeDimensions(shape, text string) (width, height, radius int, err error) {
+    parts := strings.Fields(text)
+
+    switch shape {
+    case storage.ShapeCircle:
+        maxDiameter := min(MaxWidthCM, MaxHeightCM)
+        if len(parts) != 1 {
+            return 0, 0, 0, errors.New("Неверный формат. Введите диаметр одним числом (например: 30)")
+        }
+        diameter, err := strconv.Atoi(parts[0])
+        if err != nil || diameter <= 0 || diameter > maxDiameter {
+            return 0, 0, 0, fmt.Errorf("Некорректный диаметр. Допустимый диапазон: 1-%d см", maxDiameter)
+        }
+        return diameter, diameter, 0, nil
+
+    case storage.ShapeRoundedRect:
+        if len(parts) != 3 {
+            return 0, 0, 0, errors.New("Неверный формат. Введите ширину, длину и радиус скругления через пробел (например: 30 40 5)")
+        }
+        width, height, err = ParseDimensions(parts[0] + " " + parts[1])
+        if err != nil {
+            return 0, 0, 0, err
+        }
+        maxRadius := min(width, height) / 2
+        radius, err = strconv.Atoi(parts[2])
+        if err != nil || radius <= 0 || radius > maxRadius {
+            if maxRadius < 1 {
+                return 0, 0, 0, errors.New("Изделие слишком маленькое для скругления углов")
+            }
+            return 0, 0, 0, fmt.Errorf("Некорректный радиус скругления. Допустимый диапазон: 1-%d см", maxRadius)
+        }
+        return width, height, radius, nil
+
+    default:
+        width, height, err = ParseDimensions(text)
+        return width, height, 0, err
+    }
+}
+
+// DimensionsPrompt asks for the size of a piece of the given shape
+func DimensionsPrompt(shape string) string {
+    switch shape {
+    case storage.ShapeCircle:
+        return fmt.Sprintf("Введите диаметр в сантиметрах (например: 30)\nМаксимальный диаметр: %d см", min(MaxWidthCM, MaxHeightCM))
+    case storage.ShapeRoundedRect:
+        return fmt.Sprintf("Введите ширину, длину и радиус скругления углов в сантиметрах через пробел (например: 30 40 5)\nМаксимальный размер: %dx%d см", MaxWidthCM, MaxHeightCM)
+    default:
+        return fmt.Sprintf("Введите ширину и длину в сантиметрах через пробел (например: 30 40)\nМаксимальный размер: %dx%d см", MaxWidthCM, MaxHeightCM)
+    }
+}
+
+var shapeLabels = map[string]string{
+    storage.ShapeRectangle:   "Прямоугольник",
+    storage.ShapeCircle:      "Круг",
+    storage.ShapeOval:        "Овал",
+    storage.ShapeRoundedRect: "Скруглённые углы",
+}
+
+// ShapeLabel is the Russian name of a shape; an empty shape is a rectangle
+func ShapeLabel(shape string) string {
+    if label, ok := shapeLabels[shape]; ok {
+        return label
+    }
+    return shapeLabels[storage.ShapeRectangle]
+}
+
+// FormatItemSize renders the size of a piece, e.g. "30×40 см" or "круг Ø30 см"
+func FormatItemSize(shape string, width, height, radius int) string {
+    switch shape {
+    case storage.ShapeCircle:
+        return fmt.Sprintf("круг Ø%d см", width)
+    case storage.ShapeOval:
+        return fmt.Sprintf("овал %d×%d см", width, height)
+    case storage.ShapeRoundedRect:
+        return fmt.Sprintf("%d×%d см, скругление R%d", width, height, radius)
+    default:
+        return fmt.Sprintf("%d×%d см", width, height)
+    }
+}
+
+// ParseQuantity validates the number of identical pieces
+func ParseQuantity(text string) (int, error) {
+    quantity, err := strconv.Atoi(strings.TrimSpace(text))
+    if err != nil || quantity <= 0 || quantity > MaxQuantity {
+        return 0, fmt.Errorf("Некорректное количество. Допустимый диапазон: 1-%d шт.", MaxQuantity)
+    }
+    return quantity, nil
+}
+
+// ParseDueDate parses a customer-entered date in ДД.ММ.ГГГГ (or ДД.ММ.ГГ) format
+// and rejects dates in the past.
+func ParseDueDate(text string) (time.Time, error) {
+    // Автокоррекция года
+    if len(text) == 8 { // формат ДД.ММ.ГГ
+        text = text[:6] + "20" + text[6:]
+    }
+
+    date, err := time.Parse("02.01.2006", text)
+    if err != nil {
+        return time.Time{}, errors.New("Неверный формат даты. Пожалуйста, введите дату в формате ДД.ММ.ГГГГ")
+    }
+
+    if date.Before(time.Now().Truncate(24 * time.Hour)) {
+        return time.Time{}, errors.New("Пожалуйста, выберите дату в будущем")
+    }
+    return date, nil
+}
+
 func NormalizePhoneNumber(phone string) string {
     // Remove all non-digit characters
     cleaned := strings.Map(func(r rune) rune {
@@ -83,12 +228,16 @@ func FormatPhoneNumber(phone string) string {
 
 func FormatOrderNotification(order storage.Order) string {
     return fmt.Sprintf(
-        "📦 Новый заказ #%d\n\n"+
-            "Размеры: %d x %d см\n"+
-            "Текстура: %s\n"+
+        "📦 Новый заказ %s%s\n\n"+
+            "Позиции:\n%s\n"+
             "Итоговая цена: %.2f руб\n"+
             "──────────────────\n"+
             "Детали расчета:\n"+
+            "- Скидка за количество: %.2f руб\n"+
+            "%s%s"+
+            "- Наценка за срочность: %.2f руб\n"+
+            "- Округление и минимум: %.2f руб\n"+
+            "- Доставка: %.2f руб\n"+
             "- Стоимость кожи: %.2f руб\n"+
             "- Обработка: %.2f руб\n"+
             "- Комиссия: %.2f руб\n"+
@@ -97,13 +246,19 @@ func FormatOrderNotification(order storage.Order) string {
             "Прибыль: %.2f руб\n"+
             "──────────────────\n"+
             "Контакт: %s\n"+
+            "Получение: %s\n"+
             "Статус: %s\n"+
+            "Срок выполнения: %s\n"+
             "Дата: %s",
-        order.ID,
-        order.WidthCM,
-        order.HeightCM,
-        order.TextureName,
+        order.Number(), FormatRushMark(order.Rush),
+        FormatOrderItems(order),
         order.Price,
+        order.Discount,
+        formatNotificationLoyalty(order),
+        formatNotificationPromo(order),
+        order.RushSurcharge,
+        order.PriceAdjustment,
+        order.DeliveryFee,
         order.LeatherCost,
         order.ProcessCost,
         order.Commission,
@@ -111,44 +266,543 @@ func FormatOrderNotification(order storage.Order) string {
         order.NetRevenue,
         order.Profit,
         order.Contact,
-        order.Status,
+        order.Fulfilment,
+        StatusLabel(order.Status),
+        FormatDueDate(order.DueDate),
         order.CreatedAt.Format("02.01.2006 15:04"),
     )
 }
 
-func FormatPriceBreakdown(width, height int, prices map[string]float64) string {
+// formatNotificationPromo is the promo line of the admin notification
+func formatNotificationPromo(order storage.Order) string {
+    if order.PromoCode == nil {
+        return ""
+    }
+    return fmt.Sprintf("- Промокод %s: %.2f руб\n", *order.PromoCode, order.PromoDiscount)
+}
+
+// formatNotificationLoyalty is the loyalty line of the admin notification
+func formatNotificationLoyalty(order storage.Order) string {
+    if order.LoyaltyRate <= 0 {
+        return ""
+    }
+    return fmt.Sprintf("- Скидка постоянного клиента (%.0f%%): %.2f руб\n", order.LoyaltyRate*100, order.LoyaltyDiscount)
+}
+
+// FormatRushMark flags rush orders next to their number or due date
+func FormatRushMark(rush bool) string {
+    if rush {
+        return " ⚡ СРОЧНО"
+    }
+    return ""
+}
+
+// FormatDueDate renders an order's execution date for chat messages
+func FormatDueDate(dueDate *time.Time) string {
+    if dueDate == nil {
+        return "не указана"
+    }
+    return dueDate.Format("02.01.2006")
+}
+
+// FormatPromoDiscount describes the discount of a promo code: "10%" or
+// "500.00 ₽", with the textures it is limited to
+func FormatPromoDiscount(promo storage.PromoCode) string {
+    discount := fmt.Sprintf("%g%%", promo.Value)
+    if promo.Kind == storage.PromoFixed {
+        discount = fmt.Sprintf("%s ₽", money.FromRubles(promo.Value))
+    }
+    if len(promo.Textures) > 0 {
+        discount += " на текстуры: " + strings.Join(promo.Textures, ", ")
+    }
+    return discount
+}
+
+// FormatOrderPromo is a message line with the promo discount of an order,
+// empty when it has none
+func FormatOrderPromo(order storage.Order) string {
+    if order.PromoCode == nil || order.PromoDiscount <= 0 {
+        return ""
+    }
+    return fmt.Sprintf("Промокод %s: −%.2f ₽\n", *order.PromoCode, order.PromoDiscount)
+}
+
+// FormatOrderLoyalty is a message line with the loyalty discount of an
+// order, empty when it has none
+func FormatOrderLoyalty(order storage.Order) string {
+    if order.LoyaltyDiscount <= 0 {
+        return ""
+    }
+    return fmt.Sprintf("Скидка постоянного клиента %.0f%%: −%.2f ₽\n", order.LoyaltyRate*100, order.LoyaltyDiscount)
+}
+
+// FormatLoyaltyTier describes a loyalty tier: "5% с 3-го заказа" or
+// "10% при покупках от 10000.00 ₽"
+func FormatLoyaltyTier(tier LoyaltyTier) string {
+    if tier.MinOrders > 0 {
+        return fmt.Sprintf("%.0f%% с %d-го заказа", tier.Rate*100, tier.MinOrders)
+    }
+    return fmt.Sprintf("%.0f%% при покупках от %s ₽", tier.Rate*100, tier.MinSpent)
+}
+
+// FormatLoyaltyStatus tells a customer their loyalty discount and what it
+// takes to reach the next one; empty when there are no tiers
+func FormatLoyaltyStatus(cfg LoyaltyConfig, stats storage.CustomerStats) string {
+    var lines []string
+    if tier, ok := cfg.Tier(stats); ok {
+        lines = append(lines, fmt.Sprintf("💎 Ваша скидка постоянного клиента: %s", FormatLoyaltyTier(tier)))
+    }
+    if next, ok := cfg.NextTier(stats); ok {
+        prefix := "Следующая скидка: "
+        if len(lines) == 0 {
+            prefix = "💎 Скидка постоянного клиента: "
+        }
+        line := prefix + FormatLoyaltyTier(next)
+        if next.MinSpent > 0 {
+            line += fmt.Sprintf(" (осталось %s ₽)", next.MinSpent-money.FromRubles(stats.Spent))
+        }
+        lines = append(lines, line)
+    }
+    return strings.Join(lines, "\n")
+}
+
+// FormatPromoCode is one line of the /promos list
+func FormatPromoCode(promo storage.PromoCodeStats) string {
+    mark := "⏸"
+    if promo.Active {
+        mark = "✅"
+    }
+
+    var validity []string
+    if promo.ValidFrom != nil {
+        validity = append(validity, "с "+promo.ValidFrom.Format("02.01.2006"))
+    }
+    if promo.ValidUntil != nil {
+        validity = append(validity, "по "+promo.ValidUntil.Format("02.01.2006"))
+    }
+    if len(validity) == 0 {
+        validity = append(validity, "бессрочно")
+    }
+
+    uses := fmt.Sprintf("%d", promo.Uses)
+    if promo.MaxUses > 0 {
+        uses += fmt.Sprintf("/%d", promo.MaxUses)
+    }
+    perUser := "без ограничения на клиента"
+    if promo.MaxUsesPerUser > 0 {
+        perUser = fmt.Sprintf("до %d на клиента", promo.MaxUsesPerUser)
+    }
+
+    return fmt.Sprintf("%s %s — %s, %s; использований: %s (%s), скидок на %.2f ₽",
+        mark, promo.Code, FormatPromoDiscount(promo.PromoCode), strings.Join(validity, " "),
+        uses, perUser, promo.Discount)
+}
+
+// FormatDeliveryFee is a message line with the delivery fee, empty when delivery is free
+func FormatDeliveryFee(fee float64) string {
+    if fee <= 0 {
+        return ""
+    }
+    return fmt.Sprintf("Доставка: %.2f ₽\n", fee)
+}
+
+// FormatPriceAdjustment is a message line with what the minimum order price
+// and rounding changed, empty when they changed nothing
+func FormatPriceAdjustment(adjustment float64) string {
+    if adjustment == 0 {
+        return ""
+    }
+    return fmt.Sprintf("Округление и минимальная сумма заказа: %+.2f ₽\n", adjustment)
+}
+
+// FormatPriceChange renders an old → new price with the signed difference
+func FormatPriceChange(oldPrice, newPrice float64) string {
+    return fmt.Sprintf("%.2f ₽ → %.2f ₽ (%+.2f ₽)", oldPrice, newPrice, newPrice-oldPrice)
+}
+
+// FormatOrderHeadline describes an order in one line by its first item
+func FormatOrderHeadline(order storage.Order) string {
+    if len(order.Items) == 0 {
+        return FormatItemSize(order.Shape, order.WidthCM, order.HeightCM, order.CornerRadiusCM)
+    }
+
+    first := order.Items[0]
+    headline := fmt.Sprintf("%s, %s × %d шт.",
+        FormatItemSize(first.Shape, first.WidthCM, first.HeightCM, first.CornerRadiusCM), first.TextureName, first.Quantity)
+    if more := len(order.Items) - 1; more > 0 {
+        headline += fmt.Sprintf(" и ещё %d поз.", more)
+    }
+    return headline
+}
+
+// FormatStatusTimeline lists the status changes of an order, oldest first
+func FormatStatusTimeline(history []storage.OrderStatusChange) string {
+    var sb strings.Builder
+    sb.WriteString("🕓 История статусов:")
+    for _, change := range history {
+        sb.WriteString(fmt.Sprintf("\n%s — %s",
+            change.ChangedAt.Format("02.01.2006 15:04"), StatusLabel(change.ToStatus)))
+        if change.Comment != "" {
+            sb.WriteString(fmt.Sprintf(" (%s)", change.Comment))
+        }
+    }
+    return sb.String()
+}
+
+// FormatOrderItems lists the order's items one per line. Orders loaded
+// without items are shown from the header columns.
+func FormatOrderItems(order storage.Order) string {
+    items := order.Items
+    if len(items) == 0 {
+        items = []storage.OrderItem{{
+            TextureName:    order.TextureName,
+            WidthCM:        order.WidthCM,
+            HeightCM:       order.HeightCM,
+            Shape:          order.Shape,
+            CornerRadiusCM: order.CornerRadiusCM,
+            Quantity:       1,
+            Price:          order.Price,
+        }}
+    }
+
+    lines := make([]string, 0, len(items))
+    for i, item := range items {
+        line := fmt.Sprintf("%d. %s", i+1, FormatItemSize(item.Shape, item.WidthCM, item.HeightCM, item.CornerRadiusCM))
+        if item.TextureName != "" {
+            line += ", " + item.TextureName
+        }
+        if item.Quantity > 1 {
+            line += fmt.Sprintf(" × %d шт.", item.Quantity)
+        }
+        line += fmt.Sprintf(" — %.2f ₽", item.Price)
+        lines = append(lines, line)
+    }
+    return strings.Join(lines, "\n")
+}
+
+// maxThreadMessages keeps an order conversation within a single Telegram message
+const maxThreadMessages = 20
+
+// FormatOrderThread renders the latest messages of an order conversation
+func FormatOrderThread(messages []storage.OrderMessage) string {
+    if len(messages) == 0 {
+        return "💬 Переписки по заказу пока нет"
+    }
+
+    var sb strings.Builder
+    sb.WriteString("💬 Переписка:\n")
+    if len(messages) > maxThreadMessages {
+        sb.WriteString(fmt.Sprintf("(показаны последние %d из %d)\n", maxThreadMessages, len(messages)))
+        messages = messages[len(messages)-maxThreadMessages:]
+    }
+    for _, message := range messages {
+        author := "👤 Клиент"
+        if message.FromAdmin {
+            author = "🛠 Менеджер"
+        }
+        sb.WriteString(fmt.Sprintf("\n%s, %s:\n%s\n",
+            author, message.CreatedAt.Format("02.01 15:04"), message.Text))
+    }
+    return sb.String()
+}
+
+// FormatQuoteRequest describes a quote request for admins
+func FormatQuoteRequest(quote storage.QuoteRequest) string {
+    status := "⏳ ожидает цены"
+    if quote.Status == storage.QuoteStatusQuoted && quote.PricePerDM2 != nil {
+        status = fmt.Sprintf("💬 предложено %.2f ₽/дм², ждём ответа клиента", *quote.PricePerDM2)
+    }
+    return fmt.Sprintf(
+        "📨 Запрос на расчёт #%d\n\n"+
+            "Текстура: %s\n"+
+            "Размеры: %s × %d шт.\n"+
+            "Срок выполнения: %s\n"+
+            "Контакт: %s\n"+
+            "Получение: %s\n"+
+            "Статус: %s\n\n"+
+            "Укажите цену материала за дм²: /quote %d <цена>",
+        quote.ID,
+        quote.TextureDescription,
+        FormatItemSize(quote.Shape, quote.WidthCM, quote.HeightCM, quote.CornerRadiusCM), quote.Quantity,
+        FormatDueDate(quote.DueDate),
+        FormatPhoneNumber(quote.Contact),
+        quote.Fulfilment,
+        status,
+        quote.ID,
+    )
+}
+
+// FormatQuoteOffer is the priced quote sent to the customer
+func FormatQuoteOffer(quote storage.QuoteRequest, priceDetails PriceBreakdown, adjustment, deliveryFee money.Kopecks) string {
+    discount := ""
+    if priceDetails.Discount > 0 {
+        discount = fmt.Sprintf("Скидка за количество: %s ₽\n", priceDetails.Discount)
+    }
+    return fmt.Sprintf(
+        "💬 Предложение по запросу #%d\n\n"+
+            "🧵 Текстура: %s\n"+
+            "📏 Размер: %s × %d шт.\n"+
+            "🗓 Срок выполнения: %s\n"+
+            "📦 Получение: %s\n"+
+            "%s%s%s"+
+            "💰 Итоговая цена: %s ₽\n\n"+
+            "Нажмите «✅ Принять», чтобы оформить заказ.",
+        quote.ID,
+        quote.TextureDescription,
+        FormatItemSize(quote.Shape, quote.WidthCM, quote.HeightCM, quote.CornerRadiusCM), quote.Quantity,
+        FormatDueDate(quote.DueDate),
+        quote.Fulfilment,
+        discount,
+        FormatPriceAdjustment(adjustment.Rubles()),
+        FormatDeliveryFee(deliveryFee.Rubles()),
+        priceDetails.FinalPrice+adjustment+deliveryFee,
+    )
+}
+
+// FormatCraftsmanAssignment tells a craftsman what they have to make
+func FormatCraftsmanAssignment(order storage.Order) string {
+    return fmt.Sprintf(
+        "👷 Вам назначен заказ %s%s\n\n"+
+            "Позиции:\n%s\n"+
+            "Срок: %s\n"+
+            "Получение: %s\n\n"+
+            "Ваша очередь: /my_queue",
+        order.Number(), FormatRushMark(order.Rush),
+        FormatOrderItems(order),
+        FormatDueDate(order.DueDate),
+        order.Fulfilment,
+    )
+}
+
+// FormatCraftsmanQueue lists a craftsman's open orders, earliest due first
+func FormatCraftsmanQueue(orders []storage.Order, now time.Time) string {
+    if len(orders) == 0 {
+        return "👷 В вашей очереди нет заказов"
+    }
+
+    var sb strings.Builder
+    sb.WriteString(fmt.Sprintf("👷 Ваша очередь: %d\n", len(orders)))
+    for _, order := range orders {
+        due := "срок " + FormatDueDate(order.DueDate)
+        if order.IsOverdue(now) {
+            due += " ⏰ просрочен"
+        }
+        sb.WriteString(fmt.Sprintf("\n%s%s — %s · %s\n%s\n",
+            order.Number(), FormatRushMark(order.Rush), due, StatusLabel(order.Status), FormatOrderItems(order)))
+    }
+    return sb.String()
+}
+
+// FormatStaffWorkload is the /stats section on how open orders are spread
+// across craftsmen. The result is Markdown.
+func FormatStaffWorkload(workload storage.StaffWorkload) string {
+    var sb strings.Builder
+    sb.WriteString("👷 Загрузка мастеров:\n")
+    if len(workload.Craftsmen) == 0 {
+        sb.WriteString("мастера не добавлены (/staff)\n")
+    }
+    for _, craftsman := range workload.Craftsmen {
+        sb.WriteString(fmt.Sprintf("%s: %d", escapeMarkdown(craftsman.Name), craftsman.OpenOrders))
+        if craftsman.OverdueOrders > 0 {
+            sb.WriteString(fmt.Sprintf(" (⏰ %d)", craftsman.OverdueOrders))
+        }
+        sb.WriteString("\n")
+    }
+    sb.WriteString(fmt.Sprintf("Не назначено: %d", workload.Unassigned))
+    return sb.String()
+}
+
+// escapeMarkdown protects user-entered text inside a legacy Markdown message
+func escapeMarkdown(text string) string {
+    return strings.NewReplacer("_", "\\_", "*", "\\*", "`", "\\`", "[", "\\[").Replace(text)
+}
+
+// FormatCapacity renders the limits of a day
+func FormatCapacity(capacity storage.Capacity) string {
+    if capacity.Closed {
+        return "закрыто"
+    }
+    if capacity.Unlimited() {
+        return "без ограничений"
+    }
+    var parts []string
+    if capacity.MaxOrders > 0 {
+        parts = append(parts, fmt.Sprintf("до %d заказов", capacity.MaxOrders))
+    }
+    if capacity.MaxAreaDM2 > 0 {
+        parts = append(parts, fmt.Sprintf("до %.1f дм²", capacity.MaxAreaDM2))
+    }
+    return strings.Join(parts, ", ")
+}
+
+// FormatCapacityOverview shows the default limits and the load of the given days
+func FormatCapacityOverview(capacity storage.Capacity, days []storage.DayLoad) string {
+    var sb strings.Builder
+    sb.WriteString("🏭 Загрузка производства\n")
+    sb.WriteString(fmt.Sprintf("Лимит по умолчанию: %s\n", FormatCapacity(capacity)))
+    for _, day := range days {
+        mark := "🟢"
+        if !day.Fits(0) {
+            mark = "🔴"
+        }
+        sb.WriteString(fmt.Sprintf("\n%s %s: %d заказов, %.1f дм² (%s)",
+            mark, day.Day.Format("02.01.2006"), day.Orders, day.AreaDM2, FormatCapacity(day.Capacity)))
+    }
+    return sb.String()
+}
+
+// FormatOverdueDigest lists open orders past their due date for admins
+func FormatOverdueDigest(orders []storage.Order, now time.Time) string {
+    var sb strings.Builder
+    sb.WriteString(fmt.Sprintf("⏰ Просроченные заказы: %d\n", len(orders)))
+    for _, order := range orders {
+        sb.WriteString(fmt.Sprintf("\n%s%s — срок %s, просрочен на %d дн.\n%s · %s · %s\n",
+            order.Number(), FormatRushMark(order.Rush),
+            FormatDueDate(order.DueDate),
+            storage.DaysOverdue(*order.DueDate, now),
+            StatusLabel(order.Status),
+            FormatOrderHeadline(order),
+            FormatPhoneNumber(order.Contact),
+        ))
+    }
+    sb.WriteString("\nИзменить статус: /status <номер_заказа> <новый_статус>")
+    return sb.String()
+}
+
+// FormatOverdueApology fills the apology template with the order's code and due date
+func FormatOverdueApology(template string, order storage.Order) string {
+    return strings.NewReplacer(
+        "{code}", order.Number(),
+        "{due_date}", FormatDueDate(order.DueDate),
+    ).Replace(template)
+}
+
+func FormatCustomerCancellation(order storage.Order, reason string) string {
+    if reason == "" {
+        reason = "не указана"
+    }
+    return fmt.Sprintf(
+        "⚠️ Клиент отменил заказ %s\n\n"+
+            "Позиции:\n%s\n"+
+            "Цена: %.2f руб\n"+
+            "Срок выполнения: %s\n"+
+            "Контакт: %s\n"+
+            "Причина: %s",
+        order.Number(),
+        FormatOrderItems(order),
+        order.Price,
+        FormatDueDate(order.DueDate),
+        FormatPhoneNumber(order.Contact),
+        reason,
+    )
+}
+
+func FormatPriceBreakdown(width, height int, prices PriceBreakdown) string {
     return fmt.Sprintf(
         `
             📏 Размер: %d×%d см
-            💵 Итоговая цена: %.2f₽
+            💵 Итоговая цена: %s₽
 
             📊 Детали расчета:
-            - Стоимость кожи: %.2f₽
-            - Обработка: %.2f₽
-            - Комиссия платежа (3%%): %.2f₽
-            - Налог (6%%): %.2f₽
+            - Стоимость кожи: %s₽
+            - Обработка: %s₽
+            - Комиссия платежа (3%%): %s₽
+            - Налог (6%%): %s₽
             ────────────────────
-            Чистая выручка: %.2f₽
-            Прибыль: %.2f₽
+            Чистая выручка: %s₽
+            Прибыль: %s₽
         `,
         width, height,
-        prices["final_price"],
-        prices["leather_cost"],
-        prices["processing_cost"],
-        prices["commission"],
-        prices["tax"],
-        prices["net_revenue"],
-        prices["profit"],
+        prices.FinalPrice,
+        prices.LeatherCost,
+        prices.ProcessingCost,
+        prices.Commission,
+        prices.Tax,
+        prices.NetRevenue,
+        prices.Profit,
     )
 }
 
-func FormatSimplePriceBreakdown(width, height int, finalPrice float64) string {
+func FormatSimplePriceBreakdown(width, height int, finalPrice money.Kopecks) string {
     return fmt.Sprintf(
         `📏 Размер: %d×%d см
-        💰 Итоговая цена: %.2f₽
+        💰 Итоговая цена: %s₽
 
         Нажмите "Подтвердить" для оформления заказа`,
         width, height,
         finalPrice,
     )
 }
+
+var statusLabels = map[string]string{
+    storage.StatusNew:          "Новый",
+    storage.StatusConfirmed:    "Подтверждён",
+    storage.StatusInProduction: "В производстве",
+    storage.StatusReady:        "Готов",
+    storage.StatusDelivered:    "Выдан",
+    storage.StatusCancelled:    "Отменён",
+}
+
+// StatusLabel returns the human-readable name of an order status
+func StatusLabel(status string) string {
+    if label, ok := statusLabels[status]; ok {
+        return label
+    }
+    return status
+}
+
+func FormatStatusTransitionError(orderNumber string, from, to string) string {
+    allowed := storage.AllowedStatusTransitions(from)
+    if len(allowed) == 0 {
+        return fmt.Sprintf(
+            "Заказ %s в статусе «%s» — статус больше нельзя изменить",
+            orderNumber, StatusLabel(from))
+    }
+
+    labels := make([]string, 0, len(allowed))
+    for _, status := range allowed {
+        labels = append(labels, fmt.Sprintf("%s (%s)", StatusLabel(status), status))
+    }
+    return fmt.Sprintf(
+        "Нельзя перевести заказ %s из статуса «%s» в «%s».\nДопустимые переходы: %s",
+        orderNumber, StatusLabel(from), StatusLabel(to), strings.Join(labels, ", "))
+}
+
+// FormatPriceFormula is one line of the /formula list
+func FormatPriceFormula(f storage.PriceFormula) string {
+    mark := "⏸"
+    if f.Active {
+        mark = "✅"
+    }
+    line := fmt.Sprintf("%s #%d %s: %s", mark, f.ID, f.ServiceType, f.Formula)
+
+    names := make([]string, 0, len(f.Parameters))
+    for name := range f.Parameters {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        line += fmt.Sprintf("; %s=%g", name, f.Parameters[name])
+    }
+    return line
+}
+
+// FormatFormulaVariables lists the variables every price formula can use
+func FormatFormulaVariables() string {
+    names := make([]string, 0, len(FormulaVariables))
+    for name := range FormulaVariables {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    var sb strings.Builder
+    sb.WriteString("Переменные: ")
+    for i, name := range names {
+        if i > 0 {
+            sb.WriteString(", ")
+        }
+        sb.WriteString(fmt.Sprintf("%s — %s", name, FormulaVariables[name]))
+    }
+    sb.WriteString("\nФункции: min, max, round, ceil, floor, abs")
+    return sb.String()
+}
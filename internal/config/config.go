@@ -3,11 +3,51 @@ package config
 import (
 	"errors"
 	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/caarlos0/env/v9"
 )
 
+// Price rounding modes
+const (
+	RoundUp      = "up"
+	RoundNearest = "nearest"
+)
+
+// Tiers maps a threshold to a discount rate. It is read from comma
+// separated "threshold:rate" pairs; "off" or "none" turns the discount
+// off, since an empty value brings back the default.
+type Tiers map[int]float64
+
+func (t *Tiers) UnmarshalText(text []byte) error {
+	value := strings.TrimSpace(string(text))
+	tiers := Tiers{}
+	if value == "off" || value == "none" {
+		*t = tiers
+		return nil
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		threshold, rate, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok {
+			return fmt.Errorf("invalid tier %q, expected threshold:rate", pair)
+		}
+		key, err := strconv.Atoi(strings.TrimSpace(threshold))
+		if err != nil {
+			return fmt.Errorf("invalid tier threshold %q: %w", threshold, err)
+		}
+		tiers[key], err = strconv.ParseFloat(strings.TrimSpace(rate), 64)
+		if err != nil {
+			return fmt.Errorf("invalid tier rate %q: %w", rate, err)
+		}
+	}
+	*t = tiers
+	return nil
+}
+
 type Config struct {
 	Telegram struct {
 		Token string `env:"TELEGRAM_TOKEN,required"`
@@ -44,8 +84,58 @@ type Config struct {
         PaymentCommissionRate float64 `env:"PAYMENT_COMMISSION_RATE" envDefault:"0.03"`
         SalesTaxRate          float64 `env:"SALES_TAX_RATE" envDefault:"0.06"`
         MarkupMultiplier      float64 `env:"MARKUP_MULTIPLIER" envDefault:"2.5"`
+        // QuantityDiscounts lists "min_pieces:rate" tiers, e.g. "5:0.10,10:0.15"; "off" disables them
+        QuantityDiscounts Tiers `env:"QUANTITY_DISCOUNTS" envDefault:"5:0.10,10:0.15"`
+        // EdgeCostPerM is charged per metre of cut edge, so curved shapes cost their outline
+        EdgeCostPerM float64 `env:"EDGE_COST_PER_M" envDefault:"0"`
+        // SetupFee is added to every item for setting up its cut
+        SetupFee float64 `env:"SETUP_FEE" envDefault:"0"`
+        // MinFinalPrice is the least the items of an order cost the customer; 0 disables it
+        MinFinalPrice float64 `env:"MIN_FINAL_PRICE" envDefault:"0"`
+        // RoundingStep rounds the items total of an order to 1, 10 or 50 roubles;
+        // 0 keeps kopecks. RoundingMode is "up" or "nearest".
+        RoundingStep int    `env:"PRICE_ROUNDING_STEP" envDefault:"0"`
+        RoundingMode string `env:"PRICE_ROUNDING_MODE" envDefault:"nearest"`
     }
 
+	Delivery struct {
+		// PickupPoints are offered for self-pickup, separated by ";"
+		PickupPoints []string `env:"PICKUP_POINTS" envSeparator:";" envDefault:"Мастерская"`
+		CourierFee   float64  `env:"COURIER_FEE" envDefault:"350"`
+		// FreeCourierFrom waives the courier fee from this order total on; 0 disables it
+		FreeCourierFrom float64 `env:"FREE_COURIER_FROM" envDefault:"0"`
+	}
+
+	Rush struct {
+		// MinWorkingDays: a due date fewer working days away makes the order a rush order
+		MinWorkingDays int `env:"RUSH_MIN_WORKING_DAYS" envDefault:"3"`
+		// SurchargeRate is added to the discounted price of rush orders, e.g. 0.3 for +30%
+		SurchargeRate float64 `env:"RUSH_SURCHARGE_RATE" envDefault:"0.3"`
+	}
+
+	Loyalty struct {
+		// OrderTiers lists "order_number:rate" tiers, e.g. "3:0.05" gives 5% off from the third order on; "off" disables them
+		OrderTiers Tiers `env:"LOYALTY_ORDER_TIERS" envDefault:"3:0.05"`
+		// SpendTiers lists "roubles_spent:rate" tiers, e.g. "10000:0.10" gives 10% off once 10 000 ₽ are spent; "off" disables them
+		SpendTiers Tiers `env:"LOYALTY_SPEND_TIERS" envDefault:"10000:0.10"`
+	}
+
+	Overdue struct {
+		// DigestHour is the hour of the day from which the overdue digest is sent
+		DigestHour int `env:"OVERDUE_DIGEST_HOUR" envDefault:"9"`
+		// NotifyCustomers sends ApologyTemplate once to the customer of every overdue order;
+		// {code} and {due_date} are replaced with the order's values
+		NotifyCustomers bool   `env:"OVERDUE_NOTIFY_CUSTOMERS" envDefault:"false"`
+		ApologyTemplate string `env:"OVERDUE_APOLOGY_TEMPLATE" envDefault:"Приносим извинения: заказ {code} не успел к сроку {due_date}. Мы уже работаем над ним и сообщим, как только он будет готов."`
+	}
+
+	OrderCode struct {
+		// Prefix starts every public order code, e.g. AD-2026-00042
+		Prefix string `env:"ORDER_CODE_PREFIX" envDefault:"AD"`
+		// Digits is the zero-padded width of the yearly order number
+		Digits int `env:"ORDER_CODE_DIGITS" envDefault:"5"`
+	}
+
 	MaxDimensions struct {
         Width  int `env:"MAX_WIDTH" envDefault:"80"`
         Height int `env:"MAX_HEIGHT" envDefault:"50"`
@@ -79,5 +169,75 @@ func (c *Config) Validate() error {
 		return errors.New("database name is required")
 	}
 
+	for minQuantity, rate := range c.Pricing.QuantityDiscounts {
+		if minQuantity < 2 || rate <= 0 || rate >= 1 {
+			return fmt.Errorf("invalid quantity discount tier %d:%.2f", minQuantity, rate)
+		}
+	}
+
+	if c.Pricing.EdgeCostPerM < 0 {
+		return errors.New("edge cost must not be negative")
+	}
+
+	if c.Pricing.SetupFee < 0 || c.Pricing.MinFinalPrice < 0 {
+		return errors.New("setup fee and minimum price must not be negative")
+	}
+
+	switch c.Pricing.RoundingStep {
+	case 0, 1, 10, 50:
+	default:
+		return fmt.Errorf("invalid price rounding step %d: use 0, 1, 10 or 50", c.Pricing.RoundingStep)
+	}
+
+	if c.Pricing.RoundingMode != RoundUp && c.Pricing.RoundingMode != RoundNearest {
+		return fmt.Errorf("invalid price rounding mode %q: use %q or %q", c.Pricing.RoundingMode, RoundUp, RoundNearest)
+	}
+
+	if len(c.Delivery.PickupPoints) == 0 {
+		return errors.New("at least one pickup point is required")
+	}
+
+	if c.Delivery.CourierFee < 0 || c.Delivery.FreeCourierFrom < 0 {
+		return errors.New("delivery fees must not be negative")
+	}
+
+	if c.Rush.MinWorkingDays < 0 {
+		return errors.New("rush working days must not be negative")
+	}
+
+	if c.Rush.SurchargeRate < 0 {
+		return errors.New("rush surcharge must not be negative")
+	}
+
+	for orderNumber, rate := range c.Loyalty.OrderTiers {
+		if orderNumber < 2 || rate <= 0 || rate >= 1 {
+			return fmt.Errorf("invalid loyalty order tier %d:%.2f", orderNumber, rate)
+		}
+	}
+
+	for spent, rate := range c.Loyalty.SpendTiers {
+		if spent <= 0 || rate <= 0 || rate >= 1 {
+			return fmt.Errorf("invalid loyalty spend tier %d:%.2f", spent, rate)
+		}
+	}
+
+	if c.Overdue.DigestHour < 0 || c.Overdue.DigestHour > 23 {
+		return errors.New("overdue digest hour must be between 0 and 23")
+	}
+
+	if c.Overdue.NotifyCustomers && c.Overdue.ApologyTemplate == "" {
+		return errors.New("overdue apology template is required to notify customers")
+	}
+
+	if !orderCodePrefix.MatchString(c.OrderCode.Prefix) {
+		return errors.New("order code prefix must be 1-10 capital latin letters or digits")
+	}
+
+	if c.OrderCode.Digits < 1 || c.OrderCode.Digits > 9 {
+		return errors.New("order code digits must be between 1 and 9")
+	}
+
 	return nil
 }
+
+var orderCodePrefix = regexp.MustCompile(`^[A-Z0-9]{1,10}$`)
@@ -0,0 +1,250 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// CapacityHorizon is how far ahead the nearest free day is looked for
+const CapacityHorizon = 90
+
+// ErrDayFull is returned when an order is saved for a due date that has no
+// capacity left, e.g. because another order took it after the date was picked
+var ErrDayFull = errors.New("no production capacity left on the due date")
+
+// capacityLockClass namespaces the advisory locks that serialize the orders
+// booked on one date
+const capacityLockClass = 18
+
+// Capacity limits the work due on one day. A zero limit is no limit; a
+// closed day, which only a date override can be, takes no orders at all.
+type Capacity struct {
+	MaxOrders  int     `db:"max_orders"`
+	MaxAreaDM2 float64 `db:"max_area_dm2"`
+	Closed     bool    `db:"closed"`
+}
+
+// Unlimited reports whether no limit is set
+func (c Capacity) Unlimited() bool {
+	return !c.Closed && c.MaxOrders <= 0 && c.MaxAreaDM2 <= 0
+}
+
+// DayLoad is the work already due on a day; cancelled and deleted orders do not count
+type DayLoad struct {
+	Day      time.Time `db:"day"`
+	Orders   int       `db:"orders"`
+	AreaDM2  float64   `db:"area_dm2"`
+	Capacity Capacity  `db:"-"`
+}
+
+// Fits reports whether one more order of areaDM2 can be made on the day.
+// An open day with no orders takes any single order, however large.
+func (l DayLoad) Fits(areaDM2 float64) bool {
+	if l.Capacity.Closed {
+		return false
+	}
+	if l.Orders == 0 {
+		return true
+	}
+	if l.Capacity.MaxOrders > 0 && l.Orders >= l.Capacity.MaxOrders {
+		return false
+	}
+	if l.Capacity.MaxAreaDM2 > 0 && l.AreaDM2+areaDM2 > l.Capacity.MaxAreaDM2 {
+		return false
+	}
+	return true
+}
+
+// Overbooked reports whether the load of a day, which already includes an
+// order just booked on it, breaks the limits; Fits seen after the booking
+func (l DayLoad) Overbooked() bool {
+	if l.Capacity.Closed {
+		return l.Orders > 0
+	}
+	if l.Orders <= 1 {
+		return false
+	}
+	if l.Capacity.MaxOrders > 0 && l.Orders > l.Capacity.MaxOrders {
+		return true
+	}
+	return l.Capacity.MaxAreaDM2 > 0 && l.AreaDM2 > l.Capacity.MaxAreaDM2
+}
+
+// itemAreaSQL is the area of an order_items row in cm², following the shape
+const itemAreaSQL = `
+	CASE i.shape
+		WHEN 'circle' THEN PI() * i.width_cm * i.height_cm / 4
+		WHEN 'oval' THEN PI() * i.width_cm * i.height_cm / 4
+		WHEN 'rounded_rect' THEN i.width_cm * i.height_cm - (4 - PI()) * i.corner_radius_cm * i.corner_radius_cm
+		ELSE i.width_cm * i.height_cm
+	END * i.quantity`
+
+func (s *PostgresStorage) GetDefaultCapacity(ctx context.Context) (Capacity, error) {
+	return defaultCapacity(ctx, s.db)
+}
+
+func defaultCapacity(ctx context.Context, q sqlx.QueryerContext) (Capacity, error) {
+	var capacity Capacity
+	err := sqlx.GetContext(ctx, q, &capacity, `SELECT max_orders, max_area_dm2 FROM production_capacity`)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return Capacity{}, fmt.Errorf("failed to get production capacity: %w", err)
+	}
+	return capacity, nil
+}
+
+func (s *PostgresStorage) SetDefaultCapacity(ctx context.Context, capacity Capacity, adminID int64) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO production_capacity (id, max_orders, max_area_dm2, updated_by, updated_at)
+		VALUES (TRUE, $1, $2, $3, NOW())
+		ON CONFLICT (id) DO UPDATE SET
+			max_orders = EXCLUDED.max_orders,
+			max_area_dm2 = EXCLUDED.max_area_dm2,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = EXCLUDED.updated_at`,
+		capacity.MaxOrders, capacity.MaxAreaDM2, adminID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set production capacity: %w", err)
+	}
+	return nil
+}
+
+// SetDayCapacity overrides the default limits for one date, or closes it
+func (s *PostgresStorage) SetDayCapacity(ctx context.Context, day time.Time, capacity Capacity, adminID int64) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO production_capacity_days (day, max_orders, max_area_dm2, closed, updated_by, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (day) DO UPDATE SET
+			max_orders = EXCLUDED.max_orders,
+			max_area_dm2 = EXCLUDED.max_area_dm2,
+			closed = EXCLUDED.closed,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = EXCLUDED.updated_at`,
+		day.Format("2006-01-02"), capacity.MaxOrders, capacity.MaxAreaDM2, capacity.Closed, adminID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set day capacity: %w", err)
+	}
+	return nil
+}
+
+// ResetDayCapacity returns a date to the default limits
+func (s *PostgresStorage) ResetDayCapacity(ctx context.Context, day time.Time) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM production_capacity_days WHERE day = $1`, day.Format("2006-01-02"))
+	if err != nil {
+		return fmt.Errorf("failed to reset day capacity: %w", err)
+	}
+	return nil
+}
+
+// GetDayLoads returns the load and limits of every day from from to to inclusive
+func (s *PostgresStorage) GetDayLoads(ctx context.Context, from, to time.Time) ([]DayLoad, error) {
+	return dayLoads(ctx, s.db, from, to)
+}
+
+func dayLoads(ctx context.Context, q sqlx.QueryerContext, from, to time.Time) ([]DayLoad, error) {
+	defaults, err := defaultCapacity(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	var overrides []struct {
+		Day time.Time `db:"day"`
+		Capacity
+	}
+	err = sqlx.SelectContext(ctx, q, &overrides, `
+		SELECT day, max_orders, max_area_dm2, closed FROM production_capacity_days
+		WHERE day BETWEEN $1 AND $2`,
+		from.Format("2006-01-02"), to.Format("2006-01-02"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get day capacities: %w", err)
+	}
+
+	var loads []DayLoad
+	err = sqlx.SelectContext(ctx, q, &loads, `
+		SELECT o.due_date AS day,
+		       COUNT(DISTINCT o.id) AS orders,
+		       COALESCE(SUM(`+itemAreaSQL+`), 0) / 100 AS area_dm2
+		FROM orders o
+		LEFT JOIN order_items i ON i.order_id = o.id
+		WHERE o.due_date BETWEEN $1 AND $2
+		  AND o.status <> $3 AND o.deleted_at IS NULL
+		GROUP BY o.due_date`,
+		from.Format("2006-01-02"), to.Format("2006-01-02"), StatusCancelled,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get day loads: %w", err)
+	}
+
+	byDay := make(map[string]DayLoad, len(loads))
+	for _, load := range loads {
+		byDay[load.Day.Format("2006-01-02")] = load
+	}
+	capacities := make(map[string]Capacity, len(overrides))
+	for _, override := range overrides {
+		capacities[override.Day.Format("2006-01-02")] = override.Capacity
+	}
+
+	var days []DayLoad
+	for day := dateOf(from); !day.After(dateOf(to)); day = day.AddDate(0, 0, 1) {
+		key := day.Format("2006-01-02")
+		load := byDay[key]
+		load.Day = day
+		load.Capacity = defaults
+		if capacity, ok := capacities[key]; ok {
+			load.Capacity = capacity
+		}
+		days = append(days, load)
+	}
+	return days, nil
+}
+
+// NearestFreeDay returns the first day from from on that still takes an
+// order of areaDM2, looking CapacityHorizon days ahead
+func (s *PostgresStorage) NearestFreeDay(ctx context.Context, from time.Time, areaDM2 float64) (time.Time, bool, error) {
+	days, err := s.GetDayLoads(ctx, from, from.AddDate(0, 0, CapacityHorizon))
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	for _, day := range days {
+		if day.Fits(areaDM2) {
+			return day.Day, true, nil
+		}
+	}
+	return time.Time{}, false, nil
+}
+
+// lockDueDate makes orders for the same due date wait for each other until
+// the transaction ends, so that bookDueDate sees every one of them
+func lockDueDate(ctx context.Context, tx *sqlx.Tx, day time.Time) error {
+	daysSinceEpoch := dateOf(day).Unix() / (24 * 60 * 60)
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1, $2)`, capacityLockClass, daysSinceEpoch); err != nil {
+		return fmt.Errorf("failed to lock due date: %w", err)
+	}
+	return nil
+}
+
+// bookDueDate checks, once the order is written, that its due date still
+// had room for it. The date was checked when the customer picked it, but
+// other orders may have been booked since.
+func bookDueDate(ctx context.Context, tx *sqlx.Tx, day time.Time) error {
+	loads, err := dayLoads(ctx, tx, day, day)
+	if err != nil {
+		return err
+	}
+	if loads[0].Overbooked() {
+		return ErrDayFull
+	}
+	return nil
+}
+
+// dateOf drops the time of day, keeping the calendar date
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,50 @@
+package storage
+
+import "testing"
+
+func TestDayLoadFits(t *testing.T) {
+	limits := Capacity{MaxOrders: 3, MaxAreaDM2: 100}
+
+	tests := []struct {
+		name string
+		load DayLoad
+		area float64
+		want bool
+	}{
+		{"unlimited", DayLoad{Orders: 50, AreaDM2: 5000}, 10, true},
+		{"room left", DayLoad{Orders: 2, AreaDM2: 60, Capacity: limits}, 40, true},
+		{"orders limit reached", DayLoad{Orders: 3, AreaDM2: 10, Capacity: limits}, 1, false},
+		{"area limit exceeded", DayLoad{Orders: 1, AreaDM2: 80, Capacity: limits}, 30, false},
+		{"empty day takes a large order", DayLoad{Capacity: limits}, 250, true},
+		{"orders only", DayLoad{Orders: 1, AreaDM2: 900, Capacity: Capacity{MaxOrders: 2}}, 900, true},
+		{"closed empty day", DayLoad{Capacity: Capacity{Closed: true}}, 1, false},
+		{"closed day without limits", DayLoad{Orders: 1, Capacity: Capacity{Closed: true}}, 0, false},
+	}
+	for _, tt := range tests {
+		if got := tt.load.Fits(tt.area); got != tt.want {
+			t.Errorf("%s: Fits(%.0f) = %v, want %v", tt.name, tt.area, got, tt.want)
+		}
+	}
+}
+
+func TestDayLoadOverbooked(t *testing.T) {
+	limits := Capacity{MaxOrders: 3, MaxAreaDM2: 100}
+
+	tests := []struct {
+		name string
+		load DayLoad
+		want bool
+	}{
+		{"unlimited", DayLoad{Orders: 50, AreaDM2: 5000}, false},
+		{"last order taken", DayLoad{Orders: 3, AreaDM2: 100, Capacity: limits}, false},
+		{"one order too many", DayLoad{Orders: 4, AreaDM2: 20, Capacity: limits}, true},
+		{"area exceeded", DayLoad{Orders: 2, AreaDM2: 100.5, Capacity: limits}, true},
+		{"a single large order", DayLoad{Orders: 1, AreaDM2: 250, Capacity: limits}, false},
+		{"closed day", DayLoad{Orders: 1, Capacity: Capacity{Closed: true}}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.load.Overbooked(); got != tt.want {
+			t.Errorf("%s: Overbooked() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
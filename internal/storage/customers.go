@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// CustomerStats is a customer's order history that loyalty discounts
+// follow. Only delivered orders count, so cancelled and unfinished ones
+// earn nothing.
+type CustomerStats struct {
+	Orders int `db:"delivered_orders"`
+	// Spent is what the delivered orders cost, delivery included
+	Spent float64 `db:"delivered_spent"`
+}
+
+// GetCustomerStats returns the delivered orders of a customer and what they
+// spent on them
+func (s *PostgresStorage) GetCustomerStats(ctx context.Context, userID int64) (CustomerStats, error) {
+	var stats CustomerStats
+	err := s.db.GetContext(ctx, &stats,
+		`SELECT delivered_orders, delivered_spent FROM users WHERE user_id = $1`, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return CustomerStats{}, nil
+	}
+	if err != nil {
+		return CustomerStats{}, fmt.Errorf("failed to get customer stats: %w", err)
+	}
+	return stats, nil
+}
+
+// countDeliveredOrder adds a just delivered order to its customer's stats
+func countDeliveredOrder(ctx context.Context, tx *sqlx.Tx, userID int64, price float64) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO users (user_id, delivered_orders, delivered_spent)
+		VALUES ($1, 1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET delivered_orders = users.delivered_orders + 1,
+			delivered_spent = users.delivered_spent + EXCLUDED.delivered_spent,
+			updated_at = NOW()`,
+		userID, price,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update customer stats: %w", err)
+	}
+	return nil
+}
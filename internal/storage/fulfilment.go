@@ -0,0 +1,36 @@
+package storage
+
+import "fmt"
+
+// Ways a finished order reaches the customer
+const (
+	FulfilmentPickup  = "pickup"
+	FulfilmentCourier = "courier"
+)
+
+// Fulfilment describes how a finished order reaches the customer. For pickup
+// Address is the chosen pickup point, for courier delivery the customer's
+// address, optionally with the coordinates of a shared location.
+type Fulfilment struct {
+	Method    string   `db:"fulfilment" json:"method"`
+	Address   string   `db:"delivery_address" json:"address"`
+	Latitude  *float64 `db:"delivery_latitude" json:"latitude,omitempty"`
+	Longitude *float64 `db:"delivery_longitude" json:"longitude,omitempty"`
+}
+
+// HasLocation reports whether the customer shared a location
+func (f Fulfilment) HasLocation() bool {
+	return f.Latitude != nil && f.Longitude != nil
+}
+
+// String renders the fulfilment for messages and exports
+func (f Fulfilment) String() string {
+	switch f.Method {
+	case FulfilmentPickup:
+		return fmt.Sprintf("Самовывоз: %s", f.Address)
+	case FulfilmentCourier:
+		return fmt.Sprintf("Доставка курьером: %s", f.Address)
+	default:
+		return "не указано"
+	}
+}
@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE orders ADD COLUMN due_date DATE;
+
+CREATE INDEX idx_orders_due_date ON orders (due_date);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_orders_due_date;
+ALTER TABLE orders DROP COLUMN due_date;
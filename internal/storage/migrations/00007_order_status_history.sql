@@ -0,0 +1,34 @@
+-- +goose Up
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_status_check;
+
+UPDATE orders SET status = 'in_production' WHERE status = 'processing';
+UPDATE orders SET status = 'delivered' WHERE status = 'completed';
+
+ALTER TABLE orders
+ADD CONSTRAINT orders_status_check CHECK (
+    status IN ('new', 'confirmed', 'in_production', 'ready', 'delivered', 'cancelled')
+);
+
+CREATE TABLE order_status_history (
+    id          BIGSERIAL PRIMARY KEY,
+    order_id    INTEGER     NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    from_status VARCHAR(20),
+    to_status   VARCHAR(20) NOT NULL,
+    changed_by  BIGINT      NOT NULL,
+    changed_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_order_status_history_order_id ON order_status_history (order_id, changed_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_order_status_history_order_id;
+DROP TABLE IF EXISTS order_status_history;
+
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_status_check;
+
+UPDATE orders SET status = 'new' WHERE status = 'confirmed';
+UPDATE orders SET status = 'processing' WHERE status IN ('in_production', 'ready');
+UPDATE orders SET status = 'completed' WHERE status = 'delivered';
+
+ALTER TABLE orders
+ADD CONSTRAINT orders_status_check CHECK (status IN ('new', 'processing', 'completed', 'cancelled'));
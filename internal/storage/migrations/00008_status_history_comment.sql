@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE order_status_history ADD COLUMN comment TEXT;
+
+-- +goose Down
+ALTER TABLE order_status_history DROP COLUMN comment;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- GetUserOrders and DeleteUserData already rely on soft deletion
+ALTER TABLE orders ADD COLUMN deleted_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE orders DROP COLUMN deleted_at;
@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE order_changes (
+    id         BIGSERIAL PRIMARY KEY,
+    order_id   INTEGER        NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    changed_by BIGINT         NOT NULL,
+    field      VARCHAR(20)    NOT NULL,
+    old_value  TEXT           NOT NULL,
+    new_value  TEXT           NOT NULL,
+    old_price  DECIMAL(10, 2) NOT NULL,
+    new_price  DECIMAL(10, 2) NOT NULL,
+    changed_at TIMESTAMPTZ    NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_order_changes_order_id ON order_changes (order_id, changed_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_order_changes_order_id;
+DROP TABLE IF EXISTS order_changes;
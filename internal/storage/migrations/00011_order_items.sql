@@ -0,0 +1,39 @@
+-- +goose Up
+-- An order is now a header with one or more items. The header keeps the price
+-- totals; its width_cm, height_cm and texture_id keep describing the first item
+-- so older reports continue to work.
+CREATE TABLE order_items (
+    id           BIGSERIAL PRIMARY KEY,
+    order_id     INTEGER        NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    position     INTEGER        NOT NULL,
+    texture_id   UUID           NOT NULL REFERENCES textures(id) ON DELETE RESTRICT,
+    width_cm     INTEGER        NOT NULL CHECK (width_cm > 0 AND width_cm <= 80),
+    height_cm    INTEGER        NOT NULL CHECK (height_cm > 0 AND height_cm <= 50),
+    quantity     INTEGER        NOT NULL DEFAULT 1 CHECK (quantity > 0),
+    price        DECIMAL(10, 2) NOT NULL CHECK (price > 0),
+    leather_cost DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    process_cost DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    total_cost   DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    commission   DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    tax          DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    net_revenue  DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    profit       DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    CONSTRAINT unique_order_item_position UNIQUE (order_id, position)
+);
+
+CREATE INDEX idx_order_items_order_id ON order_items (order_id);
+
+-- Every existing order becomes a single-item order
+INSERT INTO order_items (
+    order_id, position, texture_id, width_cm, height_cm, quantity, price,
+    leather_cost, process_cost, total_cost, commission, tax, net_revenue, profit
+)
+SELECT
+    id, 1, texture_id, width_cm, height_cm, 1, price,
+    leather_cost, process_cost, total_cost, commission, tax, net_revenue, profit
+FROM orders
+WHERE width_cm > 0 AND height_cm > 0;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_order_items_order_id;
+DROP TABLE IF EXISTS order_items;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- Quantity discounts are taken off the marked-up price. The amount is kept
+-- separately so reports can show gross price, discount and profit honestly.
+ALTER TABLE orders ADD COLUMN discount DECIMAL(10, 2) NOT NULL DEFAULT 0;
+ALTER TABLE order_items ADD COLUMN discount DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE order_items DROP COLUMN IF EXISTS discount;
+ALTER TABLE orders DROP COLUMN IF EXISTS discount;
@@ -0,0 +1,31 @@
+-- +goose Up
+-- Orders for a texture that is not in the catalogue start as quote requests.
+-- Once an admin names a price per dm² a hidden texture is created for the
+-- request, so the accepted quote becomes a normal order.
+CREATE TABLE quote_requests (
+    id                  BIGSERIAL PRIMARY KEY,
+    user_id             BIGINT         NOT NULL,
+    contact             VARCHAR(50)    NOT NULL,
+    texture_description TEXT           NOT NULL,
+    width_cm            INTEGER        NOT NULL CHECK (width_cm > 0 AND width_cm <= 80),
+    height_cm           INTEGER        NOT NULL CHECK (height_cm > 0 AND height_cm <= 50),
+    quantity            INTEGER        NOT NULL DEFAULT 1 CHECK (quantity > 0),
+    due_date            DATE,
+    status              VARCHAR(20)    NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'quoted', 'accepted', 'declined')),
+    price_per_dm2       DECIMAL(10, 2) CHECK (price_per_dm2 > 0),
+    texture_id          UUID           REFERENCES textures(id) ON DELETE RESTRICT,
+    quoted_by           BIGINT,
+    quoted_at           TIMESTAMPTZ,
+    order_id            INTEGER        REFERENCES orders(id) ON DELETE SET NULL,
+    created_at          TIMESTAMPTZ    NOT NULL DEFAULT NOW(),
+    updated_at          TIMESTAMPTZ    NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_quote_requests_user_id ON quote_requests (user_id);
+CREATE INDEX idx_quote_requests_status ON quote_requests (status);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_quote_requests_status;
+DROP INDEX IF EXISTS idx_quote_requests_user_id;
+DROP TABLE IF EXISTS quote_requests;
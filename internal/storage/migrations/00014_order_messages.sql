@@ -0,0 +1,16 @@
+-- +goose Up
+-- Clarifications about an order between the customer and the admins
+CREATE TABLE order_messages (
+    id         BIGSERIAL PRIMARY KEY,
+    order_id   INTEGER     NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    sender_id  BIGINT      NOT NULL,
+    from_admin BOOLEAN     NOT NULL DEFAULT FALSE,
+    text       TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_order_messages_order_id ON order_messages (order_id, created_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_order_messages_order_id;
+DROP TABLE IF EXISTS order_messages;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- Reference photos and files sent by customers. Only Telegram file IDs are
+-- stored; the files themselves stay on Telegram's servers.
+CREATE TABLE order_attachments (
+    id          BIGSERIAL PRIMARY KEY,
+    order_id    INTEGER      NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    kind        VARCHAR(10)  NOT NULL CHECK (kind IN ('photo', 'document')),
+    file_id     TEXT         NOT NULL,
+    file_name   VARCHAR(255) NOT NULL DEFAULT '',
+    caption     TEXT         NOT NULL DEFAULT '',
+    uploaded_by BIGINT       NOT NULL,
+    created_at  TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_order_attachments_order_id ON order_attachments (order_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_order_attachments_order_id;
+DROP TABLE IF EXISTS order_attachments;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- Serves the keyset-paginated order history of a customer
+CREATE INDEX idx_orders_user_id_id ON orders (user_id, id DESC) WHERE deleted_at IS NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_orders_user_id_id;
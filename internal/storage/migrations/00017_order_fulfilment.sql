@@ -0,0 +1,32 @@
+-- +goose Up
+-- How the order reaches the customer. delivery_address holds the pickup point
+-- for pickup and the customer's address for courier delivery; the coordinates
+-- are set when the customer shared a location. delivery_fee is included in price.
+ALTER TABLE orders
+    ADD COLUMN fulfilment         VARCHAR(10)      NOT NULL DEFAULT 'pickup'
+        CHECK (fulfilment IN ('pickup', 'courier')),
+    ADD COLUMN delivery_address   TEXT             NOT NULL DEFAULT '',
+    ADD COLUMN delivery_latitude  DOUBLE PRECISION,
+    ADD COLUMN delivery_longitude DOUBLE PRECISION,
+    ADD COLUMN delivery_fee       DECIMAL(10, 2)   NOT NULL DEFAULT 0 CHECK (delivery_fee >= 0);
+
+ALTER TABLE quote_requests
+    ADD COLUMN fulfilment         VARCHAR(10)      NOT NULL DEFAULT 'pickup'
+        CHECK (fulfilment IN ('pickup', 'courier')),
+    ADD COLUMN delivery_address   TEXT             NOT NULL DEFAULT '',
+    ADD COLUMN delivery_latitude  DOUBLE PRECISION,
+    ADD COLUMN delivery_longitude DOUBLE PRECISION;
+
+-- +goose Down
+ALTER TABLE quote_requests
+    DROP COLUMN IF EXISTS delivery_longitude,
+    DROP COLUMN IF EXISTS delivery_latitude,
+    DROP COLUMN IF EXISTS delivery_address,
+    DROP COLUMN IF EXISTS fulfilment;
+
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS delivery_fee,
+    DROP COLUMN IF EXISTS delivery_longitude,
+    DROP COLUMN IF EXISTS delivery_latitude,
+    DROP COLUMN IF EXISTS delivery_address,
+    DROP COLUMN IF EXISTS fulfilment;
@@ -0,0 +1,31 @@
+-- +goose Up
+-- Items may be circles, ovals and rounded rectangles. A circle stores its
+-- diameter as both width_cm and height_cm.
+ALTER TABLE order_items
+    ADD COLUMN shape            VARCHAR(20) NOT NULL DEFAULT 'rectangle'
+        CHECK (shape IN ('rectangle', 'circle', 'oval', 'rounded_rect')),
+    ADD COLUMN corner_radius_cm INTEGER     NOT NULL DEFAULT 0 CHECK (corner_radius_cm >= 0);
+
+-- The header keeps describing the first item
+ALTER TABLE orders
+    ADD COLUMN shape            VARCHAR(20) NOT NULL DEFAULT 'rectangle'
+        CHECK (shape IN ('rectangle', 'circle', 'oval', 'rounded_rect')),
+    ADD COLUMN corner_radius_cm INTEGER     NOT NULL DEFAULT 0 CHECK (corner_radius_cm >= 0);
+
+ALTER TABLE quote_requests
+    ADD COLUMN shape            VARCHAR(20) NOT NULL DEFAULT 'rectangle'
+        CHECK (shape IN ('rectangle', 'circle', 'oval', 'rounded_rect')),
+    ADD COLUMN corner_radius_cm INTEGER     NOT NULL DEFAULT 0 CHECK (corner_radius_cm >= 0);
+
+-- +goose Down
+ALTER TABLE quote_requests
+    DROP COLUMN IF EXISTS corner_radius_cm,
+    DROP COLUMN IF EXISTS shape;
+
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS corner_radius_cm,
+    DROP COLUMN IF EXISTS shape;
+
+ALTER TABLE order_items
+    DROP COLUMN IF EXISTS corner_radius_cm,
+    DROP COLUMN IF EXISTS shape;
@@ -0,0 +1,36 @@
+-- +goose Up
+-- Public order codes like AD-2026-00042. The number restarts every year;
+-- order_code_counters holds the last number issued in each year.
+CREATE TABLE order_code_counters (
+    year        INT PRIMARY KEY,
+    last_number INT NOT NULL CHECK (last_number > 0)
+);
+
+ALTER TABLE orders ADD COLUMN code VARCHAR(32);
+
+-- Existing orders are numbered in creation order with the default prefix
+WITH numbered AS (
+    SELECT id,
+           EXTRACT(YEAR FROM created_at)::INT AS year,
+           ROW_NUMBER() OVER (
+               PARTITION BY EXTRACT(YEAR FROM created_at) ORDER BY created_at, id
+           ) AS number
+    FROM orders
+)
+UPDATE orders o
+SET code = 'AD-' || n.year || '-' || LPAD(n.number::TEXT, 5, '0')
+FROM numbered n
+WHERE o.id = n.id;
+
+INSERT INTO order_code_counters (year, last_number)
+SELECT EXTRACT(YEAR FROM created_at)::INT, COUNT(*)
+FROM orders
+GROUP BY 1;
+
+ALTER TABLE orders ALTER COLUMN code SET NOT NULL;
+CREATE UNIQUE INDEX idx_orders_code ON orders (code);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_orders_code;
+ALTER TABLE orders DROP COLUMN IF EXISTS code;
+DROP TABLE IF EXISTS order_code_counters;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- overdue_notified_at is set once the customer got an apology for a missed due date
+ALTER TABLE orders ADD COLUMN overdue_notified_at TIMESTAMP;
+
+-- Serves the daily search for open orders past their due date
+CREATE INDEX idx_orders_open_due_date ON orders (due_date)
+    WHERE status IN ('new', 'confirmed', 'in_production') AND deleted_at IS NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_orders_open_due_date;
+ALTER TABLE orders DROP COLUMN IF EXISTS overdue_notified_at;
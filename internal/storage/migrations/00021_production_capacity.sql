@@ -0,0 +1,23 @@
+-- +goose Up
+-- How much the workshop can make per day. production_capacity holds the
+-- default limits (a single row), production_capacity_days overrides them for
+-- particular dates. A limit of 0 means no limit.
+CREATE TABLE production_capacity (
+    id           BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    max_orders   INTEGER        NOT NULL DEFAULT 0 CHECK (max_orders >= 0),
+    max_area_dm2 DECIMAL(10, 2) NOT NULL DEFAULT 0 CHECK (max_area_dm2 >= 0),
+    updated_by   BIGINT,
+    updated_at   TIMESTAMP      NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE production_capacity_days (
+    day          DATE PRIMARY KEY,
+    max_orders   INTEGER        NOT NULL DEFAULT 0 CHECK (max_orders >= 0),
+    max_area_dm2 DECIMAL(10, 2) NOT NULL DEFAULT 0 CHECK (max_area_dm2 >= 0),
+    updated_by   BIGINT,
+    updated_at   TIMESTAMP      NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS production_capacity_days;
+DROP TABLE IF EXISTS production_capacity;
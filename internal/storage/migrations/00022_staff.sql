@@ -0,0 +1,23 @@
+-- +goose Up
+-- Workshop staff. Craftsmen can be assigned orders and see their queue.
+CREATE TABLE staff (
+    user_id    BIGINT PRIMARY KEY,
+    name       VARCHAR(100) NOT NULL,
+    role       VARCHAR(20)  NOT NULL DEFAULT 'craftsman' CHECK (role IN ('craftsman')),
+    active     BOOLEAN      NOT NULL DEFAULT TRUE,
+    created_by BIGINT,
+    created_at TIMESTAMP    NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE orders
+    ADD COLUMN assigned_to BIGINT REFERENCES staff(user_id) ON DELETE SET NULL,
+    ADD COLUMN assigned_at TIMESTAMP;
+
+CREATE INDEX idx_orders_assigned_to ON orders (assigned_to) WHERE assigned_to IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_orders_assigned_to;
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS assigned_at,
+    DROP COLUMN IF EXISTS assigned_to;
+DROP TABLE IF EXISTS staff;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- A rush order is due sooner than the workshop normally needs and costs a
+-- surcharge, kept separately like the quantity discount.
+ALTER TABLE orders
+    ADD COLUMN rush           BOOLEAN        NOT NULL DEFAULT FALSE,
+    ADD COLUMN rush_surcharge DECIMAL(10, 2) NOT NULL DEFAULT 0;
+ALTER TABLE order_items ADD COLUMN rush_surcharge DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE order_items DROP COLUMN IF EXISTS rush_surcharge;
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS rush_surcharge,
+    DROP COLUMN IF EXISTS rush;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- Admin-defined price formulas. A formula computes the marked-up price of an
+-- item for one service (a catalogue texture name); at most one formula per
+-- service is active, the others are kept as drafts and history.
+CREATE TABLE price_formulas (
+    id           BIGSERIAL PRIMARY KEY,
+    service_type VARCHAR(100) NOT NULL,
+    formula      TEXT         NOT NULL,
+    parameters   JSONB        NOT NULL DEFAULT '{}',
+    active       BOOLEAN      NOT NULL DEFAULT FALSE,
+    created_by   BIGINT,
+    created_at   TIMESTAMP    NOT NULL DEFAULT NOW(),
+    activated_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_price_formulas_active_service ON price_formulas (service_type) WHERE active;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_price_formulas_active_service;
+DROP TABLE IF EXISTS price_formulas;
@@ -0,0 +1,37 @@
+-- +goose Up
+-- Promo codes give a percentage or a fixed amount off an order. A code is
+-- redeemed by the order it is applied to, so the usage limits count the
+-- orders that are not cancelled.
+CREATE TABLE promo_codes (
+    code              VARCHAR(32)    PRIMARY KEY,
+    kind              VARCHAR(10)    NOT NULL CHECK (kind IN ('percent', 'fixed')),
+    -- value is a percentage for 'percent' codes and roubles for 'fixed' ones
+    value             DECIMAL(10, 2) NOT NULL CHECK (value > 0),
+    valid_from        DATE,
+    valid_until       DATE,
+    -- 0 means no limit
+    max_uses          INTEGER        NOT NULL DEFAULT 0 CHECK (max_uses >= 0),
+    max_uses_per_user INTEGER        NOT NULL DEFAULT 1 CHECK (max_uses_per_user >= 0),
+    -- Names of the textures the code applies to; empty for all of them
+    textures          TEXT[]         NOT NULL DEFAULT '{}',
+    active            BOOLEAN        NOT NULL DEFAULT TRUE,
+    created_by        BIGINT,
+    created_at        TIMESTAMP      NOT NULL DEFAULT NOW(),
+    CHECK (kind <> 'percent' OR value <= 100),
+    CHECK (valid_from IS NULL OR valid_until IS NULL OR valid_from <= valid_until)
+);
+
+ALTER TABLE orders
+    ADD COLUMN promo_code     VARCHAR(32) REFERENCES promo_codes (code),
+    ADD COLUMN promo_discount DECIMAL(10, 2) NOT NULL DEFAULT 0;
+ALTER TABLE order_items ADD COLUMN promo_discount DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_orders_promo_code ON orders (promo_code) WHERE promo_code IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_orders_promo_code;
+ALTER TABLE order_items DROP COLUMN IF EXISTS promo_discount;
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS promo_discount,
+    DROP COLUMN IF EXISTS promo_code;
+DROP TABLE IF EXISTS promo_codes;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- Returning customers get a loyalty discount by their delivered orders. The
+-- rate reached when the order was placed is kept with it, so editing the
+-- order later does not depend on the customer's history since.
+ALTER TABLE orders
+    ADD COLUMN loyalty_rate     DECIMAL(4, 3)  NOT NULL DEFAULT 0,
+    ADD COLUMN loyalty_discount DECIMAL(10, 2) NOT NULL DEFAULT 0;
+ALTER TABLE order_items ADD COLUMN loyalty_discount DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE order_items DROP COLUMN IF EXISTS loyalty_discount;
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS loyalty_discount,
+    DROP COLUMN IF EXISTS loyalty_rate;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- The minimum order price and rounding apply to the order as a whole, so the
+-- amount they add (or take off when rounding down) is kept on the order.
+ALTER TABLE orders ADD COLUMN price_adjustment DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE orders DROP COLUMN IF EXISTS price_adjustment;
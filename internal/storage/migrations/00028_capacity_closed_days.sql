@@ -0,0 +1,7 @@
+-- +goose Up
+-- A closed date takes no orders at all, e.g. a holiday or a day the
+-- equipment is down. Limits of 0 keep meaning "no limit".
+ALTER TABLE production_capacity_days ADD COLUMN closed BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE production_capacity_days DROP COLUMN IF EXISTS closed;
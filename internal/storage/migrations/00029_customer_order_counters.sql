@@ -0,0 +1,21 @@
+-- +goose Up
+-- Loyalty discounts follow the delivered orders of a customer. The counters
+-- are kept on the customer and grow when an order is delivered, instead of
+-- being recounted from the orders on every checkout.
+ALTER TABLE users
+    ADD COLUMN delivered_orders INTEGER        NOT NULL DEFAULT 0,
+    ADD COLUMN delivered_spent  DECIMAL(12, 2) NOT NULL DEFAULT 0;
+
+INSERT INTO users (user_id, delivered_orders, delivered_spent)
+SELECT user_id, COUNT(*), SUM(price)
+FROM orders
+WHERE status = 'delivered' AND deleted_at IS NULL
+GROUP BY user_id
+ON CONFLICT (user_id) DO UPDATE
+SET delivered_orders = EXCLUDED.delivered_orders,
+    delivered_spent  = EXCLUDED.delivered_spent;
+
+-- +goose Down
+ALTER TABLE users
+    DROP COLUMN IF EXISTS delivered_spent,
+    DROP COLUMN IF EXISTS delivered_orders;
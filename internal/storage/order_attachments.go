@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Attachment kinds, matching how the file has to be sent back to Telegram
+const (
+	AttachmentPhoto    = "photo"
+	AttachmentDocument = "document"
+)
+
+// OrderAttachment is a photo or file a customer sent for an order
+type OrderAttachment struct {
+	ID         int64     `db:"id"`
+	OrderID    int64     `db:"order_id"`
+	Kind       string    `db:"kind"`
+	FileID     string    `db:"file_id"`
+	FileName   string    `db:"file_name"`
+	Caption    string    `db:"caption"`
+	UploadedBy int64     `db:"uploaded_by"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+const insertOrderAttachmentQuery = `
+	INSERT INTO order_attachments (order_id, kind, file_id, file_name, caption, uploaded_by)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id
+`
+
+func (s *PostgresStorage) SaveOrderAttachment(ctx context.Context, attachment OrderAttachment) (int64, error) {
+	var attachmentID int64
+	err := s.db.QueryRowContext(ctx, insertOrderAttachmentQuery,
+		attachment.OrderID,
+		attachment.Kind,
+		attachment.FileID,
+		attachment.FileName,
+		attachment.Caption,
+		attachment.UploadedBy,
+	).Scan(&attachmentID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save order attachment: %w", err)
+	}
+	return attachmentID, nil
+}
+
+func insertOrderAttachments(ctx context.Context, tx *sqlx.Tx, orderID int64, attachments []OrderAttachment) error {
+	for i, attachment := range attachments {
+		var attachmentID int64
+		if err := tx.QueryRowContext(ctx, insertOrderAttachmentQuery,
+			orderID,
+			attachment.Kind,
+			attachment.FileID,
+			attachment.FileName,
+			attachment.Caption,
+			attachment.UploadedBy,
+		).Scan(&attachmentID); err != nil {
+			return fmt.Errorf("failed to save order attachment %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (s *PostgresStorage) GetOrderAttachments(ctx context.Context, orderID int64) ([]OrderAttachment, error) {
+	const query = `
+		SELECT id, order_id, kind, file_id, file_name, caption, uploaded_by, created_at
+		FROM order_attachments
+		WHERE order_id = $1
+		ORDER BY created_at, id
+	`
+
+	var attachments []OrderAttachment
+	if err := s.db.SelectContext(ctx, &attachments, query, orderID); err != nil {
+		return nil, fmt.Errorf("failed to get order attachments: %w", err)
+	}
+	return attachments, nil
+}
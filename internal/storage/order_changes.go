@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var ErrOrderNotEditable = errors.New("order can no longer be edited")
+
+// OrderChange is one entry of the order edit log
+type OrderChange struct {
+	ID        int64     `db:"id"`
+	OrderID   int64     `db:"order_id"`
+	ChangedBy int64     `db:"changed_by"`
+	Field     string    `db:"field"`
+	OldValue  string    `db:"old_value"`
+	NewValue  string    `db:"new_value"`
+	OldPrice  float64   `db:"old_price"`
+	NewPrice  float64   `db:"new_price"`
+	ChangedAt time.Time `db:"changed_at"`
+}
+
+// UpdateOrderDetails saves the due date, items and price totals of an order
+// and logs the change. The update only applies while the order is in one of editableStatuses.
+func (s *PostgresStorage) UpdateOrderDetails(ctx context.Context, order Order, editableStatuses []string, change OrderChange) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// A new due date must have room for the order, like a new order does
+	var oldDueDate *time.Time
+	err = tx.GetContext(ctx, &oldDueDate, `SELECT due_date FROM orders WHERE id = $1 FOR UPDATE`, order.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrOrderNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get order due date: %w", err)
+	}
+	dueDateMoved := order.DueDate != nil && (oldDueDate == nil || !dateOf(*oldDueDate).Equal(dateOf(*order.DueDate)))
+	if dueDateMoved {
+		if err := lockDueDate(ctx, tx, *order.DueDate); err != nil {
+			return err
+		}
+	}
+
+	const query = `
+		UPDATE orders SET
+			width_cm = $1, height_cm = $2, texture_id = $3, due_date = $4,
+			price = $5, leather_cost = $6, process_cost = $7, total_cost = $8,
+			commission = $9, tax = $10, net_revenue = $11, profit = $12,
+			discount = $13, delivery_fee = $14, shape = $15, corner_radius_cm = $16,
+			rush_surcharge = $17, promo_discount = $18, loyalty_discount = $19,
+			price_adjustment = $20, rush = $21, updated_at = NOW()
+		WHERE id = $22 AND status = ANY($23)
+	`
+
+	res, err := tx.ExecContext(ctx, query,
+		order.WidthCM,
+		order.HeightCM,
+		order.TextureID,
+		order.DueDate,
+		order.Price,
+		order.LeatherCost,
+		order.ProcessCost,
+		order.TotalCost,
+		order.Commission,
+		order.Tax,
+		order.NetRevenue,
+		order.Profit,
+		order.Discount,
+		order.DeliveryFee,
+		itemShape(order.Shape),
+		order.CornerRadiusCM,
+		order.RushSurcharge,
+		order.PromoDiscount,
+		order.LoyaltyDiscount,
+		order.PriceAdjustment,
+		order.Rush,
+		order.ID,
+		pq.Array(editableStatuses),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update order: %w", err)
+	}
+
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check updated order: %w", err)
+	}
+	if updated == 0 {
+		return ErrOrderNotEditable
+	}
+
+	if err := updateOrderItems(ctx, tx, order.Items); err != nil {
+		return err
+	}
+
+	if dueDateMoved {
+		if err := bookDueDate(ctx, tx, *order.DueDate); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO order_changes (order_id, changed_by, field, old_value, new_value, old_price, new_price)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		order.ID, change.ChangedBy, change.Field, change.OldValue, change.NewValue, change.OldPrice, change.NewPrice,
+	); err != nil {
+		return fmt.Errorf("failed to log order change: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit order change: %w", err)
+	}
+
+	// Invalidate statistics cache
+	s.redis.Del(ctx, "order_stats")
+
+	return nil
+}
+
+func (s *PostgresStorage) GetOrderChanges(ctx context.Context, orderID int64) ([]OrderChange, error) {
+	const query = `
+		SELECT id, order_id, changed_by, field, old_value, new_value, old_price, new_price, changed_at
+		FROM order_changes
+		WHERE order_id = $1
+		ORDER BY changed_at, id
+	`
+
+	var changes []OrderChange
+	if err := s.db.SelectContext(ctx, &changes, query, orderID); err != nil {
+		return nil, fmt.Errorf("failed to get order changes: %w", err)
+	}
+	return changes, nil
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// OrderCodeFormat builds the public order codes, e.g. AD-2026-00042:
+// a prefix, the year and the order's number within that year
+type OrderCodeFormat struct {
+	Prefix string
+	Digits int
+}
+
+func (f OrderCodeFormat) Code(year, number int) string {
+	return fmt.Sprintf("%s-%d-%0*d", f.Prefix, year, f.Digits, number)
+}
+
+// Number is how the order is referred to in messages: its public code, or
+// the ID for an order that has not been saved yet
+func (o Order) Number() string {
+	if o.Code != "" {
+		return o.Code
+	}
+	return fmt.Sprintf("#%d", o.ID)
+}
+
+// ParseOrderRef splits a reference typed by a person into an order ID
+// ("42", "#42") or a public code ("ad-2026-00042" → "AD-2026-00042")
+func ParseOrderRef(ref string) (id int64, code string) {
+	ref = strings.TrimSpace(ref)
+	if id, err := strconv.ParseInt(strings.TrimPrefix(ref, "#"), 10, 64); err == nil && id > 0 {
+		return id, ""
+	}
+	return 0, strings.ToUpper(ref)
+}
+
+// nextOrderCode issues the next code of the year the order is created in
+func (s *PostgresStorage) nextOrderCode(ctx context.Context, tx *sqlx.Tx, createdAt time.Time) (string, error) {
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	year := createdAt.Year()
+
+	var number int
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO order_code_counters (year, last_number) VALUES ($1, 1)
+		ON CONFLICT (year) DO UPDATE SET last_number = order_code_counters.last_number + 1
+		RETURNING last_number`,
+		year,
+	).Scan(&number)
+	if err != nil {
+		return "", fmt.Errorf("failed to issue order code: %w", err)
+	}
+	return s.codes.Code(year, number), nil
+}
+
+func (s *PostgresStorage) GetOrderByCode(ctx context.Context, code string) (*Order, error) {
+	var id int64
+	err := s.db.GetContext(ctx, &id, `SELECT id FROM orders WHERE code = $1`, strings.ToUpper(code))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("failed to get order by code: %w", err)
+	}
+	return s.GetOrderByID(ctx, id)
+}
+
+// GetOrderByRef finds an order by its public code or its ID
+func (s *PostgresStorage) GetOrderByRef(ctx context.Context, ref string) (*Order, error) {
+	id, code := ParseOrderRef(ref)
+	if id > 0 {
+		return s.GetOrderByID(ctx, id)
+	}
+	if code == "" {
+		return nil, ErrOrderNotFound
+	}
+	return s.GetOrderByCode(ctx, code)
+}
@@ -0,0 +1,35 @@
+package storage
+
+import "testing"
+
+func TestOrderCodeFormat(t *testing.T) {
+	format := OrderCodeFormat{Prefix: "AD", Digits: 5}
+	if got := format.Code(2026, 42); got != "AD-2026-00042" {
+		t.Errorf("Code(2026, 42) = %q, want AD-2026-00042", got)
+	}
+	if got := format.Code(2026, 123456); got != "AD-2026-123456" {
+		t.Errorf("Code(2026, 123456) = %q, want AD-2026-123456", got)
+	}
+}
+
+func TestParseOrderRef(t *testing.T) {
+	tests := []struct {
+		ref  string
+		id   int64
+		code string
+	}{
+		{"42", 42, ""},
+		{"#42", 42, ""},
+		{" 7 ", 7, ""},
+		{"AD-2026-00042", 0, "AD-2026-00042"},
+		{"ad-2026-00042", 0, "AD-2026-00042"},
+		{"0", 0, "0"},
+		{"", 0, ""},
+	}
+	for _, tt := range tests {
+		id, code := ParseOrderRef(tt.ref)
+		if id != tt.id || code != tt.code {
+			t.Errorf("ParseOrderRef(%q) = %d, %q; want %d, %q", tt.ref, id, code, tt.id, tt.code)
+		}
+	}
+}
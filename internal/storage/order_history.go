@@ -0,0 +1,18 @@
+package storage
+
+import "context"
+
+// OrdersPage is one page of orders, newest first
+type OrdersPage struct {
+	Orders   []Order
+	HasNewer bool
+	HasOlder bool
+}
+
+// GetUserOrdersPage returns up to limit orders of the user using keyset
+// pagination on the order ID. With beforeID set it returns the orders older
+// than that one, with afterID set the orders newer than that one; with
+// neither it returns the newest orders.
+func (s *PostgresStorage) GetUserOrdersPage(ctx context.Context, userID, beforeID, afterID int64, limit int) (*OrdersPage, error) {
+	return s.FindOrders(ctx, OrderFilter{UserID: userID}, beforeID, afterID, limit)
+}
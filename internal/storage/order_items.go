@@ -0,0 +1,269 @@
+package storage
+
+import (
+	"adtime-bot/pkg/money"
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// OrderItem is one position of an order: a piece of a texture cut to size
+type OrderItem struct {
+	ID          int64  `db:"id"`
+	OrderID     int64  `db:"order_id"`
+	Position    int    `db:"position"`
+	TextureID   string `db:"texture_id"`
+	TextureName string `db:"texture_name"`
+	WidthCM     int    `db:"width_cm"`
+	HeightCM    int    `db:"height_cm"`
+	Shape       string `db:"shape"`
+	// CornerRadiusCM is set for rounded rectangles only
+	CornerRadiusCM  int     `db:"corner_radius_cm"`
+	Quantity        int     `db:"quantity"`
+	Price           float64 `db:"price"`
+	Discount        float64 `db:"discount"`
+	LeatherCost     float64 `db:"leather_cost"`
+	ProcessCost     float64 `db:"process_cost"`
+	TotalCost       float64 `db:"total_cost"`
+	Commission      float64 `db:"commission"`
+	Tax             float64 `db:"tax"`
+	NetRevenue      float64 `db:"net_revenue"`
+	Profit          float64 `db:"profit"`
+	RushSurcharge   float64 `db:"rush_surcharge"`
+	PromoDiscount   float64 `db:"promo_discount"`
+	LoyaltyDiscount float64 `db:"loyalty_discount"`
+}
+
+// UpdateTotals recomputes the order's price columns from its items; Price
+// also includes the price adjustment and the delivery fee. The header's dimensions and texture keep
+// describing the first item. Sums are taken in kopecks so that the totals
+// stay exact.
+func (o *Order) UpdateTotals() {
+	if len(o.Items) == 0 {
+		return
+	}
+
+	o.Price, o.Discount, o.RushSurcharge, o.LeatherCost, o.ProcessCost, o.TotalCost = 0, 0, 0, 0, 0, 0
+	o.Commission, o.Tax, o.NetRevenue, o.Profit, o.PromoDiscount = 0, 0, 0, 0, 0
+	o.LoyaltyDiscount = 0
+	for _, item := range o.Items {
+		o.Price = addRubles(o.Price, item.Price)
+		o.Discount = addRubles(o.Discount, item.Discount)
+		o.RushSurcharge = addRubles(o.RushSurcharge, item.RushSurcharge)
+		o.PromoDiscount = addRubles(o.PromoDiscount, item.PromoDiscount)
+		o.LoyaltyDiscount = addRubles(o.LoyaltyDiscount, item.LoyaltyDiscount)
+		o.LeatherCost = addRubles(o.LeatherCost, item.LeatherCost)
+		o.ProcessCost = addRubles(o.ProcessCost, item.ProcessCost)
+		o.TotalCost = addRubles(o.TotalCost, item.TotalCost)
+		o.Commission = addRubles(o.Commission, item.Commission)
+		o.Tax = addRubles(o.Tax, item.Tax)
+		o.NetRevenue = addRubles(o.NetRevenue, item.NetRevenue)
+		o.Profit = addRubles(o.Profit, item.Profit)
+	}
+	o.Price = addRubles(addRubles(o.Price, o.PriceAdjustment), o.DeliveryFee)
+
+	first := o.Items[0]
+	o.WidthCM = first.WidthCM
+	o.HeightCM = first.HeightCM
+	o.Shape = first.Shape
+	o.CornerRadiusCM = first.CornerRadiusCM
+	o.TextureID = first.TextureID
+	o.TextureName = first.TextureName
+}
+
+// ItemsTotal is the price of the items without the price adjustment and delivery
+func (o *Order) ItemsTotal() float64 {
+	if len(o.Items) == 0 {
+		return addRubles(addRubles(o.Price, -o.PriceAdjustment), -o.DeliveryFee)
+	}
+
+	total := 0.0
+	for _, item := range o.Items {
+		total = addRubles(total, item.Price)
+	}
+	return total
+}
+
+// RegularItemsTotal is what the items cost without the rush surcharge and
+// the promo discount, which belong to one order and are not carried over
+// when it is repeated
+func (o *Order) RegularItemsTotal() float64 {
+	total := 0.0
+	for _, item := range o.Items {
+		total = addRubles(total, addRubles(item.Price, item.PromoDiscount-item.RushSurcharge))
+	}
+	return total
+}
+
+// addRubles adds two DECIMAL amounts exactly, rounding each to the kopeck
+func addRubles(a, b float64) float64 {
+	return (money.FromRubles(a) + money.FromRubles(b)).Rubles()
+}
+
+// TotalQuantity is the number of pieces across all items
+func (o *Order) TotalQuantity() int {
+	total := 0
+	for _, item := range o.Items {
+		total += item.Quantity
+	}
+	return total
+}
+
+// itemsFromHeader treats a header-only order as a single-item order
+func itemsFromHeader(order Order) []OrderItem {
+	return []OrderItem{{
+		TextureID:       order.TextureID,
+		TextureName:     order.TextureName,
+		WidthCM:         order.WidthCM,
+		HeightCM:        order.HeightCM,
+		Shape:           order.Shape,
+		CornerRadiusCM:  order.CornerRadiusCM,
+		Quantity:        1,
+		Price:           order.Price,
+		Discount:        order.Discount,
+		LeatherCost:     order.LeatherCost,
+		ProcessCost:     order.ProcessCost,
+		TotalCost:       order.TotalCost,
+		Commission:      order.Commission,
+		Tax:             order.Tax,
+		NetRevenue:      order.NetRevenue,
+		Profit:          order.Profit,
+		RushSurcharge:   order.RushSurcharge,
+		PromoDiscount:   order.PromoDiscount,
+		LoyaltyDiscount: order.LoyaltyDiscount,
+	}}
+}
+
+func insertOrderItems(ctx context.Context, tx *sqlx.Tx, orderID int64, items []OrderItem) error {
+	const query = `
+		INSERT INTO order_items (
+			order_id, position, texture_id, width_cm, height_cm, quantity, price,
+			leather_cost, process_cost, total_cost, commission, tax, net_revenue, profit, discount,
+			shape, corner_radius_cm, rush_surcharge, promo_discount, loyalty_discount
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
+	`
+
+	for i, item := range items {
+		if _, err := tx.ExecContext(ctx, query,
+			orderID,
+			i+1,
+			item.TextureID,
+			item.WidthCM,
+			item.HeightCM,
+			item.Quantity,
+			item.Price,
+			item.LeatherCost,
+			item.ProcessCost,
+			item.TotalCost,
+			item.Commission,
+			item.Tax,
+			item.NetRevenue,
+			item.Profit,
+			item.Discount,
+			itemShape(item.Shape),
+			item.CornerRadiusCM,
+			item.RushSurcharge,
+			item.PromoDiscount,
+			item.LoyaltyDiscount,
+		); err != nil {
+			return fmt.Errorf("failed to save order item %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func updateOrderItems(ctx context.Context, tx *sqlx.Tx, items []OrderItem) error {
+	const query = `
+		UPDATE order_items SET
+			texture_id = $1, width_cm = $2, height_cm = $3, quantity = $4, price = $5,
+			leather_cost = $6, process_cost = $7, total_cost = $8, commission = $9,
+			tax = $10, net_revenue = $11, profit = $12, discount = $13,
+			shape = $14, corner_radius_cm = $15, rush_surcharge = $16, promo_discount = $17,
+			loyalty_discount = $18
+		WHERE id = $19
+	`
+
+	for _, item := range items {
+		if _, err := tx.ExecContext(ctx, query,
+			item.TextureID,
+			item.WidthCM,
+			item.HeightCM,
+			item.Quantity,
+			item.Price,
+			item.LeatherCost,
+			item.ProcessCost,
+			item.TotalCost,
+			item.Commission,
+			item.Tax,
+			item.NetRevenue,
+			item.Profit,
+			item.Discount,
+			itemShape(item.Shape),
+			item.CornerRadiusCM,
+			item.RushSurcharge,
+			item.PromoDiscount,
+			item.LoyaltyDiscount,
+			item.ID,
+		); err != nil {
+			return fmt.Errorf("failed to update order item %d: %w", item.ID, err)
+		}
+	}
+	return nil
+}
+
+// itemShape stores items built without a shape as rectangles
+func itemShape(shape string) string {
+	if shape == "" {
+		return ShapeRectangle
+	}
+	return shape
+}
+
+const orderItemsQuery = `
+	SELECT i.id, i.order_id, i.position, i.texture_id::text, COALESCE(t.name, '') AS texture_name,
+		i.width_cm, i.height_cm, i.shape, i.corner_radius_cm, i.quantity, i.price, i.leather_cost, i.process_cost,
+		i.total_cost, i.commission, i.tax, i.net_revenue, i.profit, i.discount,
+		i.rush_surcharge, i.promo_discount, i.loyalty_discount
+	FROM order_items i
+	LEFT JOIN textures t ON t.id = i.texture_id
+`
+
+func (s *PostgresStorage) GetOrderItems(ctx context.Context, orderID int64) ([]OrderItem, error) {
+	var items []OrderItem
+	err := s.db.SelectContext(ctx, &items, orderItemsQuery+`WHERE i.order_id = $1 ORDER BY i.position`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order items: %w", err)
+	}
+	return items, nil
+}
+
+// loadOrderItems fills Items of every order with a single query
+func (s *PostgresStorage) loadOrderItems(ctx context.Context, orders []Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(orders))
+	for i, order := range orders {
+		ids[i] = order.ID
+	}
+
+	var items []OrderItem
+	err := s.db.SelectContext(ctx, &items,
+		orderItemsQuery+`WHERE i.order_id = ANY($1) ORDER BY i.order_id, i.position`,
+		pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to get order items: %w", err)
+	}
+
+	byOrder := make(map[int64][]OrderItem, len(orders))
+	for _, item := range items {
+		byOrder[item.OrderID] = append(byOrder[item.OrderID], item)
+	}
+	for i := range orders {
+		orders[i].Items = byOrder[orders[i].ID]
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package storage
+
+import "testing"
+
+func TestUpdateTotals_ExactSums(t *testing.T) {
+	order := Order{
+		DeliveryFee: 0.2,
+		Items: []OrderItem{
+			{Price: 0.1, Tax: 0.1},
+			{Price: 0.2, Tax: 0.2},
+		},
+	}
+	order.UpdateTotals()
+
+	if order.Price != 0.5 {
+		t.Errorf("price %v, want 0.5", order.Price)
+	}
+	if order.Tax != 0.3 {
+		t.Errorf("tax %v, want 0.3", order.Tax)
+	}
+	if total := order.ItemsTotal(); total != 0.3 {
+		t.Errorf("items total %v, want 0.3", total)
+	}
+}
+
+func TestRegularItemsTotal(t *testing.T) {
+	order := Order{
+		Price:       1650.3,
+		DeliveryFee: 300,
+		Items: []OrderItem{
+			{Price: 1100.2, RushSurcharge: 200.1},
+			{Price: 250.1, PromoDiscount: 49.9},
+		},
+	}
+
+	if total := order.RegularItemsTotal(); total != 1200.1 {
+		t.Errorf("regular items total %v, want 1200.1", total)
+	}
+}
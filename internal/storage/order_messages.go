@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// OrderMessage is one message of the conversation about an order
+type OrderMessage struct {
+	ID        int64     `db:"id"`
+	OrderID   int64     `db:"order_id"`
+	SenderID  int64     `db:"sender_id"`
+	FromAdmin bool      `db:"from_admin"`
+	Text      string    `db:"text"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func (s *PostgresStorage) SaveOrderMessage(ctx context.Context, message OrderMessage) (int64, error) {
+	const query = `
+		INSERT INTO order_messages (order_id, sender_id, from_admin, text)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`
+
+	var messageID int64
+	err := s.db.QueryRowContext(ctx, query,
+		message.OrderID,
+		message.SenderID,
+		message.FromAdmin,
+		message.Text,
+	).Scan(&messageID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save order message: %w", err)
+	}
+	return messageID, nil
+}
+
+// GetOrderMessages returns the conversation about an order, oldest first
+func (s *PostgresStorage) GetOrderMessages(ctx context.Context, orderID int64) ([]OrderMessage, error) {
+	const query = `
+		SELECT id, order_id, sender_id, from_admin, text, created_at
+		FROM order_messages
+		WHERE order_id = $1
+		ORDER BY created_at, id
+	`
+
+	var messages []OrderMessage
+	if err := s.db.SelectContext(ctx, &messages, query, orderID); err != nil {
+		return nil, fmt.Errorf("failed to get order messages: %w", err)
+	}
+	return messages, nil
+}
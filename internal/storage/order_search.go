@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// OrderFilter narrows the orders returned by FindOrders. Zero fields are ignored.
+type OrderFilter struct {
+	Phone       string     `json:"phone,omitempty"`
+	UserID      int64      `json:"user_id,omitempty"`
+	Texture     string     `json:"texture,omitempty"`
+	Status      string     `json:"status,omitempty"`
+	CreatedFrom *time.Time `json:"created_from,omitempty"`
+	// CreatedTo is inclusive: orders made during that whole day match
+	CreatedTo *time.Time `json:"created_to,omitempty"`
+}
+
+// where builds the conditions of the filter with their arguments
+func (f OrderFilter) where() (string, []interface{}) {
+	conditions := []string{"deleted_at IS NULL"}
+	var args []interface{}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if f.Phone != "" {
+		add("contact = $%d", f.Phone)
+	}
+	if f.UserID != 0 {
+		add("user_id = $%d", f.UserID)
+	}
+	if f.Texture != "" {
+		add(`EXISTS (
+			SELECT 1 FROM order_items i
+			JOIN textures t ON t.id = i.texture_id
+			WHERE i.order_id = orders.id AND t.name ILIKE '%%' || $%d || '%%')`, f.Texture)
+	}
+	if f.Status != "" {
+		add("status = $%d", f.Status)
+	}
+	if f.CreatedFrom != nil {
+		add("created_at >= $%d", *f.CreatedFrom)
+	}
+	if f.CreatedTo != nil {
+		add("created_at < $%d", f.CreatedTo.AddDate(0, 0, 1))
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// FindOrders returns up to limit orders matching the filter, rush orders
+// first and newest first within them. Pages are keyed by the order ID like
+// GetUserOrdersPage; the cursor's rush flag is looked up so paging follows
+// the same ordering.
+func (s *PostgresStorage) FindOrders(ctx context.Context, filter OrderFilter, beforeID, afterID int64, limit int) (*OrdersPage, error) {
+	where, args := filter.where()
+
+	order := "rush DESC, id DESC"
+	switch {
+	case afterID > 0:
+		args = append(args, afterID)
+		where += fmt.Sprintf(" AND (rush, id) > (SELECT rush, id FROM orders WHERE id = $%d)", len(args))
+		order = "rush, id"
+	case beforeID > 0:
+		args = append(args, beforeID)
+		where += fmt.Sprintf(" AND (rush, id) < (SELECT rush, id FROM orders WHERE id = $%d)", len(args))
+	}
+	args = append(args, limit+1)
+
+	query := fmt.Sprintf(`SELECT * FROM orders WHERE %s ORDER BY %s LIMIT $%d`, where, order, len(args))
+
+	var orders []Order
+	if err := s.db.SelectContext(ctx, &orders, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to find orders: %w", err)
+	}
+
+	page := &OrdersPage{}
+	more := len(orders) > limit
+	if more {
+		orders = orders[:limit]
+	}
+	if afterID > 0 {
+		// Fetched oldest first to find the neighbours of the cursor
+		slices.Reverse(orders)
+		page.HasNewer = more
+		page.HasOlder = true
+	} else {
+		page.HasOlder = more
+		page.HasNewer = beforeID > 0
+	}
+
+	if err := s.loadOrderItems(ctx, orders); err != nil {
+		return nil, err
+	}
+	page.Orders = orders
+	return page, nil
+}
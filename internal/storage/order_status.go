@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Order lifecycle statuses
+const (
+	StatusNew          = "new"
+	StatusConfirmed    = "confirmed"
+	StatusInProduction = "in_production"
+	StatusReady        = "ready"
+	StatusDelivered    = "delivered"
+	StatusCancelled    = "cancelled"
+)
+
+// OrderStatuses lists every status in lifecycle order
+var OrderStatuses = []string{
+	StatusNew,
+	StatusConfirmed,
+	StatusInProduction,
+	StatusReady,
+	StatusDelivered,
+	StatusCancelled,
+}
+
+var (
+	ErrInvalidStatusTransition = errors.New("invalid status transition")
+	ErrOrderNotCancellable     = errors.New("order can no longer be cancelled by customer")
+)
+
+// customerCancellableStatuses are the statuses before production starts
+var customerCancellableStatuses = []string{StatusNew, StatusConfirmed}
+
+// Customers may change an order only until it is confirmed,
+// admins until it is handed over
+var (
+	CustomerEditableStatuses = []string{StatusNew}
+	AdminEditableStatuses    = []string{StatusNew, StatusConfirmed, StatusInProduction, StatusReady}
+)
+
+// statusTransitions lists the statuses an order may move to from each status.
+// Delivered and cancelled orders are final.
+var statusTransitions = map[string][]string{
+	StatusNew:          {StatusConfirmed, StatusCancelled},
+	StatusConfirmed:    {StatusInProduction, StatusCancelled},
+	StatusInProduction: {StatusReady, StatusCancelled},
+	StatusReady:        {StatusDelivered, StatusCancelled},
+	StatusDelivered:    {},
+	StatusCancelled:    {},
+}
+
+type OrderStatusChange struct {
+	ID         int64     `db:"id"`
+	OrderID    int64     `db:"order_id"`
+	FromStatus string    `db:"from_status"`
+	ToStatus   string    `db:"to_status"`
+	ChangedBy  int64     `db:"changed_by"`
+	Comment    string    `db:"comment"`
+	ChangedAt  time.Time `db:"changed_at"`
+}
+
+func IsValidStatus(status string) bool {
+	_, ok := statusTransitions[status]
+	return ok
+}
+
+// AllowedStatusTransitions returns the statuses reachable from the given one
+func AllowedStatusTransitions(from string) []string {
+	return statusTransitions[from]
+}
+
+// CanCustomerCancel reports whether the customer may still cancel an order in this status
+func CanCustomerCancel(status string) bool {
+	return slices.Contains(customerCancellableStatuses, status)
+}
+
+func ValidateStatusTransition(from, to string) error {
+	if !IsValidStatus(to) {
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidStatusTransition, to)
+	}
+	if !slices.Contains(statusTransitions[from], to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidStatusTransition, from, to)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) GetOrderStatusHistory(ctx context.Context, orderID int64) ([]OrderStatusChange, error) {
+	const query = `
+		SELECT id, order_id, COALESCE(from_status, '') AS from_status, to_status, changed_by,
+			COALESCE(comment, '') AS comment, changed_at
+		FROM order_status_history
+		WHERE order_id = $1
+		ORDER BY changed_at, id
+	`
+
+	var history []OrderStatusChange
+	if err := s.db.SelectContext(ctx, &history, query, orderID); err != nil {
+		return nil, fmt.Errorf("failed to get status history: %w", err)
+	}
+	return history, nil
+}
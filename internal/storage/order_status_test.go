@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateStatusTransition(t *testing.T) {
+	allowed := [][2]string{
+		{StatusNew, StatusConfirmed},
+		{StatusConfirmed, StatusInProduction},
+		{StatusInProduction, StatusReady},
+		{StatusReady, StatusDelivered},
+		{StatusNew, StatusCancelled},
+		{StatusReady, StatusCancelled},
+	}
+	for _, tr := range allowed {
+		if err := ValidateStatusTransition(tr[0], tr[1]); err != nil {
+			t.Errorf("%s -> %s should be allowed, got %v", tr[0], tr[1], err)
+		}
+	}
+
+	rejected := [][2]string{
+		{StatusNew, StatusDelivered},
+		{StatusConfirmed, StatusNew},
+		{StatusDelivered, StatusCancelled},
+		{StatusCancelled, StatusNew},
+		{StatusNew, "processing"},
+	}
+	for _, tr := range rejected {
+		err := ValidateStatusTransition(tr[0], tr[1])
+		if !errors.Is(err, ErrInvalidStatusTransition) {
+			t.Errorf("%s -> %s should be rejected, got %v", tr[0], tr[1], err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// openStatuses are the statuses of orders the workshop has not finished yet;
+// such an order is overdue once its due date has passed
+var openStatuses = []string{StatusNew, StatusConfirmed, StatusInProduction}
+
+// IsOpen reports whether the workshop still has work to do on the order
+func (o Order) IsOpen() bool {
+	return slices.Contains(openStatuses, o.Status)
+}
+
+// IsOverdue reports whether the order is still open after its due date
+func (o Order) IsOverdue(now time.Time) bool {
+	if o.DueDate == nil || !o.IsOpen() {
+		return false
+	}
+	return DaysOverdue(*o.DueDate, now) > 0
+}
+
+// DaysOverdue is the number of whole days from the due date to now
+func DaysOverdue(dueDate, now time.Time) int {
+	due := time.Date(dueDate.Year(), dueDate.Month(), dueDate.Day(), 0, 0, 0, 0, time.UTC)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return int(today.Sub(due).Hours() / 24)
+}
+
+// GetOverdueOrders returns the open orders due before the day of now, rush
+// orders first, then the most overdue
+func (s *PostgresStorage) GetOverdueOrders(ctx context.Context, now time.Time) ([]Order, error) {
+	var orders []Order
+	err := s.db.SelectContext(ctx, &orders, `
+		SELECT * FROM orders
+		WHERE due_date < $1 AND status = ANY($2) AND deleted_at IS NULL
+		ORDER BY rush DESC, due_date, id`,
+		now.Format("2006-01-02"), pq.Array(openStatuses),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get overdue orders: %w", err)
+	}
+
+	if err := s.loadOrderItems(ctx, orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// MarkOverdueNotified records the apology to the customer. It reports false
+// when the customer has already been notified about this order.
+func (s *PostgresStorage) MarkOverdueNotified(ctx context.Context, orderID int64) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE orders SET overdue_notified_at = NOW()
+		WHERE id = $1 AND overdue_notified_at IS NULL`,
+		orderID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark overdue notification: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to mark overdue notification: %w", err)
+	}
+	return rows > 0, nil
+}
+
+// ClaimOverdueDigest reports true for the first caller on the given day, so
+// the digest goes out once a day even if the bot restarts
+func (s *PostgresStorage) ClaimOverdueDigest(ctx context.Context, day time.Time) (bool, error) {
+	key := fmt.Sprintf("overdue_digest:%s", day.Format("2006-01-02"))
+
+	count, err := s.redis.Incr(ctx, key)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim overdue digest: %w", err)
+	}
+	if count == 1 {
+		if _, err := s.redis.Expire(ctx, key, 48*time.Hour); err != nil {
+			// Release the day so that the next check can claim it again
+			s.redis.Del(ctx, key)
+			return false, fmt.Errorf("failed to set overdue digest expiry: %w", err)
+		}
+	}
+	return count == 1, nil
+}
@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOrderIsOverdue(t *testing.T) {
+	due := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2026, 3, 12, 9, 30, 0, 0, time.Local)
+
+	if got := DaysOverdue(due, now); got != 2 {
+		t.Errorf("DaysOverdue = %d, want 2", got)
+	}
+	if got := DaysOverdue(due, due.Add(23*time.Hour)); got != 0 {
+		t.Errorf("DaysOverdue on the due date = %d, want 0", got)
+	}
+
+	tests := []struct {
+		name  string
+		order Order
+		want  bool
+	}{
+		{"open and late", Order{Status: StatusInProduction, DueDate: &due}, true},
+		{"ready", Order{Status: StatusReady, DueDate: &due}, false},
+		{"cancelled", Order{Status: StatusCancelled, DueDate: &due}, false},
+		{"no due date", Order{Status: StatusNew}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.order.IsOverdue(now); got != tt.want {
+			t.Errorf("%s: IsOverdue = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	onTime := now.AddDate(0, 0, 1)
+	if (Order{Status: StatusNew, DueDate: &onTime}).IsOverdue(now) {
+		t.Error("order due tomorrow should not be overdue")
+	}
+}
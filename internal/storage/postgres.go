@@ -2,6 +2,7 @@ package storage
 
 import (
 	"adtime-bot/internal/config"
+	"adtime-bot/pkg/money"
 	"adtime-bot/pkg/redis"
 	"context"
 	"database/sql"
@@ -13,33 +14,48 @@ import (
 
 	"github.com/cenkalti/backoff/v4"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/xuri/excelize/v2"
 	"go.uber.org/zap"
 )
 
+var ErrOrderNotFound = errors.New("order not found")
+
 type PostgresStorage struct {
 	db     *sqlx.DB
 	redis  *redis.Client
 	logger *zap.Logger
+	codes  OrderCodeFormat
 }
 
 func (s *PostgresStorage) GetUserOrders(ctx context.Context, userID int64) ([]Order, error) {
     const query = `
-        SELECT id, width_cm, height_cm, price, status, created_at 
+        SELECT id, code, width_cm, height_cm, price, status, due_date, created_at 
         FROM orders 
         WHERE user_id = $1 AND deleted_at IS NULL
         ORDER BY created_at DESC`
     
     var orders []Order
-    err := s.db.SelectContext(ctx, &orders, query, userID)
-    return orders, err
+    if err := s.db.SelectContext(ctx, &orders, query, userID); err != nil {
+        return nil, err
+    }
+
+    if err := s.loadOrderItems(ctx, orders); err != nil {
+        return nil, err
+    }
+    return orders, nil
 }
 
 func (s *PostgresStorage) DeleteUserData(ctx context.Context, chatID int64) error {
 	// Soft delete с timestamp
 	_, err := s.db.ExecContext(ctx,
 		"UPDATE orders SET deleted_at = NOW() WHERE user_id = $1", chatID)
+	if err != nil {
+		return err
+	}
+	// Deleted orders no longer count towards loyalty discounts
+	_, err = s.db.ExecContext(ctx,
+		"UPDATE users SET delivered_orders = 0, delivered_spent = 0 WHERE user_id = $1", chatID)
 	return err
 }
 
@@ -53,12 +69,17 @@ type Texture struct {
 
 type Order struct {
     ID          int64     `db:"id"`
+    // Code is the public order number, e.g. AD-2026-00042
+    Code        string    `db:"code"`
     UserID      int64     `db:"user_id"`
     WidthCM     int       `db:"width_cm"`
     HeightCM    int       `db:"height_cm"`
+    Shape       string    `db:"shape"`
+    CornerRadiusCM int    `db:"corner_radius_cm"`
     TextureID   string    `db:"texture_id"`
     TextureName string    `db:"texture_name"`
     Price       float64   `db:"price"`
+    Discount    float64   `db:"discount"`
     LeatherCost float64   `db:"leather_cost"`
     ProcessCost float64   `db:"process_cost"`
     TotalCost   float64   `db:"total_cost"`
@@ -68,12 +89,41 @@ type Order struct {
     Profit      float64   `db:"profit"`
     Contact     string    `db:"contact"`
     Status      string    `db:"status"`
+    DueDate     *time.Time `db:"due_date"`
     CreatedAt   time.Time `db:"created_at"`
     UpdatedAt   time.Time `db:"updated_at"`
+    DeletedAt   *time.Time `db:"deleted_at"`
+    // DeliveryFee is part of Price; it is passed on to the courier
+    DeliveryFee float64   `db:"delivery_fee"`
+    Fulfilment
+    // OverdueNotifiedAt is when the customer was told the order is late
+    OverdueNotifiedAt *time.Time `db:"overdue_notified_at"`
+    // AssignedTo is the craftsman making the order
+    AssignedTo  *int64     `db:"assigned_to"`
+    AssignedAt  *time.Time `db:"assigned_at"`
+    // Rush orders are due sooner than usual; RushSurcharge is part of Price
+    Rush          bool    `db:"rush"`
+    RushSurcharge float64 `db:"rush_surcharge"`
+    // PromoCode is the code redeemed by the order; PromoDiscount is taken off Price
+    PromoCode     *string `db:"promo_code"`
+    PromoDiscount float64 `db:"promo_discount"`
+    // LoyaltyRate is the loyalty tier the customer had reached when ordering;
+    // LoyaltyDiscount is taken off Price
+    LoyaltyRate     float64 `db:"loyalty_rate"`
+    LoyaltyDiscount float64 `db:"loyalty_discount"`
+    // PriceAdjustment is what the minimum order price and rounding added to
+    // the items total, negative when it was rounded down; part of Price
+    PriceAdjustment float64 `db:"price_adjustment"`
+
+    // Items are stored in order_items
+    Items []OrderItem `db:"-"`
+    // Attachments are saved together with a new order; loaded on demand
+    Attachments []OrderAttachment `db:"-"`
 }
 
 type OrderStatistics struct {
 	TotalOrders  int
+	TotalItems   int
 	TotalRevenue float64
 	TodayOrders  int
 	TodayRevenue float64
@@ -82,13 +132,8 @@ type OrderStatistics struct {
 	MonthOrders  int
 	MonthRevenue float64
 	StatusCounts map[string]int
-}
-
-type PriceFormula struct {
-	ID          string
-	ServiceType string
-	Formula     string // "width*height*price*coefficient"
-	Parameters  map[string]float64
+	// OverdueOrders are open orders past their due date
+	OverdueOrders int
 }
 
 func NewPostgresStorage(ctx context.Context, cfg config.Config, redisClient *redis.Client, logger *zap.Logger) (*PostgresStorage, error) {
@@ -147,6 +192,10 @@ func NewPostgresStorage(ctx context.Context, cfg config.Config, redisClient *red
 		db:     db,
 		redis:  redisClient,
 		logger: logger,
+		codes: OrderCodeFormat{
+			Prefix: cfg.OrderCode.Prefix,
+			Digits: cfg.OrderCode.Digits,
+		},
 	}, nil
 }
 
@@ -212,18 +261,60 @@ func (s *PostgresStorage) GetAvailableTextures(ctx context.Context) ([]Texture,
 	return textures, nil
 }
 
-func (s *PostgresStorage) SaveOrder(ctx context.Context, order Order) (int64, error) {
+// SaveOrder saves a new order and sets its ID and public code
+func (s *PostgresStorage) SaveOrder(ctx context.Context, order *Order) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.insertOrder(ctx, tx, order); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit order: %w", err)
+	}
+
+	// Invalidate statistics cache
+    s.redis.Del(ctx, "order_stats")
+
+    return nil
+}
+
+// insertOrder writes the order header, its items and the first status
+// history entry, and sets the ID and code of the order
+func (s *PostgresStorage) insertOrder(ctx context.Context, tx *sqlx.Tx, order *Order) error {
 	const query = `
         INSERT INTO orders (
             user_id, width_cm, height_cm, texture_id, price,
             leather_cost, process_cost, total_cost, commission,
-            tax, net_revenue, profit, contact, status, created_at
-        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+            tax, net_revenue, profit, contact, status, created_at, due_date, discount,
+            fulfilment, delivery_address, delivery_latitude, delivery_longitude, delivery_fee,
+            shape, corner_radius_cm, code, rush, rush_surcharge, promo_code, promo_discount,
+            loyalty_rate, loyalty_discount, price_adjustment
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17,
+            $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32)
         RETURNING id
     `
 
+	if err := redeemPromoCode(ctx, tx, *order); err != nil {
+		return err
+	}
+	if order.DueDate != nil {
+		if err := lockDueDate(ctx, tx, *order.DueDate); err != nil {
+			return err
+		}
+	}
+
+	code, err := s.nextOrderCode(ctx, tx, order.CreatedAt)
+	if err != nil {
+		return err
+	}
+
 	var orderID int64
-    err := s.db.QueryRowContext(ctx, query,
+    err = tx.QueryRowContext(ctx, query,
         order.UserID,
         order.WidthCM,
         order.HeightCM,
@@ -239,17 +330,56 @@ func (s *PostgresStorage) SaveOrder(ctx context.Context, order Order) (int64, er
         order.Contact,
         order.Status,
         order.CreatedAt,
+        order.DueDate,
+        order.Discount,
+        order.Fulfilment.Method,
+        order.Fulfilment.Address,
+        order.Fulfilment.Latitude,
+        order.Fulfilment.Longitude,
+        order.DeliveryFee,
+        itemShape(order.Shape),
+        order.CornerRadiusCM,
+        code,
+        order.Rush,
+        order.RushSurcharge,
+        order.PromoCode,
+        order.PromoDiscount,
+        order.LoyaltyRate,
+        order.LoyaltyDiscount,
+        order.PriceAdjustment,
     ).Scan(&orderID)
-
-
 	if err != nil {
-        return 0, fmt.Errorf("failed to save order: %w", err)
+        return fmt.Errorf("failed to save order: %w", err)
     }
 
-	// Invalidate statistics cache
-    s.redis.Del(ctx, "order_stats")
+	items := order.Items
+	if len(items) == 0 {
+		items = itemsFromHeader(*order)
+	}
+	if err := insertOrderItems(ctx, tx, orderID, items); err != nil {
+		return err
+	}
+	if order.DueDate != nil {
+		if err := bookDueDate(ctx, tx, *order.DueDate); err != nil {
+			return err
+		}
+	}
 
-    return orderID, nil
+	if err := insertOrderAttachments(ctx, tx, orderID, order.Attachments); err != nil {
+		return err
+	}
+
+	// The creation is the first entry of the status timeline
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO order_status_history (order_id, to_status, changed_by)
+		VALUES ($1, $2, $3)`,
+		orderID, order.Status, order.UserID,
+	); err != nil {
+		return fmt.Errorf("failed to record order status: %w", err)
+	}
+
+	order.ID, order.Code = orderID, code
+	return nil
 }
 
 func (s *PostgresStorage) ExportOrderToExcel(ctx context.Context, order Order) (string, error) {
@@ -263,46 +393,71 @@ func (s *PostgresStorage) ExportOrderToExcel(ctx context.Context, order Order) (
 	}
 
 	// Set basic order info
-	f.SetCellValue("Order", "A1", "Order ID")
-	f.SetCellValue("Order", "B1", order.ID)
+	f.SetCellValue("Order", "A1", "Order")
+	f.SetCellValue("Order", "B1", order.Number())
 	f.SetCellValue("Order", "A2", "User ID")
 	f.SetCellValue("Order", "B2", order.UserID)
 	f.SetCellValue("Order", "A3", "Created At")
 	f.SetCellValue("Order", "B3", order.CreatedAt.Format("2006-01-02 15:04"))
 
-	// Set dimensions and calculations
-	area := float64(order.WidthCM*order.HeightCM) / 100
+	// Set dimensions of the first item
 	f.SetCellValue("Order", "A4", "Dimensions")
 	f.SetCellValue("Order", "B4", fmt.Sprintf("%d × %d cm", order.WidthCM, order.HeightCM))
-	f.SetCellValue("Order", "A5", "Area")
-	f.SetCellValue("Order", "B5", fmt.Sprintf("%.1f dm²", area))
+	f.SetCellValue("Order", "A5", "Shape")
+	f.SetCellValue("Order", "B5", exportShape(order.Shape, order.CornerRadiusCM))
+	f.SetCellValue("Order", "A6", "Due Date")
+	f.SetCellValue("Order", "B6", formatExportDate(order.DueDate))
 
 	// Set pricing info
 	f.SetCellValue("Order", "A7", "Price Components")
 	f.SetCellValue("Order", "A8", "Leather Cost")
-	f.SetCellValue("Order", "B8", order.LeatherCost)
+	f.SetCellValue("Order", "B8", exportMoney(order.LeatherCost))
 	f.SetCellValue("Order", "A9", "Processing Cost")
-	f.SetCellValue("Order", "B9", order.ProcessCost)
+	f.SetCellValue("Order", "B9", exportMoney(order.ProcessCost))
 	f.SetCellValue("Order", "A10", "Total Cost")
-	f.SetCellValue("Order", "B10", order.TotalCost)
+	f.SetCellValue("Order", "B10", exportMoney(order.TotalCost))
 	f.SetCellValue("Order", "A11", "Commission")
-	f.SetCellValue("Order", "B11", order.Commission)
+	f.SetCellValue("Order", "B11", exportMoney(order.Commission))
 	f.SetCellValue("Order", "A12", "Tax")
-	f.SetCellValue("Order", "B12", order.Tax)
-	f.SetCellValue("Order", "A13", "Final Price")
-	f.SetCellValue("Order", "B13", order.Price)
+	f.SetCellValue("Order", "B12", exportMoney(order.Tax))
+	f.SetCellValue("Order", "A13", "Quantity Discount")
+	f.SetCellValue("Order", "B13", exportMoney(order.Discount))
+	f.SetCellValue("Order", "A14", "Delivery Fee")
+	f.SetCellValue("Order", "B14", exportMoney(order.DeliveryFee))
+	f.SetCellValue("Order", "A15", "Final Price")
+	f.SetCellValue("Order", "B15", exportMoney(order.Price))
+
+	// Fulfilment
+	f.SetCellValue("Order", "A16", "Fulfilment")
+	f.SetCellValue("Order", "B16", order.Fulfilment.Method)
+	f.SetCellValue("Order", "A17", "Delivery Address")
+	f.SetCellValue("Order", "B17", exportDeliveryAddress(order.Fulfilment))
+
+	// Items
+	f.SetCellValue("Order", "A19", "Items")
+	for col, header := range orderItemExportHeaders[1:] {
+		cell, _ := excelize.CoordinatesToCellName(col+1, 20)
+		f.SetCellValue("Order", cell, header)
+	}
+	for row, item := range order.Items {
+		for col, value := range orderItemExportRow(order, item)[1:] {
+			cell, _ := excelize.CoordinatesToCellName(col+1, row+21)
+			f.SetCellValue("Order", cell, value)
+		}
+	}
 
 	// Formatting
 	style, _ := f.NewStyle(&excelize.Style{
 		Font: &excelize.Font{Bold: true},
 	})
-	f.SetCellStyle("Order", "A1", "A13", style)
+	f.SetCellStyle("Order", "A1", "A17", style)
+	f.SetCellStyle("Order", "A19", "R20", style)
 
 	f.SetActiveSheet(index)
 
 	// Save file
-	filename := fmt.Sprintf("order_%d_%s.xlsx",
-		order.ID,
+	filename := fmt.Sprintf("order_%s_%s.xlsx",
+		order.Number(),
 		order.CreatedAt.Format("20060102_1504"))
 	filepath := fmt.Sprintf("reports/%s", filename)
 
@@ -317,6 +472,121 @@ func (s *PostgresStorage) ExportOrderToExcel(ctx context.Context, order Order) (
 	return filepath, nil
 }
 
+// orderExportHeaders is the column layout shared by every multi-order sheet.
+var orderExportHeaders = []string{
+	"Code", "ID", "User ID", "Width (cm)", "Height (cm)", "Texture ID",
+	"Texture Name", "Price", "Discount", "Leather Cost", "Process Cost",
+	"Total Cost", "Commission", "Tax", "Net Revenue", "Profit",
+	"Contact", "Status", "Due Date", "Created At", "Items", "Pieces",
+	"Fulfilment", "Delivery Address", "Delivery Fee", "Shape",
+	"Promo Code", "Promo Discount", "Loyalty Rate", "Loyalty Discount",
+	"Price Adjustment",
+}
+
+// orderItemExportHeaders describes the item rows; the first column is the order code
+var orderItemExportHeaders = []string{
+	"Order", "Position", "Texture ID", "Texture Name", "Width (cm)", "Height (cm)",
+	"Quantity", "Price", "Discount", "Leather Cost", "Process Cost", "Total Cost",
+	"Commission", "Tax", "Profit", "Shape", "Corner Radius (cm)", "Promo Discount",
+	"Loyalty Discount",
+}
+
+func orderItemExportRow(order Order, item OrderItem) []interface{} {
+	return []interface{}{
+		order.Number(),
+		item.Position,
+		item.TextureID,
+		item.TextureName,
+		item.WidthCM,
+		item.HeightCM,
+		item.Quantity,
+		exportMoney(item.Price),
+		exportMoney(item.Discount),
+		exportMoney(item.LeatherCost),
+		exportMoney(item.ProcessCost),
+		exportMoney(item.TotalCost),
+		exportMoney(item.Commission),
+		exportMoney(item.Tax),
+		exportMoney(item.Profit),
+		itemShape(item.Shape),
+		item.CornerRadiusCM,
+		exportMoney(item.PromoDiscount),
+		exportMoney(item.LoyaltyDiscount),
+	}
+}
+
+func orderExportRow(order Order) []interface{} {
+	return []interface{}{
+		order.Number(),
+		order.ID,
+		order.UserID,
+		order.WidthCM,
+		order.HeightCM,
+		order.TextureID,
+		order.TextureName,
+		exportMoney(order.Price),
+		exportMoney(order.Discount),
+		exportMoney(order.LeatherCost),
+		exportMoney(order.ProcessCost),
+		exportMoney(order.TotalCost),
+		exportMoney(order.Commission),
+		exportMoney(order.Tax),
+		exportMoney(order.NetRevenue),
+		exportMoney(order.Profit),
+		order.Contact,
+		order.Status,
+		formatExportDate(order.DueDate),
+		order.CreatedAt.Format("2006-01-02 15:04"),
+		len(order.Items),
+		order.TotalQuantity(),
+		order.Fulfilment.Method,
+		exportDeliveryAddress(order.Fulfilment),
+		exportMoney(order.DeliveryFee),
+		exportShape(order.Shape, order.CornerRadiusCM),
+		exportPromoCode(order.PromoCode),
+		exportMoney(order.PromoDiscount),
+		order.LoyaltyRate,
+		exportMoney(order.LoyaltyDiscount),
+		exportMoney(order.PriceAdjustment),
+	}
+}
+
+// exportMoney rounds a DECIMAL amount to the kopeck for a spreadsheet cell
+func exportMoney(rubles float64) float64 {
+	return money.FromRubles(rubles).Rubles()
+}
+
+func exportPromoCode(code *string) string {
+	if code == nil {
+		return ""
+	}
+	return *code
+}
+
+// exportShape names the shape, with the corner radius of rounded rectangles
+func exportShape(shape string, cornerRadiusCM int) string {
+	shape = itemShape(shape)
+	if shape == ShapeRoundedRect {
+		return fmt.Sprintf("%s (R%d cm)", shape, cornerRadiusCM)
+	}
+	return shape
+}
+
+// exportDeliveryAddress adds the shared coordinates to the address
+func exportDeliveryAddress(f Fulfilment) string {
+	if !f.HasLocation() {
+		return f.Address
+	}
+	return fmt.Sprintf("%s (%.6f, %.6f)", f.Address, *f.Latitude, *f.Longitude)
+}
+
+func formatExportDate(date *time.Time) string {
+	if date == nil {
+		return ""
+	}
+	return date.Format("2006-01-02")
+}
+
 func (s *PostgresStorage) ExportAllOrdersToExcel(ctx context.Context, filename string) error {
     const operation = "storage.ExportAllOrdersToExcel"
 	
@@ -336,6 +606,10 @@ func (s *PostgresStorage) ExportAllOrdersToExcel(ctx context.Context, filename s
         return fmt.Errorf("failed to fetch orders: %w", err)
     }
 
+	if err := s.loadOrderItems(ctx, orders); err != nil {
+		return err
+	}
+
 	f := excelize.NewFile()
 	defer f.Close()
 
@@ -345,44 +619,38 @@ func (s *PostgresStorage) ExportAllOrdersToExcel(ctx context.Context, filename s
 	}
 
 	// Заголовки
-	headers := []string{
-		"ID", "User ID", "Width (cm)", "Height (cm)", "Texture ID",
-		"Texture Name", "Price", "Leather Cost", "Process Cost",
-		"Total Cost", "Commission", "Tax", "Net Revenue", "Profit",
-		"Contact", "Status", "Created At",
-	}
-	for col, header := range headers {
+	for col, header := range orderExportHeaders {
 		cell, _ := excelize.CoordinatesToCellName(col+1, 1)
 		f.SetCellValue("Orders", cell, header)
 	}
 
 	// Данные
 	for row, order := range orders {
-		data := []interface{}{
-			order.ID,
-			order.UserID,
-			order.WidthCM,
-			order.HeightCM,
-			order.TextureID,
-			order.TextureName,
-			order.Price,
-			order.LeatherCost,
-			order.ProcessCost,
-			order.TotalCost,
-			order.Commission,
-			order.Tax,
-			order.NetRevenue,
-			order.Profit,
-			order.Contact,
-			order.Status,
-			order.CreatedAt.Format("2006-01-02 15:04"),
-		}
-		for col, value := range data {
+		for col, value := range orderExportRow(order) {
 			cell, _ := excelize.CoordinatesToCellName(col+1, row+2)
 			f.SetCellValue("Orders", cell, value)
 		}
 	}
 
+	// Позиции заказов
+	if _, err := f.NewSheet("Items"); err != nil {
+		return fmt.Errorf("failed to create items sheet: %w", err)
+	}
+	for col, header := range orderItemExportHeaders {
+		cell, _ := excelize.CoordinatesToCellName(col+1, 1)
+		f.SetCellValue("Items", cell, header)
+	}
+	row := 2
+	for _, order := range orders {
+		for _, item := range order.Items {
+			for col, value := range orderItemExportRow(order, item) {
+				cell, _ := excelize.CoordinatesToCellName(col+1, row)
+				f.SetCellValue("Items", cell, value)
+			}
+			row++
+		}
+	}
+
 	f.SetActiveSheet(index)
 
 	// Создаем папку если не существует
@@ -427,100 +695,93 @@ func (s *PostgresStorage) GetUserAgreement(ctx context.Context, userID int64) (b
     return agreed, phone, err
 }
 
-func (s *PostgresStorage) UpdateOrderStatus(ctx context.Context, orderID int64, status string) error {
-	// Get all orders
-	const query = `
-		SELECT * 
-		FROM orders 
-		ORDER BY created_at 
-		DESC
-	`
-	
-	
-	var orders []Order
-	if err := s.db.SelectContext(ctx, &orders, query); err != nil {
-		return fmt.Errorf("failed to fetch orders: %w", err)
-	}
-
-	// Create or open file
-	filename := "reports/current_orders.xlsx"
-	f := excelize.NewFile()
+func (s *PostgresStorage) UpdateOrderStatus(ctx context.Context, orderID int64, status string, changedBy int64) error {
+	return s.transitionOrderStatus(ctx, orderID, status, changedBy, "", nil)
+}
 
-	if _, err := os.Stat(filename); err == nil {
-		f, err = excelize.OpenFile(filename)
-		if err != nil {
-			return fmt.Errorf("failed to open existing file: %w", err)
-		}
-		// Clear existing data if needed
-		if err := f.DeleteSheet("Orders"); err != nil {
-			return fmt.Errorf("failed to clear old sheet: %w", err)
-		}
-	}
+// CancelOrderByCustomer cancels a customer's own order while it is still in an early status
+func (s *PostgresStorage) CancelOrderByCustomer(ctx context.Context, orderID, userID int64, reason string) error {
+	return s.transitionOrderStatus(ctx, orderID, StatusCancelled, userID, reason,
+		func(ownerID int64, current string) error {
+			if ownerID != userID {
+				return ErrOrderNotFound
+			}
+			if !CanCustomerCancel(current) {
+				return fmt.Errorf("%w: order is %s", ErrOrderNotCancellable, current)
+			}
+			return nil
+		})
+}
 
-	// Create fresh sheet
-	index, err := f.NewSheet("Orders")
+// transitionOrderStatus moves an order to a new status and records the change.
+// The optional check runs against the locked row before the lifecycle rules.
+func (s *PostgresStorage) transitionOrderStatus(
+	ctx context.Context,
+	orderID int64,
+	status string,
+	changedBy int64,
+	comment string,
+	check func(ownerID int64, current string) error,
+) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to create sheet: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	// Заголовки
-	headers := []string{
-		"ID", "User ID", "Width (cm)", "Height (cm)", "Texture ID",
-		"Texture Name", "Price", "Leather Cost", "Process Cost",
-		"Total Cost", "Commission", "Tax", "Net Revenue", "Profit",
-		"Contact", "Status", "Created At",
+	// Lock the row so concurrent updates can't both pass the transition check
+	var current struct {
+		UserID int64   `db:"user_id"`
+		Status string  `db:"status"`
+		Price  float64 `db:"price"`
 	}
-	for col, header := range headers {
-		cell, _ := excelize.CoordinatesToCellName(col+1, 1)
-		f.SetCellValue("Orders", cell, header)
+	err = tx.GetContext(ctx, &current, `SELECT user_id, status, price FROM orders WHERE id = $1 FOR UPDATE`, orderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrOrderNotFound
+		}
+		return fmt.Errorf("failed to get order status: %w", err)
 	}
 
-	// Данные
-	for row, order := range orders {
-		data := []interface{}{
-			order.ID,
-			order.UserID,
-			order.WidthCM,
-			order.HeightCM,
-			order.TextureID,
-			order.TextureName,
-			order.Price,
-			order.LeatherCost,
-			order.ProcessCost,
-			order.TotalCost,
-			order.Commission,
-			order.Tax,
-			order.NetRevenue,
-			order.Profit,
-			order.Contact,
-			order.Status,
-			order.CreatedAt.Format("2006-01-02 15:04"),
-		}
-		for col, value := range data {
-			cell, _ := excelize.CoordinatesToCellName(col+1, row+2)
-			f.SetCellValue("Orders", cell, value)
+	if check != nil {
+		if err := check(current.UserID, current.Status); err != nil {
+			return err
 		}
 	}
 
-	f.SetActiveSheet(index)
+	if err := ValidateStatusTransition(current.Status, status); err != nil {
+		return err
+	}
 
-	// Создаем папку если не существует
-	if err := os.MkdirAll("reports", 0755); err != nil {
-		return fmt.Errorf("failed to create reports directory: %w", err)
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE orders SET status = $1, updated_at = NOW() WHERE id = $2`,
+		status, orderID,
+	); err != nil {
+		return fmt.Errorf("failed to update order status: %w", err)
 	}
 
-	// Сохраняем в один файл
-	filepath := fmt.Sprintf("reports/%s.xlsx", filename)
-	if err := f.SaveAs(filepath); err != nil {
-		return fmt.Errorf("failed to save Excel file: %w", err)
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO order_status_history (order_id, from_status, to_status, changed_by, comment)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''))`,
+		orderID, current.Status, status, changedBy, comment,
+	); err != nil {
+		return fmt.Errorf("failed to record status change: %w", err)
 	}
 
-	// Ensure directory exists
-	if err := os.MkdirAll("reports", 0755); err != nil {
-		return fmt.Errorf("failed to create reports directory: %w", err)
+	if status == StatusDelivered {
+		if err := countDeliveredOrder(ctx, tx, current.UserID, current.Price); err != nil {
+			return err
+		}
 	}
 
-	return f.SaveAs(filename)
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit status change: %w", err)
+	}
+
+	// Invalidate statistics cache
+	s.redis.Del(ctx, "order_stats")
+
+	return nil
 }
 
 func (s *PostgresStorage) Close() error {
@@ -536,10 +797,15 @@ func (s *PostgresStorage) GetOrderByID(ctx context.Context, orderID int64) (*Ord
 	err := s.db.GetContext(ctx, &order, query, orderID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("order not found")
+			return nil, ErrOrderNotFound
 		}
 		return nil, fmt.Errorf("failed to get order: %w", err)
 	}
+
+	order.Items, err = s.GetOrderItems(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
 	return &order, nil
 }
 
@@ -569,6 +835,15 @@ func (s *PostgresStorage) GetOrderStatistics(ctx context.Context) (*OrderStatist
 		return nil, fmt.Errorf("failed to get total stats: %w", err)
 	}
 
+	// Get the number of pieces across all orders
+	err = s.db.QueryRowContext(ctx, `
+        SELECT COALESCE(SUM(quantity), 0)
+        FROM order_items
+    `).Scan(&stats.TotalItems)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get item stats: %w", err)
+	}
+
 	// Get today's stats
 	err = s.db.QueryRowContext(ctx, `
         SELECT 
@@ -625,6 +900,16 @@ func (s *PostgresStorage) GetOrderStatistics(ctx context.Context) (*OrderStatist
 		stats.StatusCounts[sc.Status] = sc.Count
 	}
 
+	// Get open orders past their due date
+	err = s.db.QueryRowContext(ctx, `
+        SELECT COUNT(*)
+        FROM orders
+        WHERE due_date < CURRENT_DATE AND status = ANY($1) AND deleted_at IS NULL
+    `, pq.Array(openStatuses)).Scan(&stats.OverdueOrders)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get overdue stats: %w", err)
+	}
+
 	// Cache the result
 	if data, err := json.Marshal(stats); err == nil {
 		s.redis.Set(ctx, cacheKey, data, 1*time.Hour)
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrPriceFormulaNotFound = errors.New("price formula not found")
+
+// PriceFormula prices the items of one service, e.g. "area*price*k" with
+// Parameters {"k": 2.4}. ServiceType is the catalogue texture name.
+type PriceFormula struct {
+	ID          int64             `db:"id"`
+	ServiceType string            `db:"service_type"`
+	Formula     string            `db:"formula"` // "width*height*price*coefficient"
+	Parameters  FormulaParameters `db:"parameters"`
+	Active      bool              `db:"active"`
+	CreatedBy   *int64            `db:"created_by"`
+	CreatedAt   time.Time         `db:"created_at"`
+	ActivatedAt *time.Time        `db:"activated_at"`
+}
+
+// FormulaParameters are the named constants of a formula, stored as JSONB
+type FormulaParameters map[string]float64
+
+func (p FormulaParameters) Value() (driver.Value, error) {
+	if p == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(p)
+}
+
+func (p *FormulaParameters) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	case nil:
+		*p = FormulaParameters{}
+		return nil
+	default:
+		return fmt.Errorf("unsupported formula parameters type %T", src)
+	}
+	return json.Unmarshal(data, p)
+}
+
+// SavePriceFormula stores a new inactive formula and sets its ID
+func (s *PostgresStorage) SavePriceFormula(ctx context.Context, formula *PriceFormula) error {
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO price_formulas (service_type, formula, parameters, created_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`,
+		formula.ServiceType, formula.Formula, formula.Parameters, formula.CreatedBy,
+	).Scan(&formula.ID, &formula.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save price formula: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) GetPriceFormula(ctx context.Context, id int64) (*PriceFormula, error) {
+	var formula PriceFormula
+	err := s.db.GetContext(ctx, &formula, `SELECT * FROM price_formulas WHERE id = $1`, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPriceFormulaNotFound
+		}
+		return nil, fmt.Errorf("failed to get price formula: %w", err)
+	}
+	return &formula, nil
+}
+
+// GetPriceFormulas returns every formula, active ones first
+func (s *PostgresStorage) GetPriceFormulas(ctx context.Context) ([]PriceFormula, error) {
+	var formulas []PriceFormula
+	err := s.db.SelectContext(ctx, &formulas, `
+		SELECT * FROM price_formulas ORDER BY active DESC, service_type, id DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get price formulas: %w", err)
+	}
+	return formulas, nil
+}
+
+// GetActivePriceFormula returns the formula in use for a service, or
+// ErrPriceFormulaNotFound when the service uses the standard pricing
+func (s *PostgresStorage) GetActivePriceFormula(ctx context.Context, serviceType string) (*PriceFormula, error) {
+	var formula PriceFormula
+	err := s.db.GetContext(ctx, &formula, `
+		SELECT * FROM price_formulas WHERE service_type = $1 AND active`,
+		serviceType,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPriceFormulaNotFound
+		}
+		return nil, fmt.Errorf("failed to get active price formula: %w", err)
+	}
+	return &formula, nil
+}
+
+// ActivatePriceFormula puts a formula in use, replacing the active formula of its service
+func (s *PostgresStorage) ActivatePriceFormula(ctx context.Context, id int64) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var serviceType string
+	err = tx.GetContext(ctx, &serviceType, `SELECT service_type FROM price_formulas WHERE id = $1 FOR UPDATE`, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrPriceFormulaNotFound
+		}
+		return fmt.Errorf("failed to get price formula: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE price_formulas SET active = FALSE
+		WHERE service_type = $1 AND active AND id <> $2`,
+		serviceType, id,
+	); err != nil {
+		return fmt.Errorf("failed to deactivate price formulas: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE price_formulas SET active = TRUE, activated_at = NOW()
+		WHERE id = $1 AND NOT active`,
+		id,
+	); err != nil {
+		return fmt.Errorf("failed to activate price formula: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// DeactivatePriceFormula returns the formula's service to the standard pricing
+func (s *PostgresStorage) DeactivatePriceFormula(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE price_formulas SET active = FALSE WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to deactivate price formula: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to deactivate price formula: %w", err)
+	}
+	if rows == 0 {
+		return ErrPriceFormulaNotFound
+	}
+	return nil
+}
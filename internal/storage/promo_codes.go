@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Promo code kinds
+const (
+	PromoPercent = "percent"
+	PromoFixed   = "fixed"
+)
+
+var (
+	ErrPromoCodeNotFound = errors.New("promo code not found")
+	ErrPromoCodeExists   = errors.New("promo code already exists")
+	// ErrPromoCodeInactive covers disabled codes and dates outside the validity window
+	ErrPromoCodeInactive  = errors.New("promo code is not valid now")
+	ErrPromoCodeExhausted = errors.New("promo code usage limit reached")
+	ErrPromoCodeUsed      = errors.New("promo code already used by the customer")
+)
+
+// PromoCode is a discount customers apply with /promo
+type PromoCode struct {
+	Code string `db:"code"`
+	Kind string `db:"kind"`
+	// Value is a percentage for PromoPercent codes and roubles for PromoFixed ones
+	Value float64 `db:"value"`
+	// ValidFrom and ValidUntil are inclusive dates; nil leaves the window open
+	ValidFrom  *time.Time `db:"valid_from"`
+	ValidUntil *time.Time `db:"valid_until"`
+	// MaxUses and MaxUsesPerUser of 0 mean no limit
+	MaxUses        int `db:"max_uses"`
+	MaxUsesPerUser int `db:"max_uses_per_user"`
+	// Textures are the names of the textures the code applies to; empty for all
+	Textures  pq.StringArray `db:"textures"`
+	Active    bool           `db:"active"`
+	CreatedBy *int64         `db:"created_by"`
+	CreatedAt time.Time      `db:"created_at"`
+}
+
+// PromoCodeStats is a promo code with its redemptions so far
+type PromoCodeStats struct {
+	PromoCode
+	Uses     int     `db:"uses"`
+	Discount float64 `db:"total_discount"`
+}
+
+// NormalizePromoCode is how codes are stored and looked up: without
+// surrounding spaces and in upper case
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// AppliesTo reports whether items of the texture get the discount
+func (p PromoCode) AppliesTo(textureName string) bool {
+	return len(p.Textures) == 0 || slices.Contains(p.Textures, textureName)
+}
+
+// Check tells whether the code can be used at now by a customer who has
+// already used it userUses times, while it has uses redemptions in total
+func (p PromoCode) Check(now time.Time, uses, userUses int) error {
+	today := dateOf(now)
+	if !p.Active ||
+		(p.ValidFrom != nil && today.Before(dateOf(*p.ValidFrom))) ||
+		(p.ValidUntil != nil && today.After(dateOf(*p.ValidUntil))) {
+		return ErrPromoCodeInactive
+	}
+	if p.MaxUses > 0 && uses >= p.MaxUses {
+		return ErrPromoCodeExhausted
+	}
+	if p.MaxUsesPerUser > 0 && userUses >= p.MaxUsesPerUser {
+		return ErrPromoCodeUsed
+	}
+	return nil
+}
+
+// promoCodeUsesQuery counts the orders that redeemed a code, in total and by
+// one customer; cancelled and deleted orders give their use back
+const promoCodeUsesQuery = `
+	SELECT COUNT(*) AS uses, COUNT(*) FILTER (WHERE user_id = $2) AS user_uses
+	FROM orders
+	WHERE promo_code = $1 AND status <> $3 AND deleted_at IS NULL
+`
+
+type promoCodeUses struct {
+	Uses     int `db:"uses"`
+	UserUses int `db:"user_uses"`
+}
+
+// SavePromoCode creates a promo code
+func (s *PostgresStorage) SavePromoCode(ctx context.Context, promo PromoCode) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO promo_codes (
+			code, kind, value, valid_from, valid_until, max_uses, max_uses_per_user,
+			textures, created_by
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		NormalizePromoCode(promo.Code),
+		promo.Kind,
+		promo.Value,
+		promo.ValidFrom,
+		promo.ValidUntil,
+		promo.MaxUses,
+		promo.MaxUsesPerUser,
+		pq.Array([]string(promo.Textures)),
+		promo.CreatedBy,
+	)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrPromoCodeExists
+		}
+		return fmt.Errorf("failed to save promo code: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStorage) GetPromoCode(ctx context.Context, code string) (*PromoCode, error) {
+	return getPromoCode(ctx, s.db, code, "")
+}
+
+// getPromoCode loads a code; lock is appended to the query, e.g. FOR UPDATE
+func getPromoCode(ctx context.Context, q sqlx.QueryerContext, code, lock string) (*PromoCode, error) {
+	var promo PromoCode
+	err := sqlx.GetContext(ctx, q, &promo, `SELECT * FROM promo_codes WHERE code = $1 `+lock, NormalizePromoCode(code))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPromoCodeNotFound
+		}
+		return nil, fmt.Errorf("failed to get promo code: %w", err)
+	}
+	return &promo, nil
+}
+
+// CheckPromoCode finds a code and checks that userID can use it now
+func (s *PostgresStorage) CheckPromoCode(ctx context.Context, code string, userID int64, now time.Time) (*PromoCode, error) {
+	promo, err := s.GetPromoCode(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	var uses promoCodeUses
+	if err := s.db.GetContext(ctx, &uses, promoCodeUsesQuery, promo.Code, userID, StatusCancelled); err != nil {
+		return nil, fmt.Errorf("failed to count promo code uses: %w", err)
+	}
+	if err := promo.Check(now, uses.Uses, uses.UserUses); err != nil {
+		return nil, err
+	}
+	return promo, nil
+}
+
+// redeemPromoCode checks the order's promo code once more while saving the
+// order. The code stays locked until the transaction ends, so two orders
+// cannot take its last use.
+func redeemPromoCode(ctx context.Context, tx *sqlx.Tx, order Order) error {
+	if order.PromoCode == nil {
+		return nil
+	}
+
+	promo, err := getPromoCode(ctx, tx, *order.PromoCode, "FOR UPDATE")
+	if err != nil {
+		return err
+	}
+
+	var uses promoCodeUses
+	if err := tx.GetContext(ctx, &uses, promoCodeUsesQuery, promo.Code, order.UserID, StatusCancelled); err != nil {
+		return fmt.Errorf("failed to count promo code uses: %w", err)
+	}
+	now := order.CreatedAt
+	if now.IsZero() {
+		now = time.Now()
+	}
+	return promo.Check(now, uses.Uses, uses.UserUses)
+}
+
+// DeactivatePromoCode stops a code from being applied; orders that already
+// used it keep their discount
+func (s *PostgresStorage) DeactivatePromoCode(ctx context.Context, code string) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE promo_codes SET active = FALSE WHERE code = $1`, NormalizePromoCode(code))
+	if err != nil {
+		return fmt.Errorf("failed to deactivate promo code: %w", err)
+	}
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check deactivated promo code: %w", err)
+	}
+	if updated == 0 {
+		return ErrPromoCodeNotFound
+	}
+	return nil
+}
+
+// GetPromoCodeStats lists all codes, newest first, with how many orders
+// redeemed them and the discount given
+func (s *PostgresStorage) GetPromoCodeStats(ctx context.Context) ([]PromoCodeStats, error) {
+	var stats []PromoCodeStats
+	err := s.db.SelectContext(ctx, &stats, `
+		SELECT p.*,
+			COUNT(o.id) AS uses,
+			COALESCE(SUM(o.promo_discount), 0) AS total_discount
+		FROM promo_codes p
+		LEFT JOIN orders o ON o.promo_code = p.code AND o.status <> $1 AND o.deleted_at IS NULL
+		GROUP BY p.code
+		ORDER BY p.created_at DESC`,
+		StatusCancelled,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get promo code stats: %w", err)
+	}
+	return stats, nil
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPromoCodeCheck(t *testing.T) {
+	from := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2026, 11, 30, 0, 0, 0, 0, time.UTC)
+	promo := PromoCode{
+		Code:           "AUTUMN",
+		Kind:           PromoPercent,
+		Value:          10,
+		ValidFrom:      &from,
+		ValidUntil:     &until,
+		MaxUses:        100,
+		MaxUsesPerUser: 1,
+		Active:         true,
+	}
+	inWindow := time.Date(2026, 11, 30, 23, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		promo    PromoCode
+		now      time.Time
+		uses     int
+		userUses int
+		want     error
+	}{
+		{"valid on the last day", promo, inWindow, 99, 0, nil},
+		{"before the window", promo, from.Add(-time.Hour), 0, 0, ErrPromoCodeInactive},
+		{"after the window", promo, until.AddDate(0, 0, 1), 0, 0, ErrPromoCodeInactive},
+		{"disabled", PromoCode{Active: false}, inWindow, 0, 0, ErrPromoCodeInactive},
+		{"exhausted", promo, inWindow, 100, 0, ErrPromoCodeExhausted},
+		{"used by the customer", promo, inWindow, 1, 1, ErrPromoCodeUsed},
+		{"no limits", PromoCode{Active: true}, inWindow, 1000, 1000, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.promo.Check(tt.now, tt.uses, tt.userUses); !errors.Is(err, tt.want) {
+				t.Errorf("Check() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPromoCodeAppliesTo(t *testing.T) {
+	if !(PromoCode{}).AppliesTo("Питон") {
+		t.Error("a code without textures should apply to every texture")
+	}
+	promo := PromoCode{Textures: []string{"Питон", "Кожа Люкс"}}
+	if !promo.AppliesTo("Кожа Люкс") || promo.AppliesTo("Крокодил") {
+		t.Errorf("AppliesTo does not follow the textures %v", promo.Textures)
+	}
+}
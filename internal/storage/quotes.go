@@ -0,0 +1,246 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Quote request statuses
+const (
+	QuoteStatusPending  = "pending"
+	QuoteStatusQuoted   = "quoted"
+	QuoteStatusAccepted = "accepted"
+	QuoteStatusDeclined = "declined"
+)
+
+var (
+	ErrQuoteNotFound = errors.New("quote request not found")
+	ErrQuoteNotOpen  = errors.New("quote request is already closed")
+)
+
+// QuoteRequest is an order for a texture outside the catalogue waiting for a price
+type QuoteRequest struct {
+	ID                 int64      `db:"id"`
+	UserID             int64      `db:"user_id"`
+	Contact            string     `db:"contact"`
+	TextureDescription string     `db:"texture_description"`
+	WidthCM            int        `db:"width_cm"`
+	HeightCM           int        `db:"height_cm"`
+	Shape              string     `db:"shape"`
+	CornerRadiusCM     int        `db:"corner_radius_cm"`
+	Quantity           int        `db:"quantity"`
+	DueDate            *time.Time `db:"due_date"`
+	Status             string     `db:"status"`
+	PricePerDM2        *float64   `db:"price_per_dm2"`
+	TextureID          *string    `db:"texture_id"`
+	QuotedBy           *int64     `db:"quoted_by"`
+	QuotedAt           *time.Time `db:"quoted_at"`
+	OrderID            *int64     `db:"order_id"`
+	CreatedAt          time.Time  `db:"created_at"`
+	Fulfilment
+}
+
+const quoteRequestColumns = `
+	id, user_id, contact, texture_description, width_cm, height_cm, quantity,
+	due_date, status, price_per_dm2, texture_id::text, quoted_by, quoted_at,
+	order_id, created_at, fulfilment, delivery_address, delivery_latitude, delivery_longitude,
+	shape, corner_radius_cm
+`
+
+func (s *PostgresStorage) SaveQuoteRequest(ctx context.Context, quote QuoteRequest) (int64, error) {
+	const query = `
+		INSERT INTO quote_requests (
+			user_id, contact, texture_description, width_cm, height_cm, quantity, due_date,
+			fulfilment, delivery_address, delivery_latitude, delivery_longitude,
+			shape, corner_radius_cm
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id
+	`
+
+	var quoteID int64
+	err := s.db.QueryRowContext(ctx, query,
+		quote.UserID,
+		quote.Contact,
+		quote.TextureDescription,
+		quote.WidthCM,
+		quote.HeightCM,
+		quote.Quantity,
+		quote.DueDate,
+		quote.Fulfilment.Method,
+		quote.Fulfilment.Address,
+		quote.Fulfilment.Latitude,
+		quote.Fulfilment.Longitude,
+		itemShape(quote.Shape),
+		quote.CornerRadiusCM,
+	).Scan(&quoteID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save quote request: %w", err)
+	}
+	return quoteID, nil
+}
+
+func (s *PostgresStorage) GetQuoteRequest(ctx context.Context, quoteID int64) (*QuoteRequest, error) {
+	var quote QuoteRequest
+	err := s.db.GetContext(ctx, &quote,
+		`SELECT `+quoteRequestColumns+` FROM quote_requests WHERE id = $1`, quoteID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrQuoteNotFound
+		}
+		return nil, fmt.Errorf("failed to get quote request: %w", err)
+	}
+	return &quote, nil
+}
+
+// GetOpenQuoteRequests returns requests still waiting for a price or for the customer's answer
+func (s *PostgresStorage) GetOpenQuoteRequests(ctx context.Context) ([]QuoteRequest, error) {
+	var quotes []QuoteRequest
+	err := s.db.SelectContext(ctx, &quotes,
+		`SELECT `+quoteRequestColumns+` FROM quote_requests
+		WHERE status IN ($1, $2)
+		ORDER BY created_at`,
+		QuoteStatusPending, QuoteStatusQuoted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get open quote requests: %w", err)
+	}
+	return quotes, nil
+}
+
+// SetQuotePrice stores the admin's price per dm² and keeps the request's
+// hidden texture in sync with it. A quote may be revised until the customer answers.
+func (s *PostgresStorage) SetQuotePrice(ctx context.Context, quoteID int64, pricePerDM2 float64, adminID int64) (*QuoteRequest, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var quote QuoteRequest
+	err = tx.GetContext(ctx, &quote,
+		`SELECT `+quoteRequestColumns+` FROM quote_requests WHERE id = $1 FOR UPDATE`, quoteID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrQuoteNotFound
+		}
+		return nil, fmt.Errorf("failed to get quote request: %w", err)
+	}
+	if quote.Status != QuoteStatusPending && quote.Status != QuoteStatusQuoted {
+		return nil, ErrQuoteNotOpen
+	}
+
+	if quote.TextureID == nil {
+		var textureID string
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO textures (name, price_per_dm2, in_stock)
+			VALUES ($1, $2, FALSE)
+			RETURNING id::text`,
+			fmt.Sprintf("%s (запрос #%d)", quote.TextureDescription, quote.ID), pricePerDM2,
+		).Scan(&textureID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create quote texture: %w", err)
+		}
+		quote.TextureID = &textureID
+	} else if _, err := tx.ExecContext(ctx, `
+		UPDATE textures SET price_per_dm2 = $1, updated_at = NOW() WHERE id = $2`,
+		pricePerDM2, *quote.TextureID,
+	); err != nil {
+		return nil, fmt.Errorf("failed to update quote texture: %w", err)
+	}
+
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE quote_requests
+		SET status = $1, price_per_dm2 = $2, texture_id = $3, quoted_by = $4,
+			quoted_at = $5, updated_at = NOW()
+		WHERE id = $6`,
+		QuoteStatusQuoted, pricePerDM2, *quote.TextureID, adminID, now, quote.ID,
+	); err != nil {
+		return nil, fmt.Errorf("failed to save quote price: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit quote price: %w", err)
+	}
+
+	// A revised price must not be served from the texture cache
+	s.redis.Del(ctx, fmt.Sprintf("texture:%s", *quote.TextureID))
+
+	quote.Status = QuoteStatusQuoted
+	quote.PricePerDM2 = &pricePerDM2
+	quote.QuotedBy = &adminID
+	quote.QuotedAt = &now
+	return &quote, nil
+}
+
+// AcceptQuote turns a quoted request of userID into the given order and
+// sets the order's ID and code
+func (s *PostgresStorage) AcceptQuote(ctx context.Context, quoteID, userID int64, order *Order) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var current struct {
+		UserID int64  `db:"user_id"`
+		Status string `db:"status"`
+	}
+	err = tx.GetContext(ctx, &current,
+		`SELECT user_id, status FROM quote_requests WHERE id = $1 FOR UPDATE`, quoteID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrQuoteNotFound
+		}
+		return fmt.Errorf("failed to get quote request: %w", err)
+	}
+	if current.UserID != userID {
+		return ErrQuoteNotFound
+	}
+	if current.Status != QuoteStatusQuoted {
+		return ErrQuoteNotOpen
+	}
+
+	if err := s.insertOrder(ctx, tx, order); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE quote_requests SET status = $1, order_id = $2, updated_at = NOW()
+		WHERE id = $3`,
+		QuoteStatusAccepted, order.ID, quoteID,
+	); err != nil {
+		return fmt.Errorf("failed to accept quote request: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit quote acceptance: %w", err)
+	}
+
+	// Invalidate statistics cache
+	s.redis.Del(ctx, "order_stats")
+
+	return nil
+}
+
+// DeclineQuote closes an open request of userID without an order
+func (s *PostgresStorage) DeclineQuote(ctx context.Context, quoteID, userID int64) error {
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE quote_requests SET status = $1, updated_at = NOW()
+		WHERE id = $2 AND user_id = $3 AND status IN ($4, $5)`,
+		QuoteStatusDeclined, quoteID, userID, QuoteStatusPending, QuoteStatusQuoted)
+	if err != nil {
+		return fmt.Errorf("failed to decline quote request: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to decline quote request: %w", err)
+	}
+	if affected == 0 {
+		return ErrQuoteNotOpen
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package storage
+
+import "slices"
+
+// Outlines the workshop cuts. A circle's diameter is stored as both its width
+// and height; rounded rectangles also store the corner radius.
+const (
+	ShapeRectangle   = "rectangle"
+	ShapeCircle      = "circle"
+	ShapeOval        = "oval"
+	ShapeRoundedRect = "rounded_rect"
+)
+
+// Shapes lists every supported outline
+var Shapes = []string{ShapeRectangle, ShapeCircle, ShapeOval, ShapeRoundedRect}
+
+// IsValidShape reports whether shape is supported; empty means a rectangle
+func IsValidShape(shape string) bool {
+	return shape == "" || slices.Contains(Shapes, shape)
+}
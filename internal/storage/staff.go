@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Staff roles
+const (
+	StaffRoleCraftsman = "craftsman"
+)
+
+var ErrStaffNotFound = errors.New("staff member not found")
+
+// StaffMember is a workshop employee known to the bot by their Telegram ID
+type StaffMember struct {
+	UserID    int64     `db:"user_id"`
+	Name      string    `db:"name"`
+	Role      string    `db:"role"`
+	Active    bool      `db:"active"`
+	CreatedBy *int64    `db:"created_by"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// CraftsmanWorkload is the number of open orders assigned to a craftsman
+type CraftsmanWorkload struct {
+	UserID        int64  `db:"user_id"`
+	Name          string `db:"name"`
+	OpenOrders    int    `db:"open_orders"`
+	OverdueOrders int    `db:"overdue_orders"`
+}
+
+// StaffWorkload is how the open orders are spread across the craftsmen
+type StaffWorkload struct {
+	Craftsmen  []CraftsmanWorkload
+	Unassigned int
+}
+
+// SaveStaffMember adds a staff member or updates and reactivates an existing one
+func (s *PostgresStorage) SaveStaffMember(ctx context.Context, member StaffMember) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO staff (user_id, name, role, active, created_by)
+		VALUES ($1, $2, $3, TRUE, $4)
+		ON CONFLICT (user_id) DO UPDATE SET
+			name = EXCLUDED.name,
+			role = EXCLUDED.role,
+			active = TRUE`,
+		member.UserID, member.Name, member.Role, member.CreatedBy,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save staff member: %w", err)
+	}
+	return nil
+}
+
+// DeactivateStaffMember removes a staff member from the team. Orders keep
+// their assignment so the history stays intact.
+func (s *PostgresStorage) DeactivateStaffMember(ctx context.Context, userID int64) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE staff SET active = FALSE WHERE user_id = $1 AND active`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to deactivate staff member: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to deactivate staff member: %w", err)
+	}
+	if rows == 0 {
+		return ErrStaffNotFound
+	}
+	return nil
+}
+
+// GetStaffMember returns an active staff member
+func (s *PostgresStorage) GetStaffMember(ctx context.Context, userID int64) (*StaffMember, error) {
+	var member StaffMember
+	err := s.db.GetContext(ctx, &member, `SELECT * FROM staff WHERE user_id = $1 AND active`, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrStaffNotFound
+		}
+		return nil, fmt.Errorf("failed to get staff member: %w", err)
+	}
+	return &member, nil
+}
+
+// GetCraftsmen returns the active craftsmen by name
+func (s *PostgresStorage) GetCraftsmen(ctx context.Context) ([]StaffMember, error) {
+	var craftsmen []StaffMember
+	err := s.db.SelectContext(ctx, &craftsmen, `
+		SELECT * FROM staff WHERE role = $1 AND active ORDER BY name, user_id`,
+		StaffRoleCraftsman,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get craftsmen: %w", err)
+	}
+	return craftsmen, nil
+}
+
+// AssignOrder makes craftsmanID responsible for the order
+func (s *PostgresStorage) AssignOrder(ctx context.Context, orderID, craftsmanID int64) error {
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE orders SET assigned_to = $2, assigned_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL`,
+		orderID, craftsmanID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to assign order: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to assign order: %w", err)
+	}
+	if rows == 0 {
+		return ErrOrderNotFound
+	}
+	return nil
+}
+
+// GetCraftsmanQueue returns the open orders assigned to a craftsman, rush
+// orders first, then the earliest due
+func (s *PostgresStorage) GetCraftsmanQueue(ctx context.Context, userID int64) ([]Order, error) {
+	var orders []Order
+	err := s.db.SelectContext(ctx, &orders, `
+		SELECT * FROM orders
+		WHERE assigned_to = $1 AND status = ANY($2) AND deleted_at IS NULL
+		ORDER BY rush DESC, due_date NULLS LAST, id`,
+		userID, pq.Array(openStatuses),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get craftsman queue: %w", err)
+	}
+
+	if err := s.loadOrderItems(ctx, orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// GetStaffWorkload counts the open orders of every active craftsman and
+// those nobody has been assigned yet
+func (s *PostgresStorage) GetStaffWorkload(ctx context.Context, now time.Time) (StaffWorkload, error) {
+	var workload StaffWorkload
+	today := now.Format("2006-01-02")
+
+	err := s.db.SelectContext(ctx, &workload.Craftsmen, `
+		SELECT s.user_id, s.name,
+		       COUNT(o.id) AS open_orders,
+		       COUNT(o.id) FILTER (WHERE o.due_date < $3) AS overdue_orders
+		FROM staff s
+		LEFT JOIN orders o ON o.assigned_to = s.user_id
+		    AND o.status = ANY($2) AND o.deleted_at IS NULL
+		WHERE s.role = $1 AND s.active
+		GROUP BY s.user_id, s.name
+		ORDER BY open_orders DESC, s.name`,
+		StaffRoleCraftsman, pq.Array(openStatuses), today,
+	)
+	if err != nil {
+		return StaffWorkload{}, fmt.Errorf("failed to get staff workload: %w", err)
+	}
+
+	err = s.db.GetContext(ctx, &workload.Unassigned, `
+		SELECT COUNT(*) FROM orders
+		WHERE assigned_to IS NULL AND status = ANY($1) AND deleted_at IS NULL`,
+		pq.Array(openStatuses),
+	)
+	if err != nil {
+		return StaffWorkload{}, fmt.Errorf("failed to count unassigned orders: %w", err)
+	}
+	return workload, nil
+}
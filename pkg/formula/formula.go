@@ -0,0 +1,404 @@
+// Package formula evaluates arithmetic price formulas such as
+// "area*price*k + perimeter*edge". A formula can only use numbers, named
+// variables, + - * /, parentheses and a few functions, so it is safe to
+// accept from admins at runtime.
+package formula
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// MaxLength limits the source of a formula
+	MaxLength = 500
+	// maxDepth limits the nesting of parentheses and functions
+	maxDepth = 32
+)
+
+// functions lists what a formula may call, with the number of arguments
+// (-1 for one or more)
+var functions = map[string]struct {
+	args int
+	fn   func(args []float64) float64
+}{
+	"min":   {-1, func(a []float64) float64 { return minOf(a) }},
+	"max":   {-1, func(a []float64) float64 { return maxOf(a) }},
+	"round": {1, func(a []float64) float64 { return math.Round(a[0]) }},
+	"ceil":  {1, func(a []float64) float64 { return math.Ceil(a[0]) }},
+	"floor": {1, func(a []float64) float64 { return math.Floor(a[0]) }},
+	"abs":   {1, func(a []float64) float64 { return math.Abs(a[0]) }},
+}
+
+// Expr is a parsed formula
+type Expr struct {
+	source string
+	root   node
+}
+
+// Parse checks the syntax of a formula and prepares it for evaluation
+func Parse(source string) (*Expr, error) {
+	if strings.TrimSpace(source) == "" {
+		return nil, errors.New("empty formula")
+	}
+	if len(source) > MaxLength {
+		return nil, fmt.Errorf("formula is longer than %d characters", MaxLength)
+	}
+
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.expression(0)
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos+1)
+	}
+	return &Expr{source: source, root: root}, nil
+}
+
+func (e *Expr) String() string {
+	return e.source
+}
+
+// Variables returns the names of the variables the formula uses, sorted
+func (e *Expr) Variables() []string {
+	seen := make(map[string]bool)
+	e.root.variables(seen)
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Eval computes the formula. Every variable it uses must be in vars.
+func (e *Expr) Eval(vars map[string]float64) (float64, error) {
+	value, err := e.root.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, errors.New("formula result is not a finite number")
+	}
+	return value, nil
+}
+
+type node interface {
+	eval(vars map[string]float64) (float64, error)
+	variables(seen map[string]bool)
+}
+
+type numberNode float64
+
+func (n numberNode) eval(map[string]float64) (float64, error) { return float64(n), nil }
+func (n numberNode) variables(map[string]bool)                {}
+
+type variableNode string
+
+func (n variableNode) eval(vars map[string]float64) (float64, error) {
+	value, ok := vars[string(n)]
+	if !ok {
+		return 0, fmt.Errorf("unknown variable %q", string(n))
+	}
+	return value, nil
+}
+
+func (n variableNode) variables(seen map[string]bool) { seen[string(n)] = true }
+
+type unaryNode struct {
+	operand node
+}
+
+func (n unaryNode) eval(vars map[string]float64) (float64, error) {
+	value, err := n.operand.eval(vars)
+	return -value, err
+}
+
+func (n unaryNode) variables(seen map[string]bool) { n.operand.variables(seen) }
+
+type binaryNode struct {
+	op          byte
+	left, right node
+}
+
+func (n binaryNode) eval(vars map[string]float64) (float64, error) {
+	left, err := n.left.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	right, err := n.right.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+
+	switch n.op {
+	case '+':
+		return left + right, nil
+	case '-':
+		return left - right, nil
+	case '*':
+		return left * right, nil
+	default:
+		if right == 0 {
+			return 0, errors.New("division by zero")
+		}
+		return left / right, nil
+	}
+}
+
+func (n binaryNode) variables(seen map[string]bool) {
+	n.left.variables(seen)
+	n.right.variables(seen)
+}
+
+type callNode struct {
+	name string
+	args []node
+}
+
+func (n callNode) eval(vars map[string]float64) (float64, error) {
+	values := make([]float64, len(n.args))
+	for i, arg := range n.args {
+		value, err := arg.eval(vars)
+		if err != nil {
+			return 0, err
+		}
+		values[i] = value
+	}
+	return functions[n.name].fn(values), nil
+}
+
+func (n callNode) variables(seen map[string]bool) {
+	for _, arg := range n.args {
+		arg.variables(seen)
+	}
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenIdent
+	tokenOperator
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func tokenize(source string) ([]token, error) {
+	var tokens []token
+	runes := []rune(source)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || r == '.':
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{tokenNumber, string(runes[start:i]), start})
+		case r == '_' || (r < unicode.MaxASCII && unicode.IsLetter(r)):
+			start := i
+			for i < len(runes) && (runes[i] == '_' || (runes[i] < unicode.MaxASCII && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])))) {
+				i++
+			}
+			tokens = append(tokens, token{tokenIdent, string(runes[start:i]), start})
+		case strings.ContainsRune("+-*/(),", r):
+			tokens = append(tokens, token{tokenOperator, string(r), i})
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d", r, i+1)
+		}
+	}
+	return append(tokens, token{tokenEOF, "end of formula", len(runes)}), nil
+}
+
+// parser is a recursive descent parser over the grammar
+//
+//	expression = term { ("+" | "-") term }
+//	term       = factor { ("*" | "/") factor }
+//	factor     = "-" factor | number | name | name "(" expression { "," expression } ")" | "(" expression ")"
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) accept(op string) bool {
+	if tok := p.peek(); tok.kind == tokenOperator && tok.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.accept(op) {
+		tok := p.peek()
+		return fmt.Errorf("expected %q at position %d, got %q", op, tok.pos+1, tok.text)
+	}
+	return nil
+}
+
+func (p *parser) expression(depth int) (node, error) {
+	if depth > maxDepth {
+		return nil, errors.New("formula is nested too deeply")
+	}
+
+	left, err := p.term(depth)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op byte
+		switch {
+		case p.accept("+"):
+			op = '+'
+		case p.accept("-"):
+			op = '-'
+		default:
+			return left, nil
+		}
+		right, err := p.term(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op, left, right}
+	}
+}
+
+func (p *parser) term(depth int) (node, error) {
+	left, err := p.factor(depth)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op byte
+		switch {
+		case p.accept("*"):
+			op = '*'
+		case p.accept("/"):
+			op = '/'
+		default:
+			return left, nil
+		}
+		right, err := p.factor(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op, left, right}
+	}
+}
+
+func (p *parser) factor(depth int) (node, error) {
+	if depth > maxDepth {
+		return nil, errors.New("formula is nested too deeply")
+	}
+
+	if p.accept("-") {
+		operand, err := p.factor(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return unaryNode{operand}, nil
+	}
+	if p.accept("(") {
+		inner, err := p.expression(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	}
+
+	tok := p.next()
+	switch tok.kind {
+	case tokenNumber:
+		value, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at position %d", tok.text, tok.pos+1)
+		}
+		return numberNode(value), nil
+	case tokenIdent:
+		if !p.accept("(") {
+			return variableNode(tok.text), nil
+		}
+		return p.call(tok, depth)
+	default:
+		return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos+1)
+	}
+}
+
+func (p *parser) call(name token, depth int) (node, error) {
+	function, ok := functions[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q", name.text)
+	}
+
+	var args []node
+	for {
+		arg, err := p.expression(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if !p.accept(",") {
+			break
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	if function.args >= 0 && len(args) != function.args {
+		return nil, fmt.Errorf("%s takes %d argument(s), got %d", name.text, function.args, len(args))
+	}
+	return callNode{name.text, args}, nil
+}
+
+func minOf(values []float64) float64 {
+	result := values[0]
+	for _, v := range values[1:] {
+		result = math.Min(result, v)
+	}
+	return result
+}
+
+func maxOf(values []float64) float64 {
+	result := values[0]
+	for _, v := range values[1:] {
+		result = math.Max(result, v)
+	}
+	return result
+}
@@ -0,0 +1,89 @@
+package formula
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	vars := map[string]float64{"width": 30, "height": 40, "price": 25, "k": 2.5}
+
+	tests := []struct {
+		source string
+		want   float64
+	}{
+		{"width*height*price*k", 75000},
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"12 / 4 / 3", 1},
+		{"-width + 50", 20},
+		{"- -2", 2},
+		{"max(width, height, 35)", 40},
+		{"min(width, height)", 30},
+		{"round(2.5) + ceil(0.1) + floor(1.9) + abs(-4)", 9},
+		{"width*height/100 * price * k", 750},
+		{".5 * 4", 2},
+	}
+	for _, tt := range tests {
+		expr, err := Parse(tt.source)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.source, err)
+			continue
+		}
+		got, err := expr.Eval(vars)
+		if err != nil {
+			t.Errorf("Eval(%q): %v", tt.source, err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Eval(%q) = %v, want %v", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	invalid := []string{
+		"",
+		"1 +",
+		"(1 + 2",
+		"1 + 2)",
+		"width height",
+		"2 ** 3",
+		"pow(2, 3)",
+		"round(1, 2)",
+		"max()",
+		"1.2.3",
+		"os.Exit(1)",
+		"width; height",
+		"ширина * 2",
+	}
+	for _, source := range invalid {
+		if _, err := Parse(source); err == nil {
+			t.Errorf("Parse(%q) should fail", source)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	for _, source := range []string{"width / (height - height)", "unknown * 2"} {
+		expr, err := Parse(source)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", source, err)
+		}
+		if _, err := expr.Eval(map[string]float64{"width": 1, "height": 2}); err == nil {
+			t.Errorf("Eval(%q) should fail", source)
+		}
+	}
+}
+
+func TestVariables(t *testing.T) {
+	expr, err := Parse("max(area * price, 100) * k + area")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got, want := expr.Variables(), []string{"area", "k", "price"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Variables() = %v, want %v", got, want)
+	}
+}
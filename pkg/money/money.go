@@ -0,0 +1,95 @@
+// Package money does exact price arithmetic in whole kopecks.
+//
+// Amounts are kept as integers so that sums and differences are exact. Only
+// multiplying by a rate (a price per dm², a discount, a tax) can give a
+// fraction of a kopeck; Round settles it once, half away from zero, and the
+// result is exact from then on.
+package money
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Kopecks is an amount of money in hundredths of a rouble
+type Kopecks int64
+
+// roundingPrecision is how many decimals of a kopeck Round looks at, so that
+// binary noise such as 100.49999999999999 for 1.005 ₽ rounds like 100.5
+const roundingPrecision = 6
+
+// Round turns a fractional number of kopecks into whole kopecks, half away
+// from zero
+func Round(kopecks float64) Kopecks {
+	rounded := math.Round(kopecks*math.Pow10(roundingPrecision)) / math.Pow10(roundingPrecision)
+	return Kopecks(math.Round(rounded))
+}
+
+// FromRubles converts an amount in roubles, e.g. a DECIMAL column or a config
+// value, rounding it to the kopeck
+func FromRubles(rubles float64) Kopecks {
+	return Round(rubles * 100)
+}
+
+// ParseRubles reads an amount typed by a person: "1500", "1500.5" or "1 500,50"
+func ParseRubles(s string) (Kopecks, error) {
+	s = strings.NewReplacer(" ", "", " ", "", ",", ".").Replace(strings.TrimSpace(s))
+	rubles, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(rubles) || math.IsInf(rubles, 0) {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	return FromRubles(rubles), nil
+}
+
+// Rubles is the amount in roubles, for DECIMAL columns and spreadsheets. Any
+// whole number of kopecks converts exactly to two decimals.
+func (k Kopecks) Rubles() float64 {
+	return float64(k) / 100
+}
+
+// MulRate multiplies the amount by a rate, e.g. 0.06 for 6%, or by a
+// quantity measured in fractions, rounding the result once
+func (k Kopecks) MulRate(rate float64) Kopecks {
+	return Round(float64(k) * rate)
+}
+
+// RoundUp rounds the amount up to a multiple of step, e.g. 1000 for whole
+// tens of roubles; a step of zero leaves it as is
+func (k Kopecks) RoundUp(step Kopecks) Kopecks {
+	if step <= 0 {
+		return k
+	}
+	rest := k % step
+	if rest > 0 {
+		return k - rest + step
+	}
+	return k - rest
+}
+
+// RoundNearest rounds the amount to the nearest multiple of step, half away
+// from zero; a step of zero leaves it as is
+func (k Kopecks) RoundNearest(step Kopecks) Kopecks {
+	if step <= 0 {
+		return k
+	}
+	rest := k % step
+	switch {
+	case 2*rest >= step:
+		return k - rest + step
+	case 2*rest <= -step:
+		return k - rest - step
+	default:
+		return k - rest
+	}
+}
+
+// String formats the amount with two decimals and no currency: "1234.50"
+func (k Kopecks) String() string {
+	sign := ""
+	if k < 0 {
+		sign, k = "-", -k
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, k/100, k%100)
+}
@@ -0,0 +1,116 @@
+package money
+
+import "testing"
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		kopecks float64
+		want    Kopecks
+	}{
+		{100, 100},
+		{100.4, 100},
+		{100.5, 101},
+		{-100.5, -101},
+		// 1.005 ₽ × 100 is 100.49999999999999 in binary
+		{1.005 * 100, 101},
+		{0.1 * 3 * 100, 30},
+	}
+	for _, tt := range tests {
+		if got := Round(tt.kopecks); got != tt.want {
+			t.Errorf("Round(%v) = %d, want %d", tt.kopecks, got, tt.want)
+		}
+	}
+}
+
+func TestFromRubles(t *testing.T) {
+	if got := FromRubles(0.1 + 0.2); got != 30 {
+		t.Errorf("FromRubles(0.1 + 0.2) = %d, want 30", got)
+	}
+	if got := FromRubles(2.675); got != 268 {
+		t.Errorf("FromRubles(2.675) = %d, want 268", got)
+	}
+	if got := Kopecks(123456).Rubles(); got != 1234.56 {
+		t.Errorf("Rubles() = %v, want 1234.56", got)
+	}
+}
+
+func TestParseRubles(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Kopecks
+	}{
+		{"1500", 150000},
+		{"1500.5", 150050},
+		{"1 500,50", 150050},
+		{" 0,015 ", 2},
+	}
+	for _, tt := range tests {
+		got, err := ParseRubles(tt.in)
+		if err != nil {
+			t.Errorf("ParseRubles(%q) failed: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseRubles(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "abc", "NaN", "Inf"} {
+		if _, err := ParseRubles(in); err == nil {
+			t.Errorf("ParseRubles(%q) succeeded, want an error", in)
+		}
+	}
+}
+
+func TestMulRate(t *testing.T) {
+	// 6% of 333.33 ₽ is 19.9998 ₽
+	if got := Kopecks(33333).MulRate(0.06); got != 2000 {
+		t.Errorf("MulRate = %d, want 2000", got)
+	}
+	// 3% of 0.50 ₽ is exactly 1.5 kopecks
+	if got := Kopecks(50).MulRate(0.03); got != 2 {
+		t.Errorf("MulRate = %d, want 2", got)
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := map[Kopecks]string{
+		0:       "0.00",
+		5:       "0.05",
+		123456:  "1234.56",
+		-123456: "-1234.56",
+		-5:      "-0.05",
+	}
+	for k, want := range tests {
+		if got := k.String(); got != want {
+			t.Errorf("Kopecks(%d).String() = %q, want %q", int64(k), got, want)
+		}
+	}
+}
+
+func TestRoundToStep(t *testing.T) {
+	tests := []struct {
+		k       Kopecks
+		step    Kopecks
+		up      Kopecks
+		nearest Kopecks
+	}{
+		{8734, 100, 8800, 8700},
+		{8750, 100, 8800, 8800},
+		{8734, 1000, 9000, 9000},
+		{12400, 5000, 15000, 10000},
+		{12500, 5000, 15000, 15000},
+		{10000, 5000, 10000, 10000},
+		{0, 5000, 0, 0},
+		{-8750, 100, -8700, -8800},
+		{8734, 0, 8734, 8734},
+	}
+	for _, tt := range tests {
+		if got := tt.k.RoundUp(tt.step); got != tt.up {
+			t.Errorf("Kopecks(%d).RoundUp(%d) = %d, want %d", tt.k, tt.step, got, tt.up)
+		}
+		if got := tt.k.RoundNearest(tt.step); got != tt.nearest {
+			t.Errorf("Kopecks(%d).RoundNearest(%d) = %d, want %d", tt.k, tt.step, got, tt.nearest)
+		}
+	}
+}